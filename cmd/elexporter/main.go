@@ -6,16 +6,26 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/u-one/go-el-controller/echonetlite"
+	"github.com/u-one/go-el-controller/internal/envflag"
+	"github.com/u-one/go-el-controller/transport"
 )
 
 var version string
 
 var exporterAddr = flag.String("listen-address", ":8083", "The address to listen on for HTTP requests.")
+var classDir = flag.String("class-dir", "", "data/csv directory of ECHONETLite-ObjectDatabase to use instead of the bundled one (env EL_CLASS_DIR)")
+var netInterface = flag.String("interface", "", "name or CIDR of the network interface for ECHONET Lite, e.g. eth0 or 192.168.1.0/24 (env EL_INTERFACE)")
+var ipv6 = flag.Bool("ipv6", false, "use IPv6 multicast group ff02::1 instead of 224.0.23.0, on the link of -interface which is required")
+var capture = flag.String("capture", "", "file to record ECHONET Lite packets sent and received, in pcapng if it ends with .pcapng, otherwise in JSONL (env EL_CAPTURE)")
+var lang = flag.String("lang", "", "language of class and property names: ja (default) or en (env EL_LANG)")
 
 var (
 	verCounter = prometheus.NewCounterVec(
@@ -35,20 +45,39 @@ func init() {
 	prometheus.MustRegister(verCounter)
 }
 
+// captureTransports wraps transports of elc to record packets to c
+func captureTransports(elc *echonetlite.ControllerNode, c *transport.Capture) {
+	elc.MulticastReceiver = c.MulticastReceiver(elc.MulticastReceiver)
+	elc.UnicastReceiver = c.UnicastReceiver(elc.UnicastReceiver)
+	elc.MulticastSender = c.MulticastSender(elc.MulticastSender, elc.MulticastGroup)
+	elc.UnicastSender = c.UnicastSender(elc.UnicastSender)
+}
+
+// env is environment variables to set flags which are not given in command line
+var env = map[string]string{
+	"class-dir": "EL_CLASS_DIR",
+	"interface": "EL_INTERFACE",
+	"capture":   "EL_CAPTURE",
+	"lang":      "EL_LANG",
+}
+
 func main() {
-	flag.Parse()
+	err := envflag.Parse(env)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	fmt.Printf("version: %s\n", version)
 	verCounter.WithLabelValues(version).Inc()
 
-	err := echonetlite.PrepareClassDictionary()
+	err = echonetlite.PrepareClassDictionary(*classDir, *lang)
 	if err != nil {
 		log.Println(err)
 	}
 
 	ctx := context.Background()
 	//ctx, cancel := context.WithTimeout(ctx, time.Second*10)
-	ctx, cancel := context.WithCancel(ctx)
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	ch := make(chan error)
@@ -65,29 +94,46 @@ func main() {
 		log.Println("exporter finished")
 	}()
 
-	elc, err := echonetlite.NewControllerNode()
+	elc, err := echonetlite.NewControllerNodeOn(echonetlite.NetworkConfig{Interface: *netInterface, IPv6: *ipv6})
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	elc.Start(ctx)
 	defer elc.Close()
 
+	if *capture != "" {
+		c, err := transport.CreateCapture(*capture)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		// closed after the controller stops
+		defer c.Close()
+		captureTransports(elc, c)
+		log.Println("capture packets to", *capture)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- elc.Run(ctx)
+	}()
+
 	log.Println("start sendLoop")
 
-	func() {
-		t := time.NewTicker(30 * time.Second)
-		defer t.Stop()
+	t := time.NewTicker(30 * time.Second)
+	defer t.Stop()
 
-		for {
-			select {
-			case <-t.C:
-				elc.RequestAirConState()
-			case <-ctx.Done():
-				return
+	for {
+		select {
+		case <-t.C:
+			elc.RequestAirConState()
+		case err := <-done:
+			// all goroutines of the controller have exited
+			if err != nil {
+				log.Println(err)
 			}
+			log.Println("finished")
+			return
 		}
-	}()
-
-	log.Println("finished")
+	}
 }
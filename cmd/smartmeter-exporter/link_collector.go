@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/u-one/go-el-controller/wisun"
+)
+
+// linkQualityReporter reports quality of the link to the smart-meter
+type linkQualityReporter interface {
+	LinkQuality() wisun.LinkQuality
+}
+
+// linkCollector exports quality of the link to the smart-meter, to alert before readings fail
+type linkCollector struct {
+	client      linkQualityReporter
+	lqiDesc     *prometheus.Desc
+	rssiDesc    *prometheus.Desc
+	channelDesc *prometheus.Desc
+}
+
+func newLinkCollector(client linkQualityReporter) *linkCollector {
+	return &linkCollector{
+		client: client,
+		lqiDesc: prometheus.NewDesc(
+			"home_smartmeter_exporter_link_lqi",
+			"LQI of the link to the smart-meter measured by the module, absent if the module can't measure it",
+			[]string{"pan_id"}, nil,
+		),
+		rssiDesc: prometheus.NewDesc(
+			"home_smartmeter_exporter_link_rssi_dbm",
+			"RSSI of frames from the smart-meter since the last update, absent if none were received",
+			[]string{"pan_id"}, nil,
+		),
+		channelDesc: prometheus.NewDesc(
+			"home_smartmeter_exporter_link_channel",
+			"Channel number of the PAN joined",
+			[]string{"pan_id"}, nil,
+		),
+	}
+}
+
+func (c *linkCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.lqiDesc
+	ch <- c.rssiDesc
+	ch <- c.channelDesc
+}
+
+func (c *linkCollector) Collect(ch chan<- prometheus.Metric) {
+	lq := c.client.LinkQuality()
+	if lq.HasLQI {
+		ch <- prometheus.MustNewConstMetric(c.lqiDesc, prometheus.GaugeValue, float64(lq.LQI), lq.PanID)
+	}
+	if lq.HasRSSI {
+		ch <- prometheus.MustNewConstMetric(c.rssiDesc, prometheus.GaugeValue, lq.RSSI, lq.PanID)
+	}
+	if channel, err := strconv.ParseUint(lq.Channel, 16, 8); err == nil {
+		ch <- prometheus.MustNewConstMetric(c.channelDesc, prometheus.GaugeValue, float64(channel), lq.PanID)
+	}
+}
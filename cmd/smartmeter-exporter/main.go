@@ -14,6 +14,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/u-one/go-el-controller/echonetlite"
+	"github.com/u-one/go-el-controller/internal/envflag"
 	"github.com/u-one/go-el-controller/wisun"
 )
 
@@ -21,8 +22,13 @@ var version string
 var bRouteID = flag.String("brouteid", "", "B-route ID")
 var bRoutePW = flag.String("broutepw", "", "B-route password")
 var serialPort = flag.String("serial-port", "/dev/ttyUSB0", "serial port for BP35C2")
+var module = flag.String("module", "auto", "Wi-SUN module: auto, bp35c2, bp35a1 or rl7023")
+var dataFormat = flag.String("erxudp-format", "keep", "format of ERXUDP data to set to the module with WOPT: keep, binary or hex")
+var stateFile = flag.String("state-file", "", "file to store the PAN joined last, to skip scanning on restart")
 var exporterPort = flag.String("exporter-port", "8080", "address for prometheus")
 var updateInterval = flag.Duration("interval", 1*time.Minute, "interval to get data from smart-meter")
+var classDir = flag.String("class-dir", "", "data/csv directory of ECHONETLite-ObjectDatabase to use instead of the bundled one (env EL_CLASS_DIR)")
+var lang = flag.String("lang", "", "language of class and property names: ja (default) or en (env EL_LANG)")
 
 var (
 	verCounter = prometheus.NewCounterVec(
@@ -42,9 +48,18 @@ func init() {
 	prometheus.MustRegister(verCounter)
 }
 
+// env is environment variables to set flags which are not given in command line
+var env = map[string]string{
+	"class-dir": "EL_CLASS_DIR",
+	"lang":      "EL_LANG",
+}
+
 func main() {
-	flag.Parse()
-	err := run()
+	err := envflag.Parse(env)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = run()
 	if err != nil {
 		log.Println(err)
 	}
@@ -55,12 +70,23 @@ func run() error {
 	fmt.Printf("version: %s serial-port:%s exporter-port:%s\n", version, *serialPort, *exporterPort)
 	verCounter.WithLabelValues(version).Inc()
 
-	err := echonetlite.PrepareClassDictionary()
+	err := echonetlite.PrepareClassDictionary(*classDir, *lang)
 	if err != nil {
 		log.Println(err)
 	}
 
 	wisunClient := wisun.NewBP35C2Client(*serialPort)
+	wisunClient.SetStateFile(*stateFile)
+	err = wisunClient.UseDialect(*module)
+	if err != nil {
+		return fmt.Errorf("failed to select module: %w", err)
+	}
+	df, err := wisun.ParseDataFormat(*dataFormat)
+	if err != nil {
+		return err
+	}
+	wisunClient.SetDataFormat(df)
+	prometheus.MustRegister(newLinkCollector(wisunClient))
 	node := echonetlite.NewElectricityControllerNode(wisunClient)
 
 	ctx := context.Background()
@@ -76,6 +102,9 @@ func run() error {
 	ctx, cancel = context.WithCancel(ctx)
 	defer cancel()
 
+	// Fixed-time energy is notified by the smart-meter every 30 minutes
+	go node.HandleNotifications(ctx)
+
 	// Start prometheus exporter
 	ch := make(chan error)
 	go func() {
@@ -100,7 +129,11 @@ func run() error {
 		for {
 			select {
 			case <-t.C:
-				_, err := node.GetPowerConsumption()
+				_, err := node.GetReadings()
+				if err != nil {
+					log.Println(err)
+				}
+				err = wisunClient.UpdateLinkQuality()
 				if err != nil {
 					log.Println(err)
 				}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/u-one/go-el-controller/echonetlite"
+	"github.com/u-one/go-el-controller/wisun"
+)
+
+const timeLayout = "2006-01-02 15:04"
+
+var bRouteID = flag.String("brouteid", "", "B-route ID")
+var bRoutePW = flag.String("broutepw", "", "B-route password")
+var serialPort = flag.String("serial-port", "/dev/ttyUSB0", "serial port for BP35C2")
+var module = flag.String("module", "auto", "Wi-SUN module: auto, bp35c2, bp35a1 or rl7023")
+var dataFormat = flag.String("erxudp-format", "keep", "format of ERXUDP data to set to the module with WOPT: keep, binary or hex")
+var stateFile = flag.String("state-file", "", "file to store the PAN joined last, to skip scanning on restart")
+var day = flag.Int("day", 0, "days before today to read historical data 1 (0-99)")
+var history2 = flag.Bool("history2", false, "read historical data 2 (0xEC) instead of historical data 1 (0xE2/0xE4)")
+var from = flag.String("from", "", "time to read historical data 2 back from, e.g. \"2021-03-01 12:30\" (default: last half hour)")
+var count = flag.Int("count", 12, "number of 30 minutes segments of historical data 2 (1-12)")
+var format = flag.String("format", "csv", "output format: csv or json")
+var output = flag.String("o", "", "output file (default: stdout, mixed with logs)")
+
+// record is a reading with its direction
+type record struct {
+	echonetlite.Reading
+	Direction string `json:"direction"`
+}
+
+func main() {
+	flag.Parse()
+	err := run()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("unsupported format: %s", *format)
+	}
+
+	wisunClient := wisun.NewBP35C2Client(*serialPort)
+	wisunClient.SetStateFile(*stateFile)
+	err := wisunClient.UseDialect(*module)
+	if err != nil {
+		return fmt.Errorf("failed to select module: %w", err)
+	}
+	df, err := wisun.ParseDataFormat(*dataFormat)
+	if err != nil {
+		return err
+	}
+	wisunClient.SetDataFormat(df)
+	node := echonetlite.NewElectricityControllerNode(wisunClient)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Second)
+	defer cancel()
+
+	err = node.Start(ctx, *bRouteID, *bRoutePW)
+	if err != nil {
+		return fmt.Errorf("failed to start: %w", err)
+	}
+	defer node.Close()
+
+	records, err := readHistory(ctx, node)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *output != "" {
+		w, err = os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	if *format == "json" {
+		return writeJSON(w, records)
+	}
+	return writeCSV(w, records)
+}
+
+func readHistory(ctx context.Context, node *echonetlite.ElectricityControllerNode) ([]record, error) {
+	var normal, reverse []echonetlite.Reading
+	var err error
+	if *history2 {
+		t := time.Now().Truncate(30 * time.Minute)
+		if *from != "" {
+			t, err = time.ParseInLocation(timeLayout, *from, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("invalid from: %w", err)
+			}
+		}
+		normal, reverse, err = node.History2(ctx, t, *count)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		normal, err = node.History(ctx, *day)
+		if err != nil {
+			return nil, err
+		}
+		// reverse direction is available only with solar power generation
+		reverse, err = node.HistoryReverse(ctx, *day)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	records := []record{}
+	for _, r := range normal {
+		records = append(records, record{Reading: r, Direction: "normal"})
+	}
+	for _, r := range reverse {
+		records = append(records, record{Reading: r, Direction: "reverse"})
+	}
+	return records, nil
+}
+
+func writeCSV(w io.Writer, records []record) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "direction", "kwh"})
+	for _, r := range records {
+		cw.Write([]string{r.Time.Format(time.RFC3339), r.Direction, strconv.FormatFloat(r.KWh, 'f', -1, 64)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, records []record) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
@@ -0,0 +1,303 @@
+package echonetlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// OperationModeValue is value of operation mode setting (0xB0) of home air conditioner
+type OperationModeValue byte
+
+// definition of operation modes
+const (
+	ModeOther            OperationModeValue = 0x40
+	ModeAuto             OperationModeValue = 0x41
+	ModeCooling          OperationModeValue = 0x42
+	ModeHeating          OperationModeValue = 0x43
+	ModeDehumidification OperationModeValue = 0x44
+	ModeAirCirculator    OperationModeValue = 0x45
+)
+
+func (m OperationModeValue) String() string {
+	switch m {
+	case ModeOther:
+		return "Other"
+	case ModeAuto:
+		return "Auto"
+	case ModeCooling:
+		return "Cooling"
+	case ModeHeating:
+		return "Heating"
+	case ModeDehumidification:
+		return "Dehumidification"
+	case ModeAirCirculator:
+		return "AirCirculator"
+	default:
+		return fmt.Sprintf("unknown(%02x)", byte(m))
+	}
+}
+
+// AirFlowRateValue is value of air flow rate setting (0xA0) of home air conditioner
+type AirFlowRateValue byte
+
+// AirFlowRateAuto is automatic air flow rate control
+const AirFlowRateAuto AirFlowRateValue = 0x41
+
+// AirFlowRateLevel returns AirFlowRateValue of the level (1-8)
+func AirFlowRateLevel(level int) AirFlowRateValue {
+	return AirFlowRateValue(0x30 + level)
+}
+
+// Level returns air flow rate level (1-8). It returns 0 for automatic control.
+func (r AirFlowRateValue) Level() int {
+	if r >= 0x31 && r <= 0x38 {
+		return int(r - 0x30)
+	}
+	return 0
+}
+
+func (r AirFlowRateValue) String() string {
+	if r == AirFlowRateAuto {
+		return "Auto"
+	}
+	if l := r.Level(); l != 0 {
+		return fmt.Sprintf("Level%d", l)
+	}
+	return fmt.Sprintf("unknown(%02x)", byte(r))
+}
+
+// AutoAirFlowDirectionValue is value of automatic control of air flow direction setting (0xA1)
+type AutoAirFlowDirectionValue byte
+
+// definition of automatic control of air flow direction
+const (
+	AirFlowDirectionAuto           AutoAirFlowDirectionValue = 0x41
+	AirFlowDirectionManual         AutoAirFlowDirectionValue = 0x42
+	AirFlowDirectionAutoVertical   AutoAirFlowDirectionValue = 0x43
+	AirFlowDirectionAutoHorizontal AutoAirFlowDirectionValue = 0x44
+)
+
+func (d AutoAirFlowDirectionValue) String() string {
+	switch d {
+	case AirFlowDirectionAuto:
+		return "Auto"
+	case AirFlowDirectionManual:
+		return "Manual"
+	case AirFlowDirectionAutoVertical:
+		return "AutoVertical"
+	case AirFlowDirectionAutoHorizontal:
+		return "AutoHorizontal"
+	default:
+		return fmt.Sprintf("unknown(%02x)", byte(d))
+	}
+}
+
+// AirFlowDirectionValue is value of air flow direction (vertical) setting (0xA4)
+type AirFlowDirectionValue byte
+
+// definition of air flow direction (vertical)
+const (
+	AirFlowUppermost     AirFlowDirectionValue = 0x41
+	AirFlowLowermost     AirFlowDirectionValue = 0x42
+	AirFlowCentral       AirFlowDirectionValue = 0x43
+	AirFlowUpperMidpoint AirFlowDirectionValue = 0x44 // midpoint between uppermost and central
+	AirFlowLowerMidpoint AirFlowDirectionValue = 0x45 // midpoint between lowermost and central
+)
+
+func (d AirFlowDirectionValue) String() string {
+	switch d {
+	case AirFlowUppermost:
+		return "Uppermost"
+	case AirFlowLowermost:
+		return "Lowermost"
+	case AirFlowCentral:
+		return "Central"
+	case AirFlowUpperMidpoint:
+		return "UpperMidpoint"
+	case AirFlowLowerMidpoint:
+		return "LowerMidpoint"
+	default:
+		return fmt.Sprintf("unknown(%02x)", byte(d))
+	}
+}
+
+// values of properties which are read and written as bool
+const (
+	operationStatusOn  = 0x30
+	operationStatusOff = 0x31
+	powerSavingOn      = 0x41
+	powerSavingOff     = 0x42
+)
+
+// Aircon is client to control a home air conditioner object (0x0130) on a node
+// Reads are done with Get and writes with SetC. Properties the device refuses are reported as SNAError.
+// EDTs are decoded and encoded with codecs derived from the class dictionary,
+// so values out of the value ranges in it are rejected.
+type Aircon struct {
+	elc  *ControllerNode
+	addr string
+	obj  Object
+}
+
+// Aircon returns Aircon for obj on the node at addr
+func (elc *ControllerNode) Aircon(addr string, obj Object) *Aircon {
+	return &Aircon{elc: elc, addr: addr, obj: obj}
+}
+
+// Object returns the home air conditioner object
+func (a *Aircon) Object() Object {
+	return a.obj
+}
+
+// get reads a property
+func (a *Aircon) get(ctx context.Context, epc PropertyCode) (Property, error) {
+	props, err := a.elc.Get(ctx, a.addr, a.obj, epc)
+	if err != nil {
+		return Property{}, err
+	}
+	for _, p := range props {
+		if PropertyCode(p.Code) == epc {
+			return p, nil
+		}
+	}
+	return Property{}, fmt.Errorf("EPC[%02x] missing in response from %s", byte(epc), a.addr)
+}
+
+// getEnum reads a property which takes one of defined values
+func (a *Aircon) getEnum(ctx context.Context, epc PropertyCode) (uint64, error) {
+	p, err := a.get(ctx, epc)
+	if err != nil {
+		return 0, err
+	}
+	v, err := decodingCodecs().Decode(a.obj, p)
+	if err != nil {
+		return 0, fmt.Errorf("invalid EDT of EPC[%02x] from %s: %w", byte(epc), a.addr, err)
+	}
+	e, ok := v.(Enum)
+	if !ok {
+		return 0, fmt.Errorf("EPC[%02x] of %s is not an enum: %s", byte(epc), a.obj, v)
+	}
+	if e.Name == "" {
+		return e.Value, fmt.Errorf("unknown value of EPC[%02x] from %s: %s", byte(epc), a.addr, e)
+	}
+	return e.Value, nil
+}
+
+// getNumber reads a number property. ok is false if the value is out of its value range, which means not available.
+func (a *Aircon) getNumber(ctx context.Context, epc PropertyCode) (n int, ok bool, err error) {
+	p, err := a.get(ctx, epc)
+	if err != nil {
+		return 0, false, err
+	}
+	v, err := decodingCodecs().DecodeNumber(a.obj, p)
+	if errors.Is(err, ErrOutOfRange) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid EDT of EPC[%02x] from %s: %w", byte(epc), a.addr, err)
+	}
+	return int(v.Value), true, nil
+}
+
+// set writes a property with EDT encoded from v
+func (a *Aircon) set(ctx context.Context, epc PropertyCode, v interface{}) error {
+	p, err := decodingCodecs().Encode(a.obj, epc, v)
+	if err != nil {
+		return fmt.Errorf("invalid value: %w", err)
+	}
+	return a.elc.SetC(ctx, a.addr, a.obj, p)
+}
+
+// Power returns true if the air conditioner is operating (0x80)
+func (a *Aircon) Power(ctx context.Context) (bool, error) {
+	v, err := a.getEnum(ctx, OperationStatus)
+	return v == operationStatusOn, err
+}
+
+// SetPower turns the air conditioner on or off (0x80)
+func (a *Aircon) SetPower(ctx context.Context, on bool) error {
+	if on {
+		return a.set(ctx, OperationStatus, operationStatusOn)
+	}
+	return a.set(ctx, OperationStatus, operationStatusOff)
+}
+
+// OperationMode returns operation mode (0xB0)
+func (a *Aircon) OperationMode(ctx context.Context) (OperationModeValue, error) {
+	v, err := a.getEnum(ctx, OperationMode)
+	return OperationModeValue(v), err
+}
+
+// SetOperationMode sets operation mode (0xB0)
+func (a *Aircon) SetOperationMode(ctx context.Context, mode OperationModeValue) error {
+	return a.set(ctx, OperationMode, byte(mode))
+}
+
+// Temperature returns set temperature in ℃ (0xB3). ok is false if it is undefined.
+func (a *Aircon) Temperature(ctx context.Context) (temp int, ok bool, err error) {
+	return a.getNumber(ctx, SetTemperature)
+}
+
+// SetTemperature sets temperature in ℃ (0xB3)
+func (a *Aircon) SetTemperature(ctx context.Context, temp int) error {
+	return a.set(ctx, SetTemperature, temp)
+}
+
+// AirFlowRate returns air flow rate (0xA0)
+func (a *Aircon) AirFlowRate(ctx context.Context) (AirFlowRateValue, error) {
+	v, err := a.getEnum(ctx, AirFlowRate)
+	return AirFlowRateValue(v), err
+}
+
+// SetAirFlowRate sets air flow rate (0xA0)
+func (a *Aircon) SetAirFlowRate(ctx context.Context, rate AirFlowRateValue) error {
+	return a.set(ctx, AirFlowRate, byte(rate))
+}
+
+// AutoAirFlowDirection returns automatic control of air flow direction (0xA1)
+func (a *Aircon) AutoAirFlowDirection(ctx context.Context) (AutoAirFlowDirectionValue, error) {
+	v, err := a.getEnum(ctx, AutoAirFlowDirection)
+	return AutoAirFlowDirectionValue(v), err
+}
+
+// SetAutoAirFlowDirection sets automatic control of air flow direction (0xA1)
+func (a *Aircon) SetAutoAirFlowDirection(ctx context.Context, d AutoAirFlowDirectionValue) error {
+	return a.set(ctx, AutoAirFlowDirection, byte(d))
+}
+
+// AirFlowDirection returns air flow direction in the vertical direction (0xA4)
+func (a *Aircon) AirFlowDirection(ctx context.Context) (AirFlowDirectionValue, error) {
+	v, err := a.getEnum(ctx, AirFlowDirectionVertical)
+	return AirFlowDirectionValue(v), err
+}
+
+// SetAirFlowDirection sets air flow direction in the vertical direction (0xA4)
+// Automatic control (0xA1) should be Manual or AutoHorizontal for it to take effect.
+func (a *Aircon) SetAirFlowDirection(ctx context.Context, d AirFlowDirectionValue) error {
+	return a.set(ctx, AirFlowDirectionVertical, byte(d))
+}
+
+// RoomTemperature returns measured room temperature in ℃ (0xBB). ok is false if it is unmeasurable.
+func (a *Aircon) RoomTemperature(ctx context.Context) (temp int, ok bool, err error) {
+	return a.getNumber(ctx, MeasuredRoomTemperature)
+}
+
+// Humidity returns measured room relative humidity in % (0xBA). ok is false if it is not available.
+func (a *Aircon) Humidity(ctx context.Context) (humidity int, ok bool, err error) {
+	return a.getNumber(ctx, MeasuredRoomHumidity)
+}
+
+// PowerSaving returns true if the air conditioner is operating in power-saving mode (0x8F)
+func (a *Aircon) PowerSaving(ctx context.Context) (bool, error) {
+	v, err := a.getEnum(ctx, PowerReductionState)
+	return v == powerSavingOn, err
+}
+
+// SetPowerSaving sets power-saving operation (0x8F)
+func (a *Aircon) SetPowerSaving(ctx context.Context, on bool) error {
+	if on {
+		return a.set(ctx, PowerReductionState, powerSavingOn)
+	}
+	return a.set(ctx, PowerReductionState, powerSavingOff)
+}
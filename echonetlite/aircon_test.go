@@ -0,0 +1,248 @@
+package echonetlite
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+)
+
+// optional is a value which may not be available
+type optional struct {
+	Value int
+	OK    bool
+}
+
+func reading(v int, ok bool, err error) (interface{}, error) {
+	return optional{Value: v, OK: ok}, err
+}
+
+func TestAircon(t *testing.T) {
+	// header of frames between controller 05ff01 and aircon 013001
+	req := []byte{0x10, 0x81, 0x00, 0x00, 0x05, 0xff, 0x01, 0x01, 0x30, 0x01}
+	res := []byte{0x10, 0x81, 0x00, 0x00, 0x01, 0x30, 0x01, 0x05, 0xff, 0x01}
+	frame := func(header []byte, body ...byte) []byte {
+		return append(append([]byte{}, header...), body...)
+	}
+
+	testcases := []struct {
+		name     string
+		call     func(ctx context.Context, a *Aircon) (interface{}, error)
+		request  []byte
+		response []byte
+		want     interface{}
+		err      error
+	}{
+		{
+			name:     "Power",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return a.Power(ctx) },
+			request:  frame(req, 0x62, 0x01, 0x80, 0x00),
+			response: frame(res, 0x72, 0x01, 0x80, 0x01, 0x30),
+			want:     true,
+		},
+		{
+			name:     "SetPower",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return nil, a.SetPower(ctx, false) },
+			request:  frame(req, 0x61, 0x01, 0x80, 0x01, 0x31),
+			response: frame(res, 0x71, 0x01, 0x80, 0x00),
+		},
+		{
+			name:     "OperationMode",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return a.OperationMode(ctx) },
+			request:  frame(req, 0x62, 0x01, 0xb0, 0x00),
+			response: frame(res, 0x72, 0x01, 0xb0, 0x01, 0x42),
+			want:     ModeCooling,
+		},
+		{
+			name: "SetOperationMode",
+			call: func(ctx context.Context, a *Aircon) (interface{}, error) {
+				return nil, a.SetOperationMode(ctx, ModeHeating)
+			},
+			request:  frame(req, 0x61, 0x01, 0xb0, 0x01, 0x43),
+			response: frame(res, 0x71, 0x01, 0xb0, 0x00),
+		},
+		{
+			name:     "Temperature",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return reading(a.Temperature(ctx)) },
+			request:  frame(req, 0x62, 0x01, 0xb3, 0x00),
+			response: frame(res, 0x72, 0x01, 0xb3, 0x01, 0x1a),
+			want:     optional{Value: 26, OK: true},
+		},
+		{
+			name:     "Temperature undefined",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return reading(a.Temperature(ctx)) },
+			request:  frame(req, 0x62, 0x01, 0xb3, 0x00),
+			response: frame(res, 0x72, 0x01, 0xb3, 0x01, 0xfd),
+			want:     optional{},
+		},
+		{
+			name:     "RoomTemperature",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return reading(a.RoomTemperature(ctx)) },
+			request:  frame(req, 0x62, 0x01, 0xbb, 0x00),
+			response: frame(res, 0x72, 0x01, 0xbb, 0x01, 0xfb),
+			want:     optional{Value: -5, OK: true},
+		},
+		{
+			name:     "RoomTemperature unmeasurable",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return reading(a.RoomTemperature(ctx)) },
+			request:  frame(req, 0x62, 0x01, 0xbb, 0x00),
+			response: frame(res, 0x72, 0x01, 0xbb, 0x01, 0x7e),
+			want:     optional{},
+		},
+		{
+			name:     "SetTemperature",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return nil, a.SetTemperature(ctx, 25) },
+			request:  frame(req, 0x61, 0x01, 0xb3, 0x01, 0x19),
+			response: frame(res, 0x71, 0x01, 0xb3, 0x00),
+		},
+		{
+			name:     "SetTemperature SNA",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return nil, a.SetTemperature(ctx, 35) },
+			request:  frame(req, 0x61, 0x01, 0xb3, 0x01, 0x23),
+			response: frame(res, 0x51, 0x01, 0xb3, 0x01, 0x23),
+			err:      fmt.Errorf("SetC_SNA from 192.168.1.5: EPC[b3] PDC[1] EDT[23]"),
+		},
+		{
+			name:     "AirFlowRate auto",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return a.AirFlowRate(ctx) },
+			request:  frame(req, 0x62, 0x01, 0xa0, 0x00),
+			response: frame(res, 0x72, 0x01, 0xa0, 0x01, 0x41),
+			want:     AirFlowRateAuto,
+		},
+		{
+			name:     "AirFlowRate",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return a.AirFlowRate(ctx) },
+			request:  frame(req, 0x62, 0x01, 0xa0, 0x00),
+			response: frame(res, 0x72, 0x01, 0xa0, 0x01, 0x33),
+			want:     AirFlowRateLevel(3),
+		},
+		{
+			name: "SetAirFlowRate",
+			call: func(ctx context.Context, a *Aircon) (interface{}, error) {
+				return nil, a.SetAirFlowRate(ctx, AirFlowRateAuto)
+			},
+			request:  frame(req, 0x61, 0x01, 0xa0, 0x01, 0x41),
+			response: frame(res, 0x71, 0x01, 0xa0, 0x00),
+		},
+		{
+			name:     "AutoAirFlowDirection",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return a.AutoAirFlowDirection(ctx) },
+			request:  frame(req, 0x62, 0x01, 0xa1, 0x00),
+			response: frame(res, 0x72, 0x01, 0xa1, 0x01, 0x42),
+			want:     AirFlowDirectionManual,
+		},
+		{
+			name: "SetAutoAirFlowDirection",
+			call: func(ctx context.Context, a *Aircon) (interface{}, error) {
+				return nil, a.SetAutoAirFlowDirection(ctx, AirFlowDirectionAutoVertical)
+			},
+			request:  frame(req, 0x61, 0x01, 0xa1, 0x01, 0x43),
+			response: frame(res, 0x71, 0x01, 0xa1, 0x00),
+		},
+		{
+			name:     "AirFlowDirection",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return a.AirFlowDirection(ctx) },
+			request:  frame(req, 0x62, 0x01, 0xa4, 0x00),
+			response: frame(res, 0x72, 0x01, 0xa4, 0x01, 0x43),
+			want:     AirFlowCentral,
+		},
+		{
+			name: "SetAirFlowDirection",
+			call: func(ctx context.Context, a *Aircon) (interface{}, error) {
+				return nil, a.SetAirFlowDirection(ctx, AirFlowLowermost)
+			},
+			request:  frame(req, 0x61, 0x01, 0xa4, 0x01, 0x42),
+			response: frame(res, 0x71, 0x01, 0xa4, 0x00),
+		},
+		{
+			name:     "Humidity",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return reading(a.Humidity(ctx)) },
+			request:  frame(req, 0x62, 0x01, 0xba, 0x00),
+			response: frame(res, 0x72, 0x01, 0xba, 0x01, 0x37),
+			want:     optional{Value: 55, OK: true},
+		},
+		{
+			name:     "Humidity undefined",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return reading(a.Humidity(ctx)) },
+			request:  frame(req, 0x62, 0x01, 0xba, 0x00),
+			response: frame(res, 0x72, 0x01, 0xba, 0x01, 0xfd),
+			want:     optional{},
+		},
+		{
+			name:     "Humidity SNA",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return reading(a.Humidity(ctx)) },
+			request:  frame(req, 0x62, 0x01, 0xba, 0x00),
+			response: frame(res, 0x52, 0x01, 0xba, 0x00),
+			want:     optional{},
+			err:      fmt.Errorf("Get_SNA from 192.168.1.5: EPC[ba] PDC[0] EDT[]"),
+		},
+		{
+			name:     "PowerSaving",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return a.PowerSaving(ctx) },
+			request:  frame(req, 0x62, 0x01, 0x8f, 0x00),
+			response: frame(res, 0x72, 0x01, 0x8f, 0x01, 0x42),
+			want:     false,
+		},
+		{
+			name:     "SetPowerSaving",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return nil, a.SetPowerSaving(ctx, true) },
+			request:  frame(req, 0x61, 0x01, 0x8f, 0x01, 0x41),
+			response: frame(res, 0x71, 0x01, 0x8f, 0x00),
+		},
+		{
+			name:     "unknown value",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return a.Power(ctx) },
+			request:  frame(req, 0x62, 0x01, 0x80, 0x00),
+			response: frame(res, 0x72, 0x01, 0x80, 0x01, 0x00),
+			want:     false,
+			err:      fmt.Errorf("unknown value of EPC[80] from 192.168.1.5: 0x00"),
+		},
+		{
+			name:     "invalid EDT",
+			call:     func(ctx context.Context, a *Aircon) (interface{}, error) { return reading(a.Temperature(ctx)) },
+			request:  frame(req, 0x62, 0x01, 0xb3, 0x00),
+			response: frame(res, 0x72, 0x01, 0xb3, 0x02, 0x00, 0x1a),
+			want:     optional{},
+			err:      fmt.Errorf("invalid EDT of EPC[b3] from 192.168.1.5: invalid EDT length: 2, expected 1"),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			c := newTestController(t, ctx, ctrl, tc.request, [][]byte{tc.response})
+			a := c.Aircon("192.168.1.5", NewObject(AirConditionerGroup, HomeAirConditioner, 0x01))
+
+			got, err := tc.call(ctx, a)
+			checkError(t, tc.err, err)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("result differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAircon_InvalidValue(t *testing.T) {
+	t.Parallel()
+
+	a := (&ControllerNode{}).Aircon("192.168.1.5", NewObject(AirConditionerGroup, HomeAirConditioner, 0x01))
+	ctx := context.Background()
+
+	// values out of the value ranges in the class dictionary
+	checkError(t, fmt.Errorf("invalid value: EPC[b3]: 51 out of range 0～50"), a.SetTemperature(ctx, 51))
+	checkError(t, fmt.Errorf("invalid value: EPC[b0]: undefined value: 0x46"), a.SetOperationMode(ctx, OperationModeValue(0x46)))
+	checkError(t, fmt.Errorf("invalid value: EPC[a0]: undefined value: 0x39"), a.SetAirFlowRate(ctx, AirFlowRateLevel(9)))
+	checkError(t, fmt.Errorf("invalid value: EPC[a1]: undefined value: 0x45"), a.SetAutoAirFlowDirection(ctx, AutoAirFlowDirectionValue(0x45)))
+	checkError(t, fmt.Errorf("invalid value: EPC[a4]: undefined value: 0x40"), a.SetAirFlowDirection(ctx, AirFlowDirectionValue(0x40)))
+}
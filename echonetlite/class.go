@@ -22,6 +22,11 @@ const (
 // Profile is definition of profile object class code
 const Profile ClassCode = 0xF0
 
+// definition of class codes for SensorGroup
+const (
+	TemperatureSensor ClassCode = 0x11
+)
+
 // definition of class codes for AirConditionerGroup
 const (
 	HomeAirConditioner ClassCode = 0x30
@@ -1,29 +1,103 @@
 package echonetlite
 
 import (
+	"embed"
 	"encoding/csv"
 	"encoding/hex"
+	"fmt"
 	"io"
-	"io/ioutil"
+	"io/fs"
 	"os"
+	"path"
 	"strings"
+	"sync"
 )
 
+// Languages of class dictionary
 const (
-	classInfoPath = "../../third-party/ECHONETLite-ObjectDatabase/data/csv/ja"
+	LangJA = "ja"
+	LangEN = "en"
 )
 
+//go:generate go run ../tools/classdata-gen -out classdata
+
+// classData is CSV files of ECHONETLite-ObjectDatabase bundled into the binary.
+// go generate replaces them with all CSV files in data/csv directory of the database (-src or EL_CLASS_DIR).
+// The committed files are device object super class, home air conditioner and low-voltage smart meter only,
+// so other classes are "unknown" unless the dictionary is loaded from the database with PrepareClassDictionary.
+//
+//go:embed classdata
+var classData embed.FS
+
 var (
 	// classDictionary is a map with ClassGroup, Class as key and ClassInfo as value
 	classDictionary ClassDictionary
 )
 
+var (
+	bundledCodecs     *CodecRegistry
+	bundledCodecsOnce sync.Once
+)
+
+// decodingCodecs returns codecs if PrepareClassDictionary has been called, otherwise CodecRegistry
+// with the bundled dictionary, so that properties are decoded without preparing the dictionary.
+func decodingCodecs() *CodecRegistry {
+	if codecs.hasDictionary() {
+		return codecs
+	}
+	bundledCodecsOnce.Do(func() {
+		bundledCodecs = NewCodecRegistry()
+		dict, err := LoadClassDictionary("", LangJA)
+		if err != nil {
+			logger.Println("[Error] failed to load bundled class dictionary:", err)
+			return
+		}
+		bundledCodecs.SetDictionary(dict)
+	})
+	return bundledCodecs
+}
+
 // PrepareClassDictionary prepares information about Echonet Lite classes
-func PrepareClassDictionary() error {
-	classDictionary, err := load(classInfoPath)
-	classDictionary.merge(loadNodeProfile(classInfoPath))
-	classDictionary.merge(loadControllerProfile())
-	return err
+// in the language (ja or en, ja if empty) from the bundled CSV files,
+// or from data/csv directory of ECHONETLite-ObjectDatabase if dir is not empty.
+func PrepareClassDictionary(dir, lang string) error {
+	dict, err := LoadClassDictionary(dir, lang)
+	if err != nil {
+		return err
+	}
+	classDictionary = dict
+	codecs.SetDictionary(dict)
+	return nil
+}
+
+// LoadClassDictionary loads ClassDictionary in the language (ja if empty)
+// from the bundled CSV files, or from dir which has ja and en directories if dir is not empty.
+func LoadClassDictionary(dir, lang string) (ClassDictionary, error) {
+	if lang == "" {
+		lang = LangJA
+	}
+	if lang != LangJA && lang != LangEN {
+		return nil, fmt.Errorf("unsupported language: %s", lang)
+	}
+
+	var fsys fs.FS
+	if dir == "" {
+		sub, err := fs.Sub(classData, "classdata")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	} else {
+		fsys = os.DirFS(dir)
+	}
+
+	dict, err := load(fsys, lang)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load class dictionary %s: %w", dir, err)
+	}
+	dict.merge(loadNodeProfile(fsys, lang))
+	dict.merge(loadControllerProfile(lang))
+	return dict, nil
 }
 
 // GetClassDictionary returns ClassDictionary
@@ -46,8 +120,23 @@ type PropertyDictionary map[PropertyCode]PropertyInfo
 
 // PropertyInfo is static information about property
 type PropertyInfo struct {
-	Code   PropertyCode
-	Detail string
+	Code             PropertyCode
+	Detail           string // Property name
+	Contents         string
+	ValueRange       string
+	Unit             string
+	DataType         string
+	DataSize         string
+	AccessRule       AccessRule
+	StatusChangeAnno string // Announcement at status change
+	Remark           string
+}
+
+// AccessRule is access rule of property for Anno, Set and Get
+type AccessRule struct {
+	Anno string
+	Set  string
+	Get  string
 }
 
 // NewClassDictionary returns ClassDictionary
@@ -64,6 +153,15 @@ func (dict ClassDictionary) get(g ClassGroupCode, c ClassCode) (ClassInfo, bool)
 	return ClassInfo{}, false
 }
 
+func (dict ClassDictionary) property(g ClassGroupCode, c ClassCode, epc PropertyCode) (PropertyInfo, bool) {
+	i, ok := dict.get(g, c)
+	if !ok {
+		return PropertyInfo{}, false
+	}
+	p, ok := i.Properties[epc]
+	return p, ok
+}
+
 func (dict ClassDictionary) add(g ClassGroupCode, c ClassCode, info ClassInfo) {
 	cm, ok := dict[g]
 	if !ok {
@@ -96,12 +194,12 @@ func (dict ClassDictionary) Get(g ClassGroupCode, c ClassCode) ClassInfo {
 
 // load loads class information from files SonyCSL provides
 // https://github.com/SonyCSL/ECHONETLite-ObjectDatabase
-func load(basePath string) (ClassDictionary, error) {
+func load(fsys fs.FS, lang string) (ClassDictionary, error) {
 
-	// There are files named in format 0xXXYY.csv (YY:class group code XX:class code)
+	// There are files named in format 0xXXYY.csv (XX:class group code YY:class code)
 	// DeviceList.csv
 	// and DeviceObject.csv
-	files, err := ioutil.ReadDir(basePath)
+	files, err := fs.ReadDir(fsys, lang)
 	if err != nil {
 		logger.Println(err)
 		return NewClassDictionary(), err
@@ -110,22 +208,18 @@ func load(basePath string) (ClassDictionary, error) {
 	classMap := NewClassDictionary()
 
 	for _, file := range files {
-		codes := classCode(file) // 0xXXYY.csv
-		if codes == nil {
+		codes := classCode(file.Name()) // 0xXXYY.csv
+		if len(codes) != 2 {
 			continue
 		}
-		logger.Println("Decoded class code", codes)
 
-		logger.Println(file)
-		logger.Println(basePath, file.Name())
-
-		properties := loadClassInfo(basePath + "/" + file.Name())
+		name, properties := loadClassInfo(fsys, path.Join(lang, file.Name()))
 		if properties != nil {
 			clsInfo := ClassInfo{
 				ClassGroup: ClassGroupCode(codes[0]),
 				Class:      ClassCode(codes[1]),
 				Properties: properties,
-				Desc:       "",
+				Desc:       name,
 			}
 			classMap.add(clsInfo.ClassGroup, clsInfo.Class, clsInfo)
 		}
@@ -134,44 +228,63 @@ func load(basePath string) (ClassDictionary, error) {
 	return classMap, nil
 }
 
-func loadNodeProfile(basePath string) ClassDictionary {
+// nodeProfileProperties are properties of node profile which are not in DeviceObject.csv
+var nodeProfileProperties = map[string][]PropertyInfo{
+	LangJA: {
+		{Code: NumOfInstances, Detail: "自ノードインスタンス数", DataType: "unsigned char×3", DataSize: "3"},
+		{Code: NumOfClasses, Detail: "自ノードクラス数", DataType: "unsigned short", DataSize: "2"},
+		{Code: InstanceListNotification, Detail: "インスタンスリスト通知", DataType: "unsigned char×(MAX)253", DataSize: "Max 253"},
+		{Code: InstanceListS, Detail: "自ノードインスタンスリストS", DataType: "unsigned char×(MAX)253", DataSize: "Max 253"},
+		{Code: ClassListS, Detail: "自ノードクラスリストS", DataType: "unsigned char×(MAX)17", DataSize: "Max 17"},
+	},
+	LangEN: {
+		{Code: NumOfInstances, Detail: "Number of self-node instances", DataType: "unsigned char×3", DataSize: "3"},
+		{Code: NumOfClasses, Detail: "Number of self-node classes", DataType: "unsigned short", DataSize: "2"},
+		{Code: InstanceListNotification, Detail: "Instance list notification", DataType: "unsigned char×(MAX)253", DataSize: "Max 253"},
+		{Code: InstanceListS, Detail: "Self-node instance list S", DataType: "unsigned char×(MAX)253", DataSize: "Max 253"},
+		{Code: ClassListS, Detail: "Self-node class list S", DataType: "unsigned char×(MAX)17", DataSize: "Max 17"},
+	},
+}
+
+var profileNames = map[string]struct{ node, controller string }{
+	LangJA: {node: "ノードプロファイル", controller: "コントローラ"},
+	LangEN: {node: "Node profile", controller: "Controller"},
+}
+
+func loadNodeProfile(fsys fs.FS, lang string) ClassDictionary {
 	classMap := NewClassDictionary()
 
-	properties := loadClassInfo(basePath + "/DeviceObject.csv")
+	_, properties := loadClassInfo(fsys, path.Join(lang, "DeviceObject.csv"))
 	if properties != nil {
-		properties[0xd3] = PropertyInfo{Code: 0xd3, Detail: "自ノードインスタンス数"}
-		properties[0xd4] = PropertyInfo{Code: 0xd4, Detail: "自ノードクラス数"}
-		properties[0xd5] = PropertyInfo{Code: 0xd5, Detail: "インスタンスリスト通知"}
-		properties[0xd6] = PropertyInfo{Code: 0xd6, Detail: "自ノードインスタンスリストS"}
-		properties[0xd7] = PropertyInfo{Code: 0xd7, Detail: "自ノードクラスリストS"}
+		for _, p := range nodeProfileProperties[lang] {
+			properties[p.Code] = p
+		}
 		clsInfo := ClassInfo{
 			ClassGroup: ClassGroupCode(0x0e),
 			Class:      ClassCode(0xf0),
 			Properties: properties,
-			Desc:       "ノードプロファイル",
+			Desc:       profileNames[lang].node,
 		}
-		logger.Println(clsInfo)
 		classMap.add(clsInfo.ClassGroup, clsInfo.Class, clsInfo)
 	}
 	return classMap
 }
 
-func loadControllerProfile() ClassDictionary {
+func loadControllerProfile(lang string) ClassDictionary {
 	classMap := NewClassDictionary()
 
 	clsInfo := ClassInfo{
 		ClassGroup: ClassGroupCode(0x05),
 		Class:      ClassCode(0xff),
 		Properties: PropertyDictionary{},
-		Desc:       "コントローラ",
+		Desc:       profileNames[lang].controller,
 	}
-	logger.Println(clsInfo)
 	classMap.add(clsInfo.ClassGroup, clsInfo.Class, clsInfo)
 	return classMap
 }
 
-func classCode(file os.FileInfo) []byte {
-	name := strings.Split(file.Name(), ".")[0]
+func classCode(fileName string) []byte {
+	name := strings.Split(fileName, ".")[0]
 
 	if !strings.HasPrefix(name, "0x") {
 		logger.Println("Not property file: ", name)
@@ -183,23 +296,28 @@ func classCode(file os.FileInfo) []byte {
 		logger.Println(err)
 		return nil
 	}
-	logger.Println(decodedClassCodes)
 	return decodedClassCodes
 }
 
-// loadPropertyInfo load PropertyInfo from file(0xXXYY.csv)
+// loadClassInfo loads class name and PropertyInfo from file(0xXXYY.csv)
 // which describes about property information for a Echonet Lite class
-func loadClassInfo(filePath string) PropertyDictionary {
-
-	properties := PropertyDictionary{}
-
-	f, err := os.Open(filePath)
+func loadClassInfo(fsys fs.FS, filePath string) (string, PropertyDictionary) {
+	f, err := fsys.Open(filePath)
 	if err != nil {
 		logger.Println("failed to open file:", err)
-		return properties
+		return "", nil
 	}
 	defer f.Close()
 
+	return readClassInfo(f)
+}
+
+// readClassInfo reads class name and PropertyInfo from csv
+func readClassInfo(in io.Reader) (string, PropertyDictionary) {
+
+	var name string
+	properties := PropertyDictionary{}
+
 	var epcBegan = false
 
 	// csv format
@@ -212,7 +330,9 @@ func loadClassInfo(filePath string) PropertyDictionary {
 	//   Line 7 Value: "0x80,Operation status,This property indicates the ON/OFF status.,"ON=0x30, OFF=0x31",.,unsigned char,1,-,optional,mandatory,mandatory,"
 	//   ...
 
-	r := csv.NewReader(f)
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	line := 0
 	for {
 		record, err := r.Read()
 		if err == io.EOF {
@@ -222,14 +342,17 @@ func loadClassInfo(filePath string) PropertyDictionary {
 			logger.Println(err)
 			continue
 		}
+		line++
 		if record[0] == "EPC" {
 			epcBegan = true
 			continue
 		}
 		if !epcBegan {
+			if line == 2 {
+				name = record[0]
+			}
 			continue
 		}
-		logger.Println(record[0])
 		if !strings.HasPrefix(record[0], "0x") {
 			continue
 		}
@@ -242,12 +365,30 @@ func loadClassInfo(filePath string) PropertyDictionary {
 			continue
 		}
 
+		column := func(i int) string {
+			if i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
 		p := PropertyInfo{
-			Code:   PropertyCode(d[0]),
-			Detail: record[1],
+			Code:       PropertyCode(d[0]),
+			Detail:     column(1),
+			Contents:   column(2),
+			ValueRange: column(3),
+			Unit:       column(4),
+			DataType:   column(5),
+			DataSize:   column(6),
+			AccessRule: AccessRule{
+				Anno: column(7),
+				Set:  column(8),
+				Get:  column(9),
+			},
+			StatusChangeAnno: column(10),
+			Remark:           column(11),
 		}
 		properties[PropertyCode(d[0])] = p
 	}
 
-	return properties
+	return name, properties
 }
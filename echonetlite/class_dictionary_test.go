@@ -1,6 +1,8 @@
 package echonetlite
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -197,3 +199,146 @@ func Test_ClassDictionary_add(t *testing.T) {
 	}
 
 }
+
+func Test_readClassInfo(t *testing.T) {
+	t.Parallel()
+
+	input := `Class name,Remarks,Group code,Class code,Whether or not detailed requirements are provided,,,,,,,
+Home air conditioner,,0x01,0x30,○,,,,,,,
+
+
+
+EPC,Property name,Contents of property,Value range(decimal notation),Unit,Data type,Data size,Access rule(Anno),Access rule(Set),Access rule(Get),Announcement at status change,Remark
+0xB0,Operation mode setting,Used to specify the operation mode.,"Automatic=0x41, Cooling=0x42",.,unsigned char,1,-,mandatory,mandatory,mandatory,
+0xBB,Measured value of room temperature,Measured value of room temperature,0x81～0x7D (-127～125℃),℃,signed char,1,-,-,optional,-
+invalid,,,,,,,,,,,
+`
+	want := PropertyDictionary{
+		0xb0: PropertyInfo{
+			Code:             0xb0,
+			Detail:           "Operation mode setting",
+			Contents:         "Used to specify the operation mode.",
+			ValueRange:       "Automatic=0x41, Cooling=0x42",
+			Unit:             ".",
+			DataType:         "unsigned char",
+			DataSize:         "1",
+			AccessRule:       AccessRule{Anno: "-", Set: "mandatory", Get: "mandatory"},
+			StatusChangeAnno: "mandatory",
+		},
+		0xbb: PropertyInfo{
+			Code:             0xbb,
+			Detail:           "Measured value of room temperature",
+			Contents:         "Measured value of room temperature",
+			ValueRange:       "0x81～0x7D (-127～125℃)",
+			Unit:             "℃",
+			DataType:         "signed char",
+			DataSize:         "1",
+			AccessRule:       AccessRule{Anno: "-", Set: "-", Get: "optional"},
+			StatusChangeAnno: "-",
+		},
+	}
+
+	name, got := readClassInfo(strings.NewReader(input))
+	if name != "Home air conditioner" {
+		t.Errorf("Diffrent result: want:%s, got:%s", "Home air conditioner", name)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("PropertyDictionary differs: (-want +got)\n%s", diff)
+	}
+}
+
+func TestLoadClassDictionary(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		lang     string
+		wantNode string
+		wantDesc string
+		wantEPC  string
+		err      error
+	}{
+		{name: "ja", lang: LangJA, wantNode: "ノードプロファイル", wantDesc: "家庭用エアコン", wantEPC: "室内温度計測値"},
+		{name: "en", lang: LangEN, wantNode: "Node profile", wantDesc: "Home air conditioner", wantEPC: "Measured value of room temperature"},
+		{name: "default", lang: "", wantNode: "ノードプロファイル", wantDesc: "家庭用エアコン", wantEPC: "室内温度計測値"},
+		{name: "unsupported", lang: "fr", err: fmt.Errorf("unsupported language: fr")},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dict, err := LoadClassDictionary("", tc.lang)
+			checkError(t, tc.err, err)
+			if err != nil {
+				return
+			}
+
+			if got := dict.Get(ProfileGroup, Profile).Desc; got != tc.wantNode {
+				t.Errorf("Diffrent result: want:%s, got:%s", tc.wantNode, got)
+			}
+			aircon := dict.Get(AirConditionerGroup, HomeAirConditioner)
+			if aircon.Desc != tc.wantDesc {
+				t.Errorf("Diffrent result: want:%s, got:%s", tc.wantDesc, aircon.Desc)
+			}
+			if got := aircon.Properties[MeasuredRoomTemperature].Detail; got != tc.wantEPC {
+				t.Errorf("Diffrent result: want:%s, got:%s", tc.wantEPC, got)
+			}
+			if _, ok := dict.property(HomeEquipmentGroup, LowVoltageSmartMeter, InstantPower); !ok {
+				t.Errorf("InstantPower of smart meter not found")
+			}
+			if _, ok := dict.property(ProfileGroup, Profile, InstanceListS); !ok {
+				t.Errorf("InstanceListS of node profile not found")
+			}
+		})
+	}
+
+	_, err := LoadClassDictionary("testdata/notfound", LangJA)
+	checkError(t, fmt.Errorf("failed to load class dictionary testdata/notfound: open ja: no such file or directory"), err)
+}
+
+func TestLoadClassDictionary_dir(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		lang     string
+		wantDesc string
+		wantEPC  string
+	}{
+		{name: "ja", lang: LangJA, wantDesc: "温度センサ", wantEPC: "温度計測値"},
+		{name: "en", lang: LangEN, wantDesc: "Temperature sensor", wantEPC: "Measured temperature value"},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// class out of the bundled CSV files is loaded from the directory
+			dict, err := LoadClassDictionary("testdata/classdata", tc.lang)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sensor := dict.Get(SensorGroup, TemperatureSensor)
+			if sensor.Desc != tc.wantDesc {
+				t.Errorf("Diffrent result: want:%s, got:%s", tc.wantDesc, sensor.Desc)
+			}
+			info := sensor.Properties[MeasuredTemperature]
+			if info.Detail != tc.wantEPC {
+				t.Errorf("Diffrent result: want:%s, got:%s", tc.wantEPC, info.Detail)
+			}
+			want := NumberCodec{Size: 2, Signed: true, Unit: "0.1℃", Min: -2732, Max: 32766}
+			if diff := cmp.Diff(want, info.Codec()); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+			if _, ok := dict.property(ProfileGroup, Profile, InstanceListS); !ok {
+				t.Errorf("InstanceListS of node profile not found")
+			}
+		})
+	}
+}
@@ -0,0 +1,530 @@
+package echonetlite
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec converts EDT of a property into typed value and back
+type Codec interface {
+	Decode(edt Data) (interface{}, error)
+	Encode(v interface{}) (Data, error)
+}
+
+// Number is decoded value of signed/unsigned integer property
+type Number struct {
+	Value int64
+	Unit  string
+}
+
+func (n Number) String() string {
+	if n.Unit == "" {
+		return strconv.FormatInt(n.Value, 10)
+	}
+	return fmt.Sprintf("%d %s", n.Value, n.Unit)
+}
+
+// Enum is decoded value of property which takes one of defined values
+type Enum struct {
+	Value uint64
+	Name  string
+}
+
+func (e Enum) String() string {
+	if e.Name == "" {
+		return fmt.Sprintf("0x%02x", e.Value)
+	}
+	return e.Name
+}
+
+// Bitmap is decoded value of bitmap property
+type Bitmap struct {
+	Value uint64
+	Size  int
+}
+
+func (b Bitmap) String() string {
+	return fmt.Sprintf("%0*b", b.Size*8, b.Value)
+}
+
+// ErrOutOfRange is returned for a number out of the value range of the property,
+// e.g. 0xFD of unsigned char or 0x7E of signed char which mean that the value is not available
+var ErrOutOfRange = errors.New("out of range")
+
+// NumberCodec is Codec for signed/unsigned integer
+// Min and Max are the value range of the property, which is not checked if both are 0.
+type NumberCodec struct {
+	Size     int
+	Signed   bool
+	Unit     string
+	Min, Max int64
+}
+
+// InRange returns true if n is in the value range
+func (c NumberCodec) InRange(n int64) bool {
+	if c.Min == 0 && c.Max == 0 {
+		return true
+	}
+	return n >= c.Min && n <= c.Max
+}
+
+// Decode decodes EDT into Number
+func (c NumberCodec) Decode(edt Data) (interface{}, error) {
+	v, err := decodeUint(edt, c.Size)
+	if err != nil {
+		return nil, err
+	}
+	return Number{Value: c.toInt64(v), Unit: c.Unit}, nil
+}
+
+// Encode encodes Number or integer into EDT
+func (c NumberCodec) Encode(v interface{}) (Data, error) {
+	if n, ok := v.(Number); ok {
+		v = n.Value
+	}
+	n, err := toInt64(v)
+	if err != nil {
+		return nil, err
+	}
+	bits := uint(8 * c.Size)
+	if c.Signed {
+		min, max := int64(-1)<<(bits-1), int64(1)<<(bits-1)-1
+		if n < min || n > max {
+			return nil, fmt.Errorf("%d out of range of signed %d bytes", n, c.Size)
+		}
+	} else if n < 0 || (bits < 64 && uint64(n) >= uint64(1)<<bits) {
+		return nil, fmt.Errorf("%d out of range of unsigned %d bytes", n, c.Size)
+	}
+	if !c.InRange(n) {
+		return nil, fmt.Errorf("%d %w %d～%d", n, ErrOutOfRange, c.Min, c.Max)
+	}
+	return encodeUint(uint64(n), c.Size), nil
+}
+
+// EnumCodec is Codec for property which takes one of defined values or ranges of values
+type EnumCodec struct {
+	Size   int
+	Values map[uint64]string
+	Ranges []EnumRange
+}
+
+// EnumRange is range of values with a name, e.g. "Air flow rate=0x31～0x38"
+type EnumRange struct {
+	Lo, Hi uint64
+	Name   string
+}
+
+// name returns name of v in the range, e.g. "Air flow rate(0x33)"
+func (r EnumRange) name(v uint64) string {
+	return fmt.Sprintf("%s(0x%02x)", r.Name, v)
+}
+
+// lookup returns name of v, and false if v is undefined
+func (c EnumCodec) lookup(v uint64) (string, bool) {
+	if name, ok := c.Values[v]; ok {
+		return name, true
+	}
+	for _, r := range c.Ranges {
+		if v >= r.Lo && v <= r.Hi {
+			return r.name(v), true
+		}
+	}
+	return "", false
+}
+
+// Decode decodes EDT into Enum. Name is empty for undefined value.
+func (c EnumCodec) Decode(edt Data) (interface{}, error) {
+	v, err := decodeUint(edt, c.Size)
+	if err != nil {
+		return nil, err
+	}
+	name, _ := c.lookup(v)
+	return Enum{Value: v, Name: name}, nil
+}
+
+// Encode encodes Enum, name of value or integer into EDT
+func (c EnumCodec) Encode(v interface{}) (Data, error) {
+	switch e := v.(type) {
+	case Enum:
+		v = e.Value
+	case string:
+		for value, name := range c.Values {
+			if name == e {
+				return encodeUint(value, c.Size), nil
+			}
+		}
+		for _, r := range c.Ranges {
+			hex := strings.TrimPrefix(e, r.Name+"(0x")
+			if hex == e || !strings.HasSuffix(hex, ")") {
+				continue
+			}
+			value, err := strconv.ParseUint(strings.TrimSuffix(hex, ")"), 16, 64)
+			if err == nil && value >= r.Lo && value <= r.Hi {
+				return encodeUint(value, c.Size), nil
+			}
+		}
+		return nil, fmt.Errorf("undefined value: %s", e)
+	}
+	n, err := toInt64(v)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := c.lookup(uint64(n)); !ok {
+		return nil, fmt.Errorf("undefined value: 0x%02x", n)
+	}
+	return encodeUint(uint64(n), c.Size), nil
+}
+
+// BitmapCodec is Codec for bitmap
+type BitmapCodec struct {
+	Size int
+}
+
+// Decode decodes EDT into Bitmap
+func (c BitmapCodec) Decode(edt Data) (interface{}, error) {
+	v, err := decodeUint(edt, c.Size)
+	if err != nil {
+		return nil, err
+	}
+	return Bitmap{Value: v, Size: c.Size}, nil
+}
+
+// Encode encodes Bitmap or integer into EDT
+func (c BitmapCodec) Encode(v interface{}) (Data, error) {
+	if b, ok := v.(Bitmap); ok {
+		v = b.Value
+	}
+	n, err := toInt64(v)
+	if err != nil {
+		return nil, err
+	}
+	return encodeUint(uint64(n), c.Size), nil
+}
+
+// RawCodec is Codec which passes EDT through as Data
+type RawCodec struct{}
+
+// Decode returns copy of EDT
+func (RawCodec) Decode(edt Data) (interface{}, error) {
+	return append(Data{}, edt...), nil
+}
+
+// Encode encodes Data or []byte into EDT
+func (RawCodec) Encode(v interface{}) (Data, error) {
+	switch d := v.(type) {
+	case Data:
+		return append(Data{}, d...), nil
+	case []byte:
+		return append(Data{}, d...), nil
+	}
+	return nil, fmt.Errorf("unsupported type: %T", v)
+}
+
+// PropertyMapCodec is Codec for property maps (0x9B-0x9F)
+type PropertyMapCodec struct{}
+
+// Decode decodes EDT into PropertyMap
+func (PropertyMapCodec) Decode(edt Data) (interface{}, error) {
+	return ParsePropertyMap(edt)
+}
+
+// Encode encodes PropertyMap into EDT
+func (PropertyMapCodec) Encode(v interface{}) (Data, error) {
+	m, ok := v.(PropertyMap)
+	if !ok {
+		return nil, fmt.Errorf("unsupported type: %T", v)
+	}
+	return m.Encode(), nil
+}
+
+func decodeUint(edt Data, size int) (uint64, error) {
+	if len(edt) != size {
+		return 0, fmt.Errorf("invalid EDT length: %d, expected %d", len(edt), size)
+	}
+	var v uint64
+	for _, b := range edt {
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+func encodeUint(v uint64, size int) Data {
+	edt := make(Data, size)
+	for i := size - 1; i >= 0; i-- {
+		edt[i] = byte(v)
+		v >>= 8
+	}
+	return edt
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int8:
+		return int64(n), nil
+	case int16:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint:
+		return int64(n), nil
+	case uint8:
+		return int64(n), nil
+	case uint16:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	case uint64:
+		return int64(n), nil
+	}
+	return 0, fmt.Errorf("unsupported type: %T", v)
+}
+
+// enumValuePattern matches a definition of value in value range column like "ON=0x30"
+var enumValuePattern = regexp.MustCompile(`([^,，、=＝]+?)\s*[=＝]\s*0x([0-9A-Fa-f]+)`)
+
+// numberRangePattern matches value range column of number like "0x00～0x32 (0～50℃)"
+var numberRangePattern = regexp.MustCompile(`^0x([0-9A-Fa-f]+)\s*[～~]\s*0x([0-9A-Fa-f]+)`)
+
+// enumRangePattern matches the end of a definition of range which follows enumValuePattern like "～0x38"
+var enumRangePattern = regexp.MustCompile(`^[～~]\s*0x([0-9A-Fa-f]+)`)
+
+// Codec returns Codec derived from data type, size, unit and value range of the property
+func (info PropertyInfo) Codec() Codec {
+	dataType := strings.ToLower(info.DataType)
+	size, err := strconv.Atoi(strings.TrimSpace(info.DataSize))
+	if err != nil {
+		size = 0
+	}
+
+	if strings.Contains(dataType, "bitmap") {
+		if size < 1 || size > 8 {
+			return RawCodec{}
+		}
+		return BitmapCodec{Size: size}
+	}
+
+	var typeSize int
+	switch {
+	case strings.HasSuffix(dataType, "signed char"):
+		typeSize = 1
+	case strings.HasSuffix(dataType, "signed short"):
+		typeSize = 2
+	case strings.HasSuffix(dataType, "signed long"):
+		typeSize = 4
+	default:
+		// arrays, strings and structures
+		return RawCodec{}
+	}
+	if size == 0 {
+		size = typeSize
+	}
+	if size != typeSize {
+		return RawCodec{}
+	}
+
+	values := map[uint64]string{}
+	var ranges []EnumRange
+	for _, m := range enumValuePattern.FindAllStringSubmatchIndex(info.ValueRange, -1) {
+		v, err := strconv.ParseUint(info.ValueRange[m[4]:m[5]], 16, 64)
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSpace(info.ValueRange[m[2]:m[3]])
+		rest := strings.TrimSpace(info.ValueRange[m[1]:])
+		if r := enumRangePattern.FindStringSubmatch(rest); r != nil {
+			// range of values like "Air flow rate=0x31～0x38"
+			hi, err := strconv.ParseUint(r[1], 16, 64)
+			if err == nil && hi >= v {
+				ranges = append(ranges, EnumRange{Lo: v, Hi: hi, Name: name})
+			}
+			continue
+		}
+		values[v] = name
+	}
+	if len(values) > 0 || len(ranges) > 0 {
+		return EnumCodec{Size: size, Values: values, Ranges: ranges}
+	}
+
+	codec := NumberCodec{
+		Size:   size,
+		Signed: !strings.HasPrefix(dataType, "unsigned"),
+		Unit:   info.unit(),
+	}
+	if m := numberRangePattern.FindStringSubmatch(strings.TrimSpace(info.ValueRange)); m != nil {
+		lo, err1 := strconv.ParseUint(m[1], 16, 64)
+		hi, err2 := strconv.ParseUint(m[2], 16, 64)
+		if err1 == nil && err2 == nil {
+			codec.Min, codec.Max = codec.toInt64(lo), codec.toInt64(hi)
+		}
+	}
+	return codec
+}
+
+// toInt64 converts v in the size to int64 with sign extension if signed
+func (c NumberCodec) toInt64(v uint64) int64 {
+	n := int64(v)
+	if c.Signed {
+		shift := uint(64 - 8*c.Size)
+		n = int64(v<<shift) >> shift
+	}
+	return n
+}
+
+func (info PropertyInfo) unit() string {
+	switch u := strings.TrimSpace(info.Unit); u {
+	case "", ".", "-", "－", "—":
+		return ""
+	default:
+		return u
+	}
+}
+
+// CodecRegistry holds Codec for each property of classes
+// Codec is looked up in order of registered one for the class, registered one for all classes
+// and derived one from PropertyInfo of the class or the super class in ClassDictionary.
+type CodecRegistry struct {
+	mu     sync.RWMutex
+	dict   ClassDictionary
+	codecs map[codecKey]Codec
+}
+
+type codecKey struct {
+	classGroup ClassGroupCode
+	class      ClassCode
+	epc        PropertyCode
+}
+
+// anyClass is used as key of codecs registered for all classes
+const anyClass = ClassCode(0x00)
+
+var codecs = NewCodecRegistry()
+
+// GetCodecRegistry returns CodecRegistry used to format properties
+func GetCodecRegistry() *CodecRegistry {
+	return codecs
+}
+
+// NewCodecRegistry returns CodecRegistry with codecs for property maps
+func NewCodecRegistry() *CodecRegistry {
+	r := &CodecRegistry{codecs: map[codecKey]Codec{}}
+	for _, epc := range []PropertyCode{SetMPropertyMap, GetMPropertyMap, StageChangeAnnouncePropertyMap, SetPropertyMap, GetPropertyMap} {
+		r.Register(0x00, anyClass, epc, PropertyMapCodec{})
+	}
+	return r
+}
+
+// SetDictionary sets ClassDictionary from which codecs are derived
+func (r *CodecRegistry) SetDictionary(dict ClassDictionary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dict = dict
+}
+
+func (r *CodecRegistry) hasDictionary() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.dict != nil
+}
+
+// Register registers Codec for the property of the class
+// Class group 0x00 and class 0x00 registers it for all classes.
+func (r *CodecRegistry) Register(g ClassGroupCode, c ClassCode, epc PropertyCode, codec Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codecs[codecKey{g, c, epc}] = codec
+}
+
+// Lookup returns Codec and PropertyInfo for the property of the object
+func (r *CodecRegistry) Lookup(obj Object, epc PropertyCode) (Codec, PropertyInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	info, found := r.dict.property(obj.ClassGroup, obj.Class, epc)
+	if !found && epc < 0xA0 {
+		// Properties of the super class are loaded as ones of node profile
+		info, found = r.dict.property(ProfileGroup, Profile, epc)
+	}
+
+	if codec, ok := r.codecs[codecKey{obj.ClassGroup, obj.Class, epc}]; ok {
+		return codec, info, true
+	}
+	if codec, ok := r.codecs[codecKey{0x00, anyClass, epc}]; ok {
+		return codec, info, true
+	}
+	if found {
+		return info.Codec(), info, true
+	}
+	return nil, info, false
+}
+
+// Decode decodes EDT of the property of the object
+func (r *CodecRegistry) Decode(obj Object, p Property) (interface{}, error) {
+	codec, _, ok := r.Lookup(obj, PropertyCode(p.Code))
+	if !ok {
+		return nil, fmt.Errorf("no codec for EPC[%02x] of %s", p.Code, obj)
+	}
+	return codec.Decode(p.Data)
+}
+
+// DecodeNumber decodes EDT of the property of the object into Number
+// It returns ErrOutOfRange with the number if it is out of the value range of the property.
+func (r *CodecRegistry) DecodeNumber(obj Object, p Property) (Number, error) {
+	codec, _, ok := r.Lookup(obj, PropertyCode(p.Code))
+	if !ok {
+		return Number{}, fmt.Errorf("no codec for EPC[%02x] of %s", p.Code, obj)
+	}
+	v, err := codec.Decode(p.Data)
+	if err != nil {
+		return Number{}, err
+	}
+	n, ok := v.(Number)
+	if !ok {
+		return Number{}, fmt.Errorf("EPC[%02x] of %s is not a number: %s", p.Code, obj, v)
+	}
+	if c, ok := codec.(NumberCodec); ok && !c.InRange(n.Value) {
+		return n, fmt.Errorf("EPC[%02x] of %s: %d %w %d～%d", p.Code, obj, n.Value, ErrOutOfRange, c.Min, c.Max)
+	}
+	return n, nil
+}
+
+// Encode returns property of the object whose EDT is encoded from v
+func (r *CodecRegistry) Encode(obj Object, epc PropertyCode, v interface{}) (Property, error) {
+	codec, _, ok := r.Lookup(obj, epc)
+	if !ok {
+		return Property{}, fmt.Errorf("no codec for EPC[%02x] of %s", byte(epc), obj)
+	}
+	edt, err := codec.Encode(v)
+	if err != nil {
+		return Property{}, fmt.Errorf("EPC[%02x]: %w", byte(epc), err)
+	}
+	return Property{Code: byte(epc), Len: len(edt), Data: edt}, nil
+}
+
+// Format returns string representation of the property with name and decoded value
+func (r *CodecRegistry) Format(obj Object, p Property) string {
+	str := fmt.Sprintf("EPC[%x]", p.Code)
+	codec, info, ok := r.Lookup(obj, PropertyCode(p.Code))
+	if info.Detail != "" {
+		str += "(" + info.Detail + ")"
+	}
+	str += fmt.Sprintf(" PDC[%d] EDT[%s]", p.Len, p.Data)
+	if !ok || len(p.Data) == 0 {
+		return str
+	}
+	if _, raw := codec.(RawCodec); raw {
+		return str
+	}
+	v, err := codec.Decode(p.Data)
+	if err != nil {
+		return str
+	}
+	return str + fmt.Sprintf("(%s)", v)
+}
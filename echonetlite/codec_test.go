@@ -0,0 +1,403 @@
+package echonetlite
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPropertyInfo_Codec(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name  string
+		input PropertyInfo
+		want  Codec
+	}{
+		{
+			name:  "enum",
+			input: PropertyInfo{ValueRange: "ON=0x30, OFF=0x31", Unit: ".", DataType: "unsigned char", DataSize: "1"},
+			want:  EnumCodec{Size: 1, Values: map[uint64]string{0x30: "ON", 0x31: "OFF"}},
+		},
+		{
+			name:  "enum ja",
+			input: PropertyInfo{ValueRange: "自動＝0x41，冷房＝0x42", DataType: "unsigned char", DataSize: "1"},
+			want:  EnumCodec{Size: 1, Values: map[uint64]string{0x41: "自動", 0x42: "冷房"}},
+		},
+		{
+			name:  "enum with range",
+			input: PropertyInfo{ValueRange: "Automatic air flow rate control=0x41, Air flow rate=0x31～0x38", DataType: "unsigned char", DataSize: "1"},
+			want: EnumCodec{
+				Size:   1,
+				Values: map[uint64]string{0x41: "Automatic air flow rate control"},
+				Ranges: []EnumRange{{Lo: 0x31, Hi: 0x38, Name: "Air flow rate"}},
+			},
+		},
+		{
+			name:  "signed char",
+			input: PropertyInfo{ValueRange: "0x81～0x7D (-127～125℃)", Unit: "℃", DataType: "signed char", DataSize: "1"},
+			want:  NumberCodec{Size: 1, Signed: true, Unit: "℃", Min: -127, Max: 125},
+		},
+		{
+			name:  "unsigned long",
+			input: PropertyInfo{ValueRange: "0x00000000～0x3B9AC9FF", Unit: "kWh", DataType: "unsigned long", DataSize: "4"},
+			want:  NumberCodec{Size: 4, Unit: "kWh", Max: 999999999},
+		},
+		{
+			name:  "size missing",
+			input: PropertyInfo{DataType: "unsigned short", Unit: "-"},
+			want:  NumberCodec{Size: 2},
+		},
+		{
+			name:  "bitmap",
+			input: PropertyInfo{DataType: "bitmap", DataSize: "2"},
+			want:  BitmapCodec{Size: 2},
+		},
+		{
+			name:  "array",
+			input: PropertyInfo{DataType: "unsigned char×3", DataSize: "3"},
+			want:  RawCodec{},
+		},
+		{
+			name:  "size mismatch",
+			input: PropertyInfo{DataType: "unsigned char", DataSize: "Max 17"},
+			want:  NumberCodec{Size: 1},
+		},
+		{
+			name:  "unknown",
+			input: PropertyInfo{},
+			want:  RawCodec{},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := tc.input.Codec()
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Codec differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCodec_Decode(t *testing.T) {
+	t.Parallel()
+
+	enum := EnumCodec{Size: 1, Values: map[uint64]string{0x30: "ON", 0x31: "OFF"}}
+	// air flow rate setting (0xA0) of home air conditioner
+	airFlow := EnumCodec{
+		Size:   1,
+		Values: map[uint64]string{0x41: "Automatic air flow rate control"},
+		Ranges: []EnumRange{{Lo: 0x31, Hi: 0x38, Name: "Air flow rate"}},
+	}
+
+	testcases := []struct {
+		name   string
+		codec  Codec
+		input  Data
+		want   interface{}
+		str    string
+		err    error
+		encode interface{}
+	}{
+		{
+			name:  "signed char",
+			codec: NumberCodec{Size: 1, Signed: true, Unit: "℃"},
+			input: Data{0x1b},
+			want:  Number{Value: 27, Unit: "℃"},
+			str:   "27 ℃",
+		},
+		{
+			name:  "negative signed char",
+			codec: NumberCodec{Size: 1, Signed: true, Unit: "℃"},
+			input: Data{0xfb},
+			want:  Number{Value: -5, Unit: "℃"},
+			str:   "-5 ℃",
+		},
+		{
+			name:  "signed long",
+			codec: NumberCodec{Size: 4, Signed: true, Unit: "W"},
+			input: Data{0xff, 0xff, 0xff, 0x9c},
+			want:  Number{Value: -100, Unit: "W"},
+			str:   "-100 W",
+		},
+		{
+			name:  "unsigned long",
+			codec: NumberCodec{Size: 4},
+			input: Data{0x00, 0x01, 0x00, 0x00},
+			want:  Number{Value: 65536},
+			str:   "65536",
+		},
+		{
+			name:  "invalid length",
+			codec: NumberCodec{Size: 2},
+			input: Data{0x00},
+			err:   fmt.Errorf("invalid EDT length: 1, expected 2"),
+		},
+		{
+			name:  "enum",
+			codec: enum,
+			input: Data{0x30},
+			want:  Enum{Value: 0x30, Name: "ON"},
+			str:   "ON",
+		},
+		{
+			name:   "undefined enum",
+			codec:  enum,
+			input:  Data{0x41},
+			want:   Enum{Value: 0x41},
+			str:    "0x41",
+			encode: Enum{Value: 0x30, Name: "ON"},
+		},
+		{
+			name:  "enum in range",
+			codec: airFlow,
+			input: Data{0x33},
+			want:  Enum{Value: 0x33, Name: "Air flow rate(0x33)"},
+			str:   "Air flow rate(0x33)",
+		},
+		{
+			name:  "enum value with range",
+			codec: airFlow,
+			input: Data{0x41},
+			want:  Enum{Value: 0x41, Name: "Automatic air flow rate control"},
+			str:   "Automatic air flow rate control",
+		},
+		{
+			name:   "enum out of range",
+			codec:  airFlow,
+			input:  Data{0x39},
+			want:   Enum{Value: 0x39},
+			str:    "0x39",
+			encode: Enum{Value: 0x41, Name: "Automatic air flow rate control"},
+		},
+		{
+			name:  "bitmap",
+			codec: BitmapCodec{Size: 1},
+			input: Data{0x05},
+			want:  Bitmap{Value: 0x05, Size: 1},
+			str:   "00000101",
+		},
+		{
+			name:  "property map",
+			codec: PropertyMapCodec{},
+			input: Data{0x02, 0x80, 0x9f},
+			want:  NewPropertyMap(0x80, 0x9f),
+			str:   "[80 9f]",
+		},
+		{
+			name:  "raw",
+			codec: RawCodec{},
+			input: Data{0x01, 0x02},
+			want:  Data{0x01, 0x02},
+			str:   "0102",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.codec.Decode(tc.input)
+			checkError(t, tc.err, err)
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Decoded value differs: (-want +got)\n%s", diff)
+			}
+			if str := fmt.Sprint(got); str != tc.str {
+				t.Errorf("Diffrent result: want:%s, got:%s", tc.str, str)
+			}
+
+			if tc.encode != nil {
+				// undefined values can not be encoded
+				if _, err := tc.codec.Encode(got); err == nil {
+					t.Errorf("Encode(%v) succeeded", got)
+				}
+				return
+			}
+			edt, err := tc.codec.Encode(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.input, edt); diff != "" {
+				t.Errorf("Encoded EDT differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCodec_Encode(t *testing.T) {
+	t.Parallel()
+
+	enum := EnumCodec{Size: 1, Values: map[uint64]string{0x30: "ON", 0x31: "OFF"}}
+	// air flow rate setting (0xA0) of home air conditioner
+	airFlow := EnumCodec{
+		Size:   1,
+		Values: map[uint64]string{0x41: "Automatic air flow rate control"},
+		Ranges: []EnumRange{{Lo: 0x31, Hi: 0x38, Name: "Air flow rate"}},
+	}
+
+	testcases := []struct {
+		name  string
+		codec Codec
+		input interface{}
+		want  Data
+		err   error
+	}{
+		{name: "int", codec: NumberCodec{Size: 2, Signed: true}, input: -2, want: Data{0xff, 0xfe}},
+		{name: "signed overflow", codec: NumberCodec{Size: 1, Signed: true}, input: 128, err: fmt.Errorf("128 out of range of signed 1 bytes")},
+		{name: "unsigned overflow", codec: NumberCodec{Size: 1}, input: 256, err: fmt.Errorf("256 out of range of unsigned 1 bytes")},
+		{name: "negative unsigned", codec: NumberCodec{Size: 1}, input: -1, err: fmt.Errorf("-1 out of range of unsigned 1 bytes")},
+		{name: "unsupported type", codec: NumberCodec{Size: 1}, input: "1", err: fmt.Errorf("unsupported type: string")},
+		{name: "in range", codec: NumberCodec{Size: 1, Max: 50}, input: 50, want: Data{0x32}},
+		{name: "out of range", codec: NumberCodec{Size: 1, Max: 50}, input: 0xfd, err: fmt.Errorf("253 out of range 0～50")},
+		{name: "enum name", codec: enum, input: "OFF", want: Data{0x31}},
+		{name: "enum value", codec: enum, input: 0x30, want: Data{0x30}},
+		{name: "undefined enum name", codec: enum, input: "AUTO", err: fmt.Errorf("undefined value: AUTO")},
+		{name: "enum range lower bound", codec: airFlow, input: 0x31, want: Data{0x31}},
+		{name: "enum range upper bound", codec: airFlow, input: 0x38, want: Data{0x38}},
+		{name: "enum range name", codec: airFlow, input: "Air flow rate(0x35)", want: Data{0x35}},
+		{name: "enum range name out of range", codec: airFlow, input: "Air flow rate(0x39)", err: fmt.Errorf("undefined value: Air flow rate(0x39)")},
+		{name: "enum out of range", codec: airFlow, input: 0x39, err: fmt.Errorf("undefined value: 0x39")},
+		{name: "bitmap", codec: BitmapCodec{Size: 2}, input: Bitmap{Value: 0x0102, Size: 2}, want: Data{0x01, 0x02}},
+		{name: "raw", codec: RawCodec{}, input: []byte{0x01}, want: Data{0x01}},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.codec.Encode(tc.input)
+			checkError(t, tc.err, err)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("EDT differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCodecRegistry(t *testing.T) {
+	t.Parallel()
+
+	aircon := NewObject(AirConditionerGroup, HomeAirConditioner, 0x01)
+	dict := ClassDictionary{
+		ProfileGroup: map[ClassCode]ClassInfo{
+			Profile: {ProfileGroup, Profile, PropertyDictionary{
+				0x80: PropertyInfo{Code: 0x80, Detail: "動作状態", ValueRange: "ON=0x30, OFF=0x31", DataType: "unsigned char", DataSize: "1"},
+				0x9f: PropertyInfo{Code: 0x9f, Detail: "Getプロパティマップ", DataType: "unsigned char×(MAX17)"},
+			}, "ノードプロファイル"},
+		},
+		AirConditionerGroup: map[ClassCode]ClassInfo{
+			HomeAirConditioner: {AirConditionerGroup, HomeAirConditioner, PropertyDictionary{
+				0xbb: PropertyInfo{Code: 0xbb, Detail: "室内温度計測値", ValueRange: "0x81～0x7D (-127～125℃)", Unit: "℃", DataType: "signed char", DataSize: "1"},
+				0xb3: PropertyInfo{Code: 0xb3, Detail: "温度設定値", Unit: "℃", DataType: "unsigned char", DataSize: "1"},
+			}, "家庭用エアコン"},
+		},
+	}
+	r := NewCodecRegistry()
+	r.SetDictionary(dict)
+	r.Register(AirConditionerGroup, HomeAirConditioner, 0xb3, NumberCodec{Size: 1, Unit: "°C"})
+
+	testcases := []struct {
+		name  string
+		obj   Object
+		input Property
+		want  string
+	}{
+		{
+			name:  "class property",
+			obj:   aircon,
+			input: Property{Code: 0xbb, Len: 1, Data: Data{0x1b}},
+			want:  "EPC[bb](室内温度計測値) PDC[1] EDT[1b](27 ℃)",
+		},
+		{
+			name:  "registered codec",
+			obj:   aircon,
+			input: Property{Code: 0xb3, Len: 1, Data: Data{0x19}},
+			want:  "EPC[b3](温度設定値) PDC[1] EDT[19](25 °C)",
+		},
+		{
+			name:  "super class property",
+			obj:   aircon,
+			input: Property{Code: 0x80, Len: 1, Data: Data{0x30}},
+			want:  "EPC[80](動作状態) PDC[1] EDT[30](ON)",
+		},
+		{
+			name:  "property map",
+			obj:   aircon,
+			input: Property{Code: 0x9f, Len: 3, Data: Data{0x02, 0x80, 0xbb}},
+			want:  "EPC[9f](Getプロパティマップ) PDC[3] EDT[0280bb]([80 bb])",
+		},
+		{
+			name:  "unknown class",
+			obj:   NewObject(0x02, 0x88, 0x01),
+			input: Property{Code: 0xe7, Len: 4, Data: Data{0x00, 0x00, 0x01, 0x00}},
+			want:  "EPC[e7] PDC[4] EDT[00000100]",
+		},
+		{
+			name:  "invalid EDT",
+			obj:   aircon,
+			input: Property{Code: 0xbb, Len: 2, Data: Data{0x00, 0x1b}},
+			want:  "EPC[bb](室内温度計測値) PDC[2] EDT[001b]",
+		},
+		{
+			name:  "request",
+			obj:   aircon,
+			input: Property{Code: 0xbb},
+			want:  "EPC[bb](室内温度計測値) PDC[0] EDT[]",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := r.Format(tc.obj, tc.input)
+			if got != tc.want {
+				t.Errorf("Diffrent result: want:%s, got:%s", tc.want, got)
+			}
+		})
+	}
+
+	p, err := r.Encode(aircon, 0x80, "OFF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(Property{Code: 0x80, Len: 1, Data: Data{0x31}}, p); diff != "" {
+		t.Errorf("Encoded property differs: (-want +got)\n%s", diff)
+	}
+
+	_, err = r.Encode(aircon, 0xbb, 200)
+	checkError(t, fmt.Errorf("EPC[bb]: 200 out of range of signed 1 bytes"), err)
+
+	n, err := r.DecodeNumber(aircon, Property{Code: 0xbb, Len: 1, Data: Data{0xfb}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(Number{Value: -5, Unit: "℃"}, n); diff != "" {
+		t.Errorf("Decoded number differs: (-want +got)\n%s", diff)
+	}
+
+	// 0x7E is out of the value range, which means unmeasurable
+	_, err = r.DecodeNumber(aircon, Property{Code: 0xbb, Len: 1, Data: Data{0x7e}})
+	if !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Diffrent result: want:%v, got:%v", ErrOutOfRange, err)
+	}
+
+	_, err = r.Decode(NewObject(0x02, 0x88, 0x01), Property{Code: 0xe7})
+	checkError(t, fmt.Errorf("no codec for EPC[e7] of 02 88 01"), err)
+}
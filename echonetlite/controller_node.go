@@ -2,9 +2,11 @@ package echonetlite
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -41,72 +43,110 @@ const (
 	MulticastIP = "224.0.23.0"
 	// Port is Echonet-Lite receive port
 	Port = ":3610"
+
+	// DefaultNodeTTL is default duration to keep nodes not seen
+	DefaultNodeTTL = 10 * time.Minute
 )
 
+// ArbitraryFrameHandler handles frames in arbitrary message format received from addr
+type ArbitraryFrameHandler func(addr string, f Frame)
+
 // ControllerNode is ECHONETLite controller
 type ControllerNode struct {
 	MulticastReceiver transport.MulticastReceiver
 	UnicastReceiver   transport.UnicastReceiver
 	MulticastSender   transport.MulticastSender
+	UnicastSender     transport.UnicastSender // sends requests to a single node, MulticastSender is used if nil
+	MulticastGroup    string                  // group to receive multicast, MulticastIP if empty
+	ArbitraryHandler  ArbitraryFrameHandler   // called for frames with EHD2 = 0x82, dropped if nil
+	RequestTimeout    time.Duration           // timeout for each attempt of a request, DefaultRequestTimeout if 0
+	RequestRetries    int                     // num of retries after the first attempt times out, DefaultRequestRetries if 0, none if negative (NoRequestRetries)
+	NodeTTL           time.Duration           // nodes not seen for this duration are evicted, DefaultNodeTTL if 0
 	tid               uint16
 	nodeList          NodeList
+	mu                sync.Mutex
+	pending           map[transactionKey]transaction
+	wg                sync.WaitGroup // goroutines of the controller
 }
 
 // NewControllerNode returns ControllerNode
 func NewControllerNode() (*ControllerNode, error) {
-	ms, err := transport.NewUDPMulticastSender(MulticastIP, Port)
+	return NewControllerNodeOn(NetworkConfig{})
+}
+
+// NewControllerNodeOn returns ControllerNode on the network of cfg
+func NewControllerNodeOn(cfg NetworkConfig) (*ControllerNode, error) {
+	t, err := openUDPTransports(cfg)
 	if err != nil {
 		log.Println(err)
 		return &ControllerNode{}, err
 	}
 	return &ControllerNode{
-		MulticastReceiver: &transport.UDPMulticastReceiver{},
-		MulticastSender:   ms,
-		UnicastReceiver:   &transport.UDPUnicastReceiver{},
+		MulticastReceiver: t.multicastReceiver,
+		MulticastSender:   t.multicastSender,
+		UnicastReceiver:   t.unicastReceiver,
+		UnicastSender:     t.unicastSender,
+		MulticastGroup:    t.group,
 	}, nil
 }
 
-// Close closes all resources open
-func (elc ControllerNode) Close() {
+// Close closes senders. Receivers are closed when ctx given to Start or Run is done.
+func (elc *ControllerNode) Close() {
 	elc.MulticastSender.Close()
-}
-
-// NodeList is list of node profile objects
-type NodeList map[string]Node
-
-// Add adds Node
-func (nlist *NodeList) Add(addr string, obj Object) {
-	if _, ok := (*nlist)[addr]; !ok {
-		(*nlist)[addr] = Node{}
+	if elc.UnicastSender != nil {
+		elc.UnicastSender.Close()
 	}
 }
 
-// Node represents a node profile object
-type Node struct {
-	Devices []Object
-}
-
-// Start starts controller
-func (elc ControllerNode) Start(ctx context.Context) {
+// Start starts controller. Its goroutines run until ctx is done.
+func (elc *ControllerNode) Start(ctx context.Context) {
+	elc.mu.Lock()
 	elc.tid = 0
 	elc.nodeList = make(NodeList)
+	elc.mu.Unlock()
 
 	sch := elc.UnicastReceiver.Start(ctx, Port)
-	go elc.handleUnicastResult(ctx, sch)
+	elc.goroutine(func() { elc.handleUnicastResult(ctx, sch) })
 
-	mch := elc.MulticastReceiver.Start(ctx, MulticastIP, Port)
-	go elc.handleMulticastResult(ctx, mch)
+	mch := elc.MulticastReceiver.Start(ctx, multicastGroup(elc.MulticastGroup), Port)
+	elc.goroutine(func() { elc.handleMulticastResult(ctx, mch) })
 
 	elc.startSequence(ctx)
+
+	elc.goroutine(func() { elc.maintainNodes(ctx) })
 }
 
-func (elc ControllerNode) handleMulticastResult(ctx context.Context, results <-chan transport.ReceiveResult) {
+// Run starts controller, and returns when ctx is done and all of its goroutines have exited.
+// If a receiver stops with error, it stops the controller and returns the error.
+func (elc *ControllerNode) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	elc.Start(ctx)
+	err := waitReceivers(cancel, elc.UnicastReceiver, elc.MulticastReceiver)
+	elc.wg.Wait()
+	return err
+}
+
+// goroutine runs f in a goroutine which Run waits for
+func (elc *ControllerNode) goroutine(f func()) {
+	elc.wg.Add(1)
+	go func() {
+		defer elc.wg.Done()
+		f()
+	}()
+}
+
+func (elc *ControllerNode) handleMulticastResult(ctx context.Context, results <-chan transport.ReceiveResult) {
 	for {
 		select {
 		case <-ctx.Done():
 			clogger.Println("readMulticast handler ctx.Done")
 			return
-		case result := <-results:
+		case result, ok := <-results:
+			if !ok {
+				return
+			}
 			if result.Err != nil {
 				clogger.Printf("[Error] failed to receive [%s]\n", result.Err)
 				break
@@ -121,13 +161,16 @@ func (elc ControllerNode) handleMulticastResult(ctx context.Context, results <-c
 	}
 }
 
-func (elc ControllerNode) handleUnicastResult(ctx context.Context, results <-chan transport.ReceiveResult) {
+func (elc *ControllerNode) handleUnicastResult(ctx context.Context, results <-chan transport.ReceiveResult) {
 	for {
 		select {
 		case <-ctx.Done():
 			clogger.Println("readUnicast handler ctx.Done")
 			return
-		case result := <-results:
+		case result, ok := <-results:
+			if !ok {
+				return
+			}
 			if result.Err != nil {
 				clogger.Printf("[Error] failed to receive [%s]\n", result.Err)
 				break
@@ -142,20 +185,32 @@ func (elc ControllerNode) handleUnicastResult(ctx context.Context, results <-cha
 	}
 }
 
-func (elc ControllerNode) onReceive(ctx context.Context, recv transport.ReceiveResult) error {
+func (elc *ControllerNode) onReceive(ctx context.Context, recv transport.ReceiveResult) error {
 	frame, err := ParseFrame(recv.Data)
 	if err != nil {
-		return fmt.Errorf("parse failed: %w", err)
+		if errors.Is(err, ErrUnsupportedEHD) {
+			clogger.Printf("[%v] ignore frame: %s\n", recv.Address, err)
+			return nil
+		}
+		return fmt.Errorf("parse failed: malformed frame from %v: %w", recv.Address, err)
 	}
 	clogger.Printf("[%v] %s\n", recv.Address, frame)
 
-	var targetObj Object
 	if frame.ESV.isResponseOrNotification() {
-		targetObj = frame.SrcObj()
-	} else {
-		targetObj = frame.DstObj()
+		elc.resolve(recv.Address, frame)
 	}
-	obj, err := parseProperties(targetObj, frame.Properties)
+	elc.seeNode(ctx, recv.Address, frame)
+
+	if frame.IsArbitrary() {
+		if elc.ArbitraryHandler == nil {
+			clogger.Printf("[%v] no handler for arbitrary message format\n", recv.Address)
+			return nil
+		}
+		elc.ArbitraryHandler(recv.Address, frame)
+		return nil
+	}
+
+	obj, err := parseProperties(frame.TargetObj(), frame.Properties)
 	if err != nil {
 		return fmt.Errorf("ParseProperties failed: %w", err)
 	}
@@ -180,12 +235,22 @@ func (elc ControllerNode) onReceive(ctx context.Context, recv transport.ReceiveR
 				loc = fmt.Sprintf("%s%d", lc, ln)
 			}
 
-			tempMetrics.With(prometheus.Labels{"ip": recv.Address, "location": loc, "type": "room"}).Set(o.InternalTemp)
-			tempMetrics.With(prometheus.Labels{"ip": recv.Address, "location": loc, "type": "outside"}).Set(o.OuterTemp)
+			// the gauge is removed rather than kept stale if the temperature is not available
+			room := prometheus.Labels{"ip": recv.Address, "location": loc, "type": "room"}
+			if o.HasInternalTemp {
+				tempMetrics.With(room).Set(o.InternalTemp)
+			} else {
+				tempMetrics.Delete(room)
+			}
+			outside := prometheus.Labels{"ip": recv.Address, "location": loc, "type": "outside"}
+			if o.HasOuterTemp {
+				tempMetrics.With(outside).Set(o.OuterTemp)
+			} else {
+				tempMetrics.Delete(outside)
+			}
 
 		}
 	case Inf: // プロパティ値通知
-		elc.nodeList.Add(recv.Address, frame.SEOJ)
 		//[Controller]2019/09/27 01:52:59 [192.168.1.15] 108100010ef00105ff017301d50401013001 EHD[1081] TID[0001] SEOJ[0ef001](ノードプロファイル) DEOJ[05ff01](コントローラ) ESV[INF] OPC[01] EPC0[d5](インスタンスリスト通知) PDC0[4] EDT0[01013001]
 		//[Controller]2019/09/27 01:52:59 [192.168.1.10] 108100010ef00105ff017301d50401013001 EHD[1081] TID[0001] SEOJ[0ef001](ノードプロファイル) DEOJ[05ff01](コントローラ) ESV[INF] OPC[01] EPC0[d5](インスタンスリスト通知) PDC0[4] EDT0[01013001]
 	case InfC: //
@@ -204,29 +269,183 @@ func (elc ControllerNode) onReceive(ctx context.Context, recv transport.ReceiveR
 func (elc *ControllerNode) sendFrame(f *Frame) {
 	clogger.Printf(">>>>>>>> SEND : %s\n", f)
 	elc.MulticastSender.Send([]byte(f.Serialize()))
+}
+
+// sendFrameTo sends frame only to the node at addr
+func (elc *ControllerNode) sendFrameTo(addr string, f *Frame) {
+	if elc.UnicastSender == nil {
+		elc.sendFrame(f)
+		return
+	}
+	clogger.Printf(">>>>>>>> SEND [%v]: %s\n", addr, f)
+	err := elc.UnicastSender.Send(hostOf(addr), []byte(f.Serialize()))
+	if err != nil {
+		clogger.Printf("[Error] failed to send to %v: %s\n", addr, err)
+	}
+}
+
+// nextTID returns TID for a new request and reserves it
+func (elc *ControllerNode) nextTID() uint16 {
+	elc.mu.Lock()
+	defer elc.mu.Unlock()
+	tid := elc.tid
 	elc.tid++
+	return tid
+}
+
+// seeNode records the node at addr as alive,
+// and inspects device objects listed in frame from its node profile
+func (elc *ControllerNode) seeNode(ctx context.Context, addr string, f Frame) {
+	addr = hostOf(addr)
+
+	elc.mu.Lock()
+	if elc.nodeList == nil {
+		elc.nodeList = make(NodeList)
+	}
+	elc.nodeList.Add(addr, time.Now())
+	elc.mu.Unlock()
+
+	if !f.SEOJ.isNodeProfile() || !f.ESV.isResponseOrNotification() {
+		return
+	}
+
+	elc.mu.Lock()
+	err := elc.nodeList.UpdateProfile(addr, f.SEOJ, f.Properties)
+	elc.mu.Unlock()
+	if err != nil {
+		clogger.Printf("[Error] invalid property of node profile from %v: %s\n", addr, err)
+	}
+
+	for _, p := range f.Properties {
+		code := PropertyCode(p.Code)
+		if (code != InstanceListNotification && code != InstanceListS) || p.Len == 0 {
+			continue
+		}
+		objs, err := ParseInstanceList(p.Data)
+		if err != nil {
+			clogger.Printf("[Error] invalid instance list from %v: %s\n", addr, err)
+			continue
+		}
+
+		var added []Object
+		elc.mu.Lock()
+		if code == InstanceListS {
+			added = elc.nodeList.SetDevices(addr, objs)
+		} else {
+			added = elc.nodeList.AddDevices(addr, objs)
+		}
+		elc.mu.Unlock()
+
+		for _, obj := range added {
+			obj := obj
+			elc.goroutine(func() { elc.inspectDevice(ctx, addr, obj) })
+		}
+	}
+}
+
+// inspectDevice gets manufacturer code and property maps of obj on the node at addr
+func (elc *ControllerNode) inspectDevice(ctx context.Context, addr string, obj Object) {
+	props, err := elc.Get(ctx, addr, obj, ManufacturerCode, StageChangeAnnouncePropertyMap, SetPropertyMap, GetPropertyMap)
+	var snaErr *SNAError
+	if err != nil && !errors.As(err, &snaErr) {
+		clogger.Printf("[Error] failed to inspect %s on %v: %s\n", obj, addr, err)
+		return
+	}
+
+	d := Device{Object: obj}
+	err = d.Update(props)
+	if err != nil {
+		clogger.Printf("[Error] invalid property of %s on %v: %s\n", obj, addr, err)
+	}
+
+	elc.mu.Lock()
+	elc.nodeList.UpdateDevice(addr, d)
+	elc.mu.Unlock()
+}
+
+// maintainNodes requests node profiles periodically to know nodes alive, and evicts nodes gone silent
+func (elc *ControllerNode) maintainNodes(ctx context.Context) {
+	ttl := elc.NodeTTL
+	if ttl == 0 {
+		ttl = DefaultNodeTTL
+	}
+	t := time.NewTicker(ttl / 4)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			// Self-node instance list S is mandatory for every node profile
+			nodeProfile := NewObject(ProfileGroup, Profile, 0x01)
+			elc.sendFrame(CreateGetPropertiesFrame(elc.nextTID(), nodeProfile, []PropertyCode{InstanceListS}))
+
+			elc.mu.Lock()
+			evicted := elc.nodeList.Evict(time.Now().Add(-ttl))
+			elc.mu.Unlock()
+			for _, addr := range evicted {
+				clogger.Printf("[%v] evicted node not seen for %s\n", addr, ttl)
+			}
+		}
+	}
+}
+
+// Nodes returns nodes found and their device objects sorted by address
+func (elc *ControllerNode) Nodes() []Node {
+	elc.mu.Lock()
+	defer elc.mu.Unlock()
+
+	nodes := make([]Node, 0, len(elc.nodeList))
+	for _, addr := range elc.nodeList.Addresses() {
+		n := elc.nodeList[addr]
+		n.Devices = append([]Device{}, n.Devices...)
+		nodes = append(nodes, n)
+	}
+	return nodes
 }
 
 func (elc *ControllerNode) startSequence(ctx context.Context) {
 	clogger.Println("Start Sequnce Begin")
 
-	f := CreateInfFrame(elc.tid)
+	f := CreateInfFrame(elc.nextTID())
 	elc.sendFrame(f)
 
 	// ver.1.0
-	f = CreateInfReqFrame(elc.tid)
+	f = CreateInfReqFrame(elc.nextTID())
 	elc.sendFrame(f)
 
 	// ver.1.1
-	f = CreateGetFrame(elc.tid)
+	f = CreateGetFrame(elc.nextTID())
 	elc.sendFrame(f)
 
-	time.Sleep(time.Second * 3)
+	select {
+	case <-time.After(time.Second * 3):
+	case <-ctx.Done():
+	}
 	clogger.Println("Start Sequnce End")
 }
 
 // RequestAirConState sends request to get air conditioner states
+// to each air conditioner found, or multicasts it if no air conditioner is found yet
 func (elc *ControllerNode) RequestAirConState() {
-	f := CreateAirconGetFrame(elc.tid)
-	elc.sendFrame(f)
+	sent := false
+	for _, n := range elc.Nodes() {
+		for _, d := range n.Devices {
+			if d.Object.ClassGroup != AirConditionerGroup || d.Object.Class != HomeAirConditioner {
+				continue
+			}
+			epcs := d.Supported(airconStateProperties)
+			if len(epcs) == 0 {
+				continue
+			}
+			f := CreateGetPropertiesFrame(elc.nextTID(), d.Object, epcs)
+			elc.sendFrameTo(n.Address, f)
+			sent = true
+		}
+	}
+	if !sent {
+		f := CreateAirconGetFrame(elc.nextTID())
+		elc.sendFrame(f)
+	}
 }
@@ -3,11 +3,14 @@ package echonetlite
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
 
 	gomock "github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/u-one/go-el-controller/transport"
 )
 
@@ -102,3 +105,201 @@ func TestController(t *testing.T) {
 	<-ctx.Done()
 
 }
+
+func TestController_onReceiveArbitrary(t *testing.T) {
+	var gotAddr string
+	var gotFrame Frame
+	c := ControllerNode{
+		ArbitraryHandler: func(addr string, f Frame) {
+			gotAddr = addr
+			gotFrame = f
+		},
+	}
+
+	data := []byte{0x10, 0x82, 0x00, 0x01, 0xde, 0xad}
+	err := c.onReceive(context.Background(), transport.ReceiveResult{Data: data, Address: "192.168.1.3"})
+	if err != nil {
+		t.Fatalf("onReceive failed: %s", err)
+	}
+
+	if gotAddr != "192.168.1.3" {
+		t.Errorf("Diffrent address: want:%v, got:%v", "192.168.1.3", gotAddr)
+	}
+	want := NewArbitraryFrame(1, Data{0xde, 0xad})
+	if diff := cmp.Diff(want, gotFrame); diff != "" {
+		t.Errorf("Frame differs: (-want +got)\n%s", diff)
+	}
+}
+
+func TestController_RequestAirConState(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ms := transport.NewMockMulticastSender(ctrl)
+	us := transport.NewMockUnicastSender(ctrl)
+	c := ControllerNode{MulticastSender: ms, UnicastSender: us}
+
+	aircongetFrame := []byte{0x10, 0x81, 0x0, 0x0, 0x05, 0xff, 0x01, 0x01, 0x30, 0x01, 0x62, 0x04, 0x81, 0x00, 0x83, 0x00, 0xbb, 0x00, 0xbe, 0x00}
+	ms.EXPECT().Send(aircongetFrame)
+	c.RequestAirConState()
+
+	c.nodeList = NodeList{
+		"192.168.1.10": Node{Address: "192.168.1.10", Devices: []Device{{Object: NewObject(AirConditionerGroup, HomeAirConditioner, 0x01)}}},
+		"192.168.1.15": Node{Address: "192.168.1.15", Devices: []Device{{Object: NewObject(AirConditionerGroup, HomeAirConditioner, 0x02)}}},
+		"192.168.1.20": Node{Address: "192.168.1.20", Devices: []Device{{Object: NewObject(HomeEquipmentGroup, LowVoltageSmartMeter, 0x01)}}},
+		"192.168.1.25": Node{Address: "192.168.1.25", Devices: []Device{
+			{
+				Object:         NewObject(AirConditionerGroup, HomeAirConditioner, 0x01),
+				GetPropertyMap: NewPropertyMap(OperationStatus, InstallationLocation, MeasuredRoomTemperature),
+			},
+		}},
+		"192.168.1.30": Node{Address: "192.168.1.30", Devices: []Device{
+			{
+				Object:         NewObject(AirConditionerGroup, HomeAirConditioner, 0x01),
+				GetPropertyMap: NewPropertyMap(OperationStatus),
+			},
+		}},
+	}
+
+	gomock.InOrder(
+		us.EXPECT().Send("192.168.1.10", []byte{0x10, 0x81, 0x0, 0x1, 0x05, 0xff, 0x01, 0x01, 0x30, 0x01, 0x62, 0x04, 0x81, 0x00, 0x83, 0x00, 0xbb, 0x00, 0xbe, 0x00}),
+		us.EXPECT().Send("192.168.1.15", []byte{0x10, 0x81, 0x0, 0x2, 0x05, 0xff, 0x01, 0x01, 0x30, 0x02, 0x62, 0x04, 0x81, 0x00, 0x83, 0x00, 0xbb, 0x00, 0xbe, 0x00}),
+		us.EXPECT().Send("192.168.1.25", []byte{0x10, 0x81, 0x0, 0x3, 0x05, 0xff, 0x01, 0x01, 0x30, 0x01, 0x62, 0x02, 0x81, 0x00, 0xbb, 0x00}),
+	)
+	c.RequestAirConState()
+}
+
+func TestController_Nodes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	us := transport.NewMockUnicastSender(ctrl)
+	uch := make(chan transport.ReceiveResult, 1)
+	c := &ControllerNode{UnicastSender: us}
+	go c.handleUnicastResult(ctx, uch)
+
+	inspectFrame := []byte{0x10, 0x81, 0x00, 0x00, 0x05, 0xff, 0x01, 0x01, 0x30, 0x01, 0x62, 0x04, 0x8a, 0x00, 0x9d, 0x00, 0x9e, 0x00, 0x9f, 0x00}
+	inspected := make(chan struct{})
+	us.EXPECT().Send("192.168.1.15", inspectFrame).DoAndReturn(func(ip string, data []byte) error {
+		uch <- transport.ReceiveResult{
+			Data: []byte{0x10, 0x81, 0x00, 0x00, 0x01, 0x30, 0x01, 0x05, 0xff, 0x01, 0x52, 0x04,
+				0x8a, 0x03, 0x00, 0x00, 0x08,
+				0x9d, 0x02, 0x01, 0x80,
+				0x9e, 0x02, 0x01, 0xb3,
+				0x9f, 0x00},
+			Address: "192.168.1.15:3610",
+		}
+		close(inspected)
+		return nil
+	})
+
+	infFrame := []byte{0x10, 0x81, 0x00, 0x01, 0x0e, 0xf0, 0x01, 0x05, 0xff, 0x01, 0x73, 0x01, 0xd5, 0x04, 0x01, 0x01, 0x30, 0x01}
+	uch <- transport.ReceiveResult{Data: infFrame, Address: "192.168.1.15"}
+
+	<-inspected
+	var got []Node
+	for i := 0; i < 100; i++ {
+		got = c.Nodes()
+		if len(got) == 1 && len(got[0].Devices) == 1 && got[0].Devices[0].ManufacturerCode != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	want := []Node{
+		{
+			Address: "192.168.1.15",
+			Profile: Device{Object: NewObject(ProfileGroup, Profile, 0x01)},
+			Devices: []Device{
+				{
+					Object:           NewObject(AirConditionerGroup, HomeAirConditioner, 0x01),
+					ManufacturerCode: Data{0x00, 0x00, 0x08},
+					AnnoPropertyMap:  NewPropertyMap(OperationStatus),
+					SetPropertyMap:   NewPropertyMap(0xb3),
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(Node{}, "LastSeen")); diff != "" {
+		t.Errorf("Nodes differ: (-want +got)\n%s", diff)
+	}
+	if got[0].LastSeen.IsZero() {
+		t.Errorf("LastSeen is not set")
+	}
+}
+
+func TestController_Run(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	testcases := []struct {
+		name    string
+		waitErr error // error of multicast receiver
+		want    error
+	}{
+		{
+			name: "canceled",
+		},
+		{
+			name:    "receiver failed",
+			waitErr: errors.New("multicast error: bind failed"),
+			want:    errors.New("multicast error: bind failed"),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			h := transport.NewVirtualLAN(1).Host("192.168.1.10")
+			r := transport.NewMockMulticastReceiver(ctrl)
+			c := &ControllerNode{
+				MulticastReceiver: r,
+				UnicastReceiver:   h.UnicastReceiver(),
+				MulticastSender:   h.MulticastSender(MulticastIP, Port),
+				UnicastSender:     h.UnicastSender(Port),
+			}
+
+			stopped := make(chan struct{})
+			r.EXPECT().Start(gomock.Any(), MulticastIP, Port).DoAndReturn(func(ctx context.Context, ip, port string) <-chan transport.ReceiveResult {
+				results := make(chan transport.ReceiveResult)
+				go func() {
+					if tc.waitErr == nil {
+						<-ctx.Done()
+					}
+					close(results)
+					close(stopped)
+				}()
+				return results
+			})
+			r.EXPECT().Wait().DoAndReturn(func() error {
+				<-stopped
+				return tc.waitErr
+			})
+
+			errs := make(chan error)
+			go func() {
+				errs <- c.Run(ctx)
+			}()
+			if tc.waitErr == nil {
+				time.Sleep(100 * time.Millisecond)
+				cancel()
+			}
+
+			select {
+			case err := <-errs:
+				checkError(t, tc.want, err)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Run did not return")
+			}
+		})
+	}
+}
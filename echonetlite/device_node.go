@@ -0,0 +1,449 @@
+package echonetlite
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/u-one/go-el-controller/transport"
+)
+
+var dlogger *log.Logger
+
+func init() {
+	dlogger = log.New(os.Stdout, "[Device]", log.LstdFlags)
+}
+
+// ExperimentalManufacturerCode is manufacturer code for experimental use, which is not registered
+var ExperimentalManufacturerCode = Data{0xFF, 0xFF, 0xFF}
+
+const (
+	// maxInstanceList is max num of instances in instance list notification (0xD5) and self-node instance list S (0xD6)
+	maxInstanceList = 84
+	// maxClassList is max num of classes in self-node class list S (0xD7)
+	maxClassList = 8
+)
+
+// nodeProfileObject is the general node profile object
+var nodeProfileObject = NewObject(ProfileGroup, Profile, 0x01)
+
+// DeviceObject is a device object served by DeviceNode
+// Set property maps before adding it to DeviceNode.
+type DeviceObject struct {
+	Object          Object
+	Store           PropertyStore
+	AnnoPropertyMap PropertyMap // announced with INF when the value changes
+	SetPropertyMap  PropertyMap // writable with SetC and SetI
+	GetPropertyMap  PropertyMap // readable with Get and INF_REQ
+}
+
+// NewDeviceObject returns DeviceObject of obj whose values are in store
+// Only property maps are readable at first. Add codes to property maps to serve values in store.
+func NewDeviceObject(obj Object, store PropertyStore) *DeviceObject {
+	return &DeviceObject{
+		Object:         obj,
+		Store:          store,
+		GetPropertyMap: NewPropertyMap(StageChangeAnnouncePropertyMap, SetPropertyMap, GetPropertyMap),
+	}
+}
+
+// get returns value of code if it is readable
+func (d *DeviceObject) get(code PropertyCode) (Data, bool) {
+	if !d.GetPropertyMap.Has(code) {
+		return nil, false
+	}
+	switch code {
+	case StageChangeAnnouncePropertyMap:
+		return d.AnnoPropertyMap.Encode(), true
+	case SetPropertyMap:
+		return d.SetPropertyMap.Encode(), true
+	case GetPropertyMap:
+		return d.GetPropertyMap.Encode(), true
+	}
+	return d.Store.Get(code)
+}
+
+// DeviceNode is ECHONETLite node which has device objects and responds to requests from controllers
+type DeviceNode struct {
+	MulticastReceiver transport.MulticastReceiver
+	UnicastReceiver   transport.UnicastReceiver
+	MulticastSender   transport.MulticastSender
+	UnicastSender     transport.UnicastSender // sends responses to the requester, MulticastSender is used if nil
+	MulticastGroup    string                  // group to receive multicast, MulticastIP if empty
+	ManufacturerCode  Data                    // of the node profile, ExperimentalManufacturerCode if nil
+	mu                sync.Mutex
+	tid               uint16
+	started           bool
+	profile           *DeviceObject
+	devices           []*DeviceObject
+	wg                sync.WaitGroup // goroutines of the node
+}
+
+// NewDeviceNode returns DeviceNode which has devices
+func NewDeviceNode(devices ...*DeviceObject) (*DeviceNode, error) {
+	return NewDeviceNodeOn(NetworkConfig{}, devices...)
+}
+
+// NewDeviceNodeOn returns DeviceNode on the network of cfg which has devices
+func NewDeviceNodeOn(cfg NetworkConfig, devices ...*DeviceObject) (*DeviceNode, error) {
+	t, err := openUDPTransports(cfg)
+	if err != nil {
+		log.Println(err)
+		return &DeviceNode{}, err
+	}
+	n := &DeviceNode{
+		MulticastReceiver: t.multicastReceiver,
+		MulticastSender:   t.multicastSender,
+		UnicastReceiver:   t.unicastReceiver,
+		UnicastSender:     t.unicastSender,
+		MulticastGroup:    t.group,
+	}
+	for _, d := range devices {
+		n.AddDevice(d)
+	}
+	return n, nil
+}
+
+// Close closes senders. Receivers are closed when ctx given to Start or Run is done.
+func (n *DeviceNode) Close() {
+	n.MulticastSender.Close()
+	if n.UnicastSender != nil {
+		n.UnicastSender.Close()
+	}
+}
+
+// Start starts to respond to requests until ctx is done, and announces the instance list
+func (n *DeviceNode) Start(ctx context.Context) {
+	n.mu.Lock()
+	n.started = true
+	n.mu.Unlock()
+
+	sch := n.UnicastReceiver.Start(ctx, Port)
+	n.goroutine(func() { n.handleResult(ctx, sch) })
+
+	mch := n.MulticastReceiver.Start(ctx, multicastGroup(n.MulticastGroup), Port)
+	n.goroutine(func() { n.handleResult(ctx, mch) })
+
+	n.announceInstanceList()
+}
+
+// Run starts the node, and returns when ctx is done and all of its goroutines have exited.
+// If a receiver stops with error, it stops the node and returns the error.
+func (n *DeviceNode) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	n.Start(ctx)
+	err := waitReceivers(cancel, n.UnicastReceiver, n.MulticastReceiver)
+	n.wg.Wait()
+	return err
+}
+
+// AddDevice adds a device object to the node, and announces the instance list if the node is started
+func (n *DeviceNode) AddDevice(d *DeviceObject) {
+	n.mu.Lock()
+	n.initProfile()
+	n.devices = append(n.devices, d)
+	n.updateInstanceList()
+	started := n.started
+	n.mu.Unlock()
+
+	if started {
+		n.announceInstanceList()
+	}
+}
+
+// Objects returns the node profile object and device objects of the node
+func (n *DeviceNode) Objects() []*DeviceObject {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.initProfile()
+	return append([]*DeviceObject{n.profile}, n.devices...)
+}
+
+// SetProperty sets value of code of obj, and sends INF if it is in the status change announcement property map
+func (n *DeviceNode) SetProperty(obj Object, code PropertyCode, edt Data) error {
+	for _, d := range n.Objects() {
+		if d.Object == obj {
+			return n.set(d, code, edt)
+		}
+	}
+	return fmt.Errorf("no object %s", obj)
+}
+
+// initProfile creates the node profile object unless it exists. Caller holds mu.
+func (n *DeviceNode) initProfile() {
+	if n.profile != nil {
+		return
+	}
+	code := n.ManufacturerCode
+	if code == nil {
+		code = ExperimentalManufacturerCode
+	}
+	// ID: lower communication layer ID 0xFE, manufacturer code and unique ID in the manufacturer
+	id := append(Data{0xFE}, code...)
+	id = append(id, make(Data, 13)...)
+
+	p := NewDeviceObject(nodeProfileObject, NewMemoryPropertyStore(map[PropertyCode]Data{
+		OperationStatus:  {0x30},
+		SpecVersion:      {0x01, 0x0D, 0x01, 0x00}, // Ver.1.13, specified message format
+		ID:               id,
+		ManufacturerCode: code,
+	}))
+	for _, c := range []PropertyCode{OperationStatus, SpecVersion, ID, ManufacturerCode,
+		NumOfInstances, NumOfClasses, InstanceListNotification, InstanceListS, ClassListS} {
+		p.GetPropertyMap.Add(c)
+	}
+	p.AnnoPropertyMap = NewPropertyMap(OperationStatus, InstanceListNotification)
+	n.profile = p
+	n.updateInstanceList()
+}
+
+// updateInstanceList sets properties of the node profile which list device objects. Caller holds mu.
+func (n *DeviceNode) updateInstanceList() {
+	instances := Data{}
+	classes := Data{}
+	numClasses := 0
+	seen := map[[2]byte]bool{}
+	for i, d := range n.devices {
+		if i < maxInstanceList {
+			instances = append(instances, d.Object.Data()...)
+		}
+		class := [2]byte{byte(d.Object.ClassGroup), byte(d.Object.Class)}
+		if seen[class] {
+			continue
+		}
+		seen[class] = true
+		if numClasses < maxClassList {
+			classes = append(classes, class[:]...)
+		}
+		numClasses++
+	}
+	numInstances := len(n.devices)
+	listed := numInstances
+	if listed > maxInstanceList {
+		listed = maxInstanceList
+	}
+	listedClasses := numClasses
+	if listedClasses > maxClassList {
+		listedClasses = maxClassList
+	}
+
+	s := n.profile.Store
+	list := append(Data{byte(listed)}, instances...)
+	// node profile class is included in num of classes
+	values := map[PropertyCode]Data{
+		NumOfInstances:           {byte(numInstances >> 16), byte(numInstances >> 8), byte(numInstances)},
+		NumOfClasses:             {byte((numClasses + 1) >> 8), byte(numClasses + 1)},
+		InstanceListNotification: list,
+		InstanceListS:            list,
+		ClassListS:               append(Data{byte(listedClasses)}, classes...),
+	}
+	for code, edt := range values {
+		if err := s.Set(code, edt); err != nil {
+			dlogger.Printf("[Error] failed to set EPC[%02x] of node profile: %s\n", byte(code), err)
+		}
+	}
+}
+
+// announceInstanceList sends INF of instance list notification
+func (n *DeviceNode) announceInstanceList() {
+	profile := n.Objects()[0]
+	edt, _ := profile.Store.Get(InstanceListNotification)
+	n.announce(profile, InstanceListNotification, edt)
+}
+
+// announce multicasts INF of code of d
+func (n *DeviceNode) announce(d *DeviceObject, code PropertyCode, edt Data) {
+	props := []Property{{Code: byte(code), Len: len(edt), Data: edt}}
+	f := NewFrame(n.nextTID(), d.Object, nodeProfileObject, Inf, props)
+	n.sendFrame(&f)
+}
+
+// set sets value of code of d, and announces it if it changed
+func (n *DeviceNode) set(d *DeviceObject, code PropertyCode, edt Data) error {
+	old, _ := d.Store.Get(code)
+	err := d.Store.Set(code, edt)
+	if err != nil {
+		return err
+	}
+	if d.AnnoPropertyMap.Has(code) && !bytes.Equal(old, edt) {
+		n.announce(d, code, edt)
+	}
+	return nil
+}
+
+// goroutine runs f in a goroutine which Run waits for
+func (n *DeviceNode) goroutine(f func()) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		f()
+	}()
+}
+
+func (n *DeviceNode) nextTID() uint16 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	tid := n.tid
+	n.tid++
+	return tid
+}
+
+func (n *DeviceNode) sendFrame(f *Frame) {
+	dlogger.Printf(">>>>>>>> SEND : %s\n", f)
+	n.MulticastSender.Send([]byte(f.Serialize()))
+}
+
+// sendFrameTo sends frame only to the node at addr
+func (n *DeviceNode) sendFrameTo(addr string, f *Frame) {
+	if n.UnicastSender == nil {
+		n.sendFrame(f)
+		return
+	}
+	dlogger.Printf(">>>>>>>> SEND [%v]: %s\n", addr, f)
+	err := n.UnicastSender.Send(hostOf(addr), []byte(f.Serialize()))
+	if err != nil {
+		dlogger.Printf("[Error] failed to send to %v: %s\n", addr, err)
+	}
+}
+
+func (n *DeviceNode) handleResult(ctx context.Context, results <-chan transport.ReceiveResult) {
+	for {
+		select {
+		case <-ctx.Done():
+			dlogger.Println("receive handler ctx.Done")
+			return
+		case result, ok := <-results:
+			if !ok {
+				return
+			}
+			if result.Err != nil {
+				dlogger.Printf("[Error] failed to receive [%s]\n", result.Err)
+				break
+			}
+			err := n.onReceive(result)
+			if err != nil {
+				dlogger.Printf("[Error] %s", err)
+			}
+		}
+	}
+}
+
+func (n *DeviceNode) onReceive(recv transport.ReceiveResult) error {
+	f, err := ParseFrame(recv.Data)
+	if err != nil {
+		if errors.Is(err, ErrUnsupportedEHD) {
+			dlogger.Printf("[%v] ignore frame: %s\n", recv.Address, err)
+			return nil
+		}
+		return fmt.Errorf("parse failed: malformed frame from %v: %w", recv.Address, err)
+	}
+	if f.IsArbitrary() || !f.ESV.isRequest() {
+		return nil
+	}
+	dlogger.Printf("<<<<<<<< [%v] %s\n", recv.Address, f)
+
+	for _, d := range n.targets(f.DEOJ) {
+		n.serve(recv.Address, d, f)
+	}
+	return nil
+}
+
+// targets returns objects addressed by deoj. Instance code 0 addresses all instances of the class.
+func (n *DeviceNode) targets(deoj Object) []*DeviceObject {
+	targets := []*DeviceObject{}
+	for _, d := range n.Objects() {
+		if d.Object.ClassGroup != deoj.ClassGroup || d.Object.Class != deoj.Class {
+			continue
+		}
+		if deoj.Num == 0 || d.Object.Num == deoj.Num {
+			targets = append(targets, d)
+		}
+	}
+	return targets
+}
+
+// serviceResponses is ESV of responses to requests when accepted and not accepted
+// SetI has no response when accepted, and SetGet is never accepted.
+var serviceResponses = map[ESVType]struct{ res, sna ESVType }{
+	SetI:   {sna: SetISNA},
+	SetC:   {res: SetRes, sna: SetCSNA},
+	Get:    {res: GetRes, sna: GetSNA},
+	InfReq: {res: Inf, sna: InfSNA},
+	SetGet: {sna: SetGetSNA},
+}
+
+// serve processes request f from addr to d, and responds to it
+func (n *DeviceNode) serve(addr string, d *DeviceObject, f Frame) {
+	esv, ok := serviceResponses[f.ESV]
+	if !ok {
+		dlogger.Printf("[%v] unsupported service: %s\n", addr, f.ESV)
+		return
+	}
+
+	tid := binary.BigEndian.Uint16(f.TID)
+	if f.ESV == SetGet {
+		// not supported, so properties to set are returned as requested and ones to get without EDT
+		gets := make([]Property, 0, len(f.GetProperties))
+		for _, p := range f.GetProperties {
+			gets = append(gets, Property{Code: p.Code, Len: 0, Data: Data{}})
+		}
+		res := NewSetGetFrame(tid, d.Object, f.SEOJ, esv.sna, f.Properties, gets)
+		n.sendFrameTo(addr, &res)
+		return
+	}
+
+	accepted := true
+	props := make([]Property, 0, len(f.Properties))
+	for _, p := range f.Properties {
+		code := PropertyCode(p.Code)
+		switch f.ESV {
+		case SetI, SetC:
+			err := n.write(d, code, p.Data)
+			if err != nil {
+				dlogger.Printf("[%v] rejected %s of %s: %s\n", addr, p, d.Object, err)
+				// properties not accepted are returned as requested
+				props = append(props, p)
+				accepted = false
+				continue
+			}
+			props = append(props, Property{Code: p.Code, Len: 0, Data: Data{}})
+		default:
+			edt, ok := d.get(code)
+			if !ok {
+				props = append(props, Property{Code: p.Code, Len: 0, Data: Data{}})
+				accepted = false
+				continue
+			}
+			props = append(props, Property{Code: p.Code, Len: len(edt), Data: edt})
+		}
+	}
+
+	switch {
+	case !accepted:
+		res := NewFrame(tid, d.Object, f.SEOJ, esv.sna, props)
+		n.sendFrameTo(addr, &res)
+	case f.ESV == SetI:
+	case f.ESV == InfReq:
+		// INF in response to INF_REQ is multicast
+		res := NewFrame(tid, d.Object, f.SEOJ, esv.res, props)
+		n.sendFrame(&res)
+	default:
+		res := NewFrame(tid, d.Object, f.SEOJ, esv.res, props)
+		n.sendFrameTo(addr, &res)
+	}
+}
+
+// write sets value of code of d requested by a controller
+func (n *DeviceNode) write(d *DeviceObject, code PropertyCode, edt Data) error {
+	if !d.SetPropertyMap.Has(code) {
+		return fmt.Errorf("EPC[%02x] is not writable", byte(code))
+	}
+	return n.set(d, code, edt)
+}
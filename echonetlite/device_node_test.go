@@ -0,0 +1,279 @@
+package echonetlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/u-one/go-el-controller/transport"
+)
+
+// newTestSensor returns temperature sensor whose installation location is writable and announced
+func newTestSensor(instance int) *DeviceObject {
+	d := NewDeviceObject(NewObject(SensorGroup, TemperatureSensor, instance), NewMemoryPropertyStore(map[PropertyCode]Data{
+		OperationStatus:      {0x30},
+		InstallationLocation: {0x08},
+		MeasuredTemperature:  {0x00, 0xfa},
+	}))
+	d.GetPropertyMap.Add(OperationStatus)
+	d.GetPropertyMap.Add(InstallationLocation)
+	d.GetPropertyMap.Add(MeasuredTemperature)
+	d.SetPropertyMap.Add(InstallationLocation)
+	d.AnnoPropertyMap.Add(OperationStatus)
+	d.AnnoPropertyMap.Add(InstallationLocation)
+	return d
+}
+
+func TestDeviceNode_onReceive(t *testing.T) {
+	type sent struct {
+		multicast bool
+		data      []byte
+	}
+
+	testcases := []struct {
+		name    string
+		request []byte
+		want    []sent
+	}{
+		{
+			name:    "Get",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x00, 0x11, 0x01, 0x62, 0x02, 0x80, 0x00, 0xe0, 0x00},
+			want: []sent{
+				{data: []byte{0x10, 0x81, 0x00, 0x05, 0x00, 0x11, 0x01, 0x05, 0xff, 0x01, 0x72, 0x02, 0x80, 0x01, 0x30, 0xe0, 0x02, 0x00, 0xfa}},
+			},
+		},
+		{
+			name:    "Get_SNA for unsupported EPC",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x00, 0x11, 0x01, 0x62, 0x02, 0x80, 0x00, 0xbb, 0x00},
+			want: []sent{
+				{data: []byte{0x10, 0x81, 0x00, 0x05, 0x00, 0x11, 0x01, 0x05, 0xff, 0x01, 0x52, 0x02, 0x80, 0x01, 0x30, 0xbb, 0x00}},
+			},
+		},
+		{
+			name:    "Get property map",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x00, 0x11, 0x01, 0x62, 0x01, 0x9e, 0x00},
+			want: []sent{
+				{data: []byte{0x10, 0x81, 0x00, 0x05, 0x00, 0x11, 0x01, 0x05, 0xff, 0x01, 0x72, 0x01, 0x9e, 0x02, 0x01, 0x81}},
+			},
+		},
+		{
+			name:    "Get all instances",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x00, 0x11, 0x00, 0x62, 0x01, 0x80, 0x00},
+			want: []sent{
+				{data: []byte{0x10, 0x81, 0x00, 0x05, 0x00, 0x11, 0x01, 0x05, 0xff, 0x01, 0x72, 0x01, 0x80, 0x01, 0x30}},
+				{data: []byte{0x10, 0x81, 0x00, 0x05, 0x00, 0x11, 0x02, 0x05, 0xff, 0x01, 0x72, 0x01, 0x80, 0x01, 0x30}},
+			},
+		},
+		{
+			name:    "Get node profile",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x0e, 0xf0, 0x01, 0x62, 0x03, 0xd3, 0x00, 0xd6, 0x00, 0xd7, 0x00},
+			want: []sent{
+				{data: []byte{0x10, 0x81, 0x00, 0x05, 0x0e, 0xf0, 0x01, 0x05, 0xff, 0x01, 0x72, 0x03,
+					0xd3, 0x03, 0x00, 0x00, 0x02,
+					0xd6, 0x07, 0x02, 0x00, 0x11, 0x01, 0x00, 0x11, 0x02,
+					0xd7, 0x03, 0x01, 0x00, 0x11}},
+			},
+		},
+		{
+			name:    "SetC announces the change",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x00, 0x11, 0x01, 0x61, 0x01, 0x81, 0x01, 0x10},
+			want: []sent{
+				{multicast: true, data: []byte{0x10, 0x81, 0x00, 0x00, 0x00, 0x11, 0x01, 0x0e, 0xf0, 0x01, 0x73, 0x01, 0x81, 0x01, 0x10}},
+				{data: []byte{0x10, 0x81, 0x00, 0x05, 0x00, 0x11, 0x01, 0x05, 0xff, 0x01, 0x71, 0x01, 0x81, 0x00}},
+			},
+		},
+		{
+			name:    "SetC with the same value",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x00, 0x11, 0x01, 0x61, 0x01, 0x81, 0x01, 0x08},
+			want: []sent{
+				{data: []byte{0x10, 0x81, 0x00, 0x05, 0x00, 0x11, 0x01, 0x05, 0xff, 0x01, 0x71, 0x01, 0x81, 0x00}},
+			},
+		},
+		{
+			name:    "SetC_SNA for read-only EPC",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x00, 0x11, 0x01, 0x61, 0x02, 0x81, 0x01, 0x08, 0xe0, 0x02, 0x00, 0x00},
+			want: []sent{
+				{data: []byte{0x10, 0x81, 0x00, 0x05, 0x00, 0x11, 0x01, 0x05, 0xff, 0x01, 0x51, 0x02, 0x81, 0x00, 0xe0, 0x02, 0x00, 0x00}},
+			},
+		},
+		{
+			name:    "SetI",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x00, 0x11, 0x01, 0x60, 0x01, 0x81, 0x01, 0x08},
+			want:    []sent{},
+		},
+		{
+			name:    "SetI_SNA for empty EDT",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x00, 0x11, 0x01, 0x60, 0x01, 0x81, 0x00},
+			want: []sent{
+				{data: []byte{0x10, 0x81, 0x00, 0x05, 0x00, 0x11, 0x01, 0x05, 0xff, 0x01, 0x50, 0x01, 0x81, 0x00}},
+			},
+		},
+		{
+			name:    "INF_REQ",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x00, 0x11, 0x01, 0x63, 0x01, 0xe0, 0x00},
+			want: []sent{
+				{multicast: true, data: []byte{0x10, 0x81, 0x00, 0x05, 0x00, 0x11, 0x01, 0x05, 0xff, 0x01, 0x73, 0x01, 0xe0, 0x02, 0x00, 0xfa}},
+			},
+		},
+		{
+			name:    "INF_SNA",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x00, 0x11, 0x01, 0x63, 0x01, 0xbb, 0x00},
+			want: []sent{
+				{data: []byte{0x10, 0x81, 0x00, 0x05, 0x00, 0x11, 0x01, 0x05, 0xff, 0x01, 0x53, 0x01, 0xbb, 0x00}},
+			},
+		},
+		{
+			name:    "SetGet_SNA",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x00, 0x11, 0x01, 0x6e, 0x01, 0x81, 0x01, 0x10, 0x01, 0xe0, 0x00},
+			want: []sent{
+				{data: []byte{0x10, 0x81, 0x00, 0x05, 0x00, 0x11, 0x01, 0x05, 0xff, 0x01, 0x5e, 0x01, 0x81, 0x01, 0x10, 0x01, 0xe0, 0x00}},
+			},
+		},
+		{
+			name:    "response is ignored",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x00, 0x11, 0x01, 0x72, 0x01, 0x80, 0x01, 0x30},
+			want:    []sent{},
+		},
+		{
+			name:    "object not in the node",
+			request: []byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x01, 0x30, 0x01, 0x62, 0x01, 0x80, 0x00},
+			want:    []sent{},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ms := transport.NewMockMulticastSender(ctrl)
+			us := transport.NewMockUnicastSender(ctrl)
+			n := &DeviceNode{MulticastSender: ms, UnicastSender: us}
+			n.AddDevice(newTestSensor(1))
+			n.AddDevice(newTestSensor(2))
+
+			got := []sent{}
+			ms.EXPECT().Send(gomock.Any()).Do(func(data []byte) {
+				got = append(got, sent{multicast: true, data: data})
+			}).AnyTimes()
+			us.EXPECT().Send("192.168.1.5", gomock.Any()).DoAndReturn(func(ip string, data []byte) error {
+				got = append(got, sent{data: data})
+				return nil
+			}).AnyTimes()
+
+			err := n.onReceive(transport.ReceiveResult{Data: tc.request, Address: "192.168.1.5:3610"})
+			if err != nil {
+				t.Fatalf("onReceive failed: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(sent{})); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDeviceNode_Start(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	r := transport.NewMockMulticastReceiver(ctrl)
+	ur := transport.NewMockUnicastReceiver(ctrl)
+	ms := transport.NewMockMulticastSender(ctrl)
+	us := transport.NewMockUnicastSender(ctrl)
+
+	mch := make(chan transport.ReceiveResult, 1)
+	uch := make(chan transport.ReceiveResult, 1)
+	r.EXPECT().Start(gomock.Any(), "224.0.23.0", ":3610").Return(mch)
+	ur.EXPECT().Start(gomock.Any(), ":3610").Return(uch)
+
+	n := &DeviceNode{MulticastReceiver: r, UnicastReceiver: ur, MulticastSender: ms, UnicastSender: us}
+	n.AddDevice(newTestSensor(1))
+
+	responded := make(chan struct{})
+	gomock.InOrder(
+		// instance list at startup
+		ms.EXPECT().Send([]byte{0x10, 0x81, 0x00, 0x00, 0x0e, 0xf0, 0x01, 0x0e, 0xf0, 0x01, 0x73, 0x01, 0xd5, 0x04, 0x01, 0x00, 0x11, 0x01}),
+		// instance list after a device is added
+		ms.EXPECT().Send([]byte{0x10, 0x81, 0x00, 0x01, 0x0e, 0xf0, 0x01, 0x0e, 0xf0, 0x01, 0x73, 0x01, 0xd5, 0x07, 0x02, 0x00, 0x11, 0x01, 0x00, 0x11, 0x02}),
+		// change of operation status
+		ms.EXPECT().Send([]byte{0x10, 0x81, 0x00, 0x02, 0x00, 0x11, 0x02, 0x0e, 0xf0, 0x01, 0x73, 0x01, 0x80, 0x01, 0x31}),
+	)
+	us.EXPECT().Send("192.168.1.5", []byte{0x10, 0x81, 0x00, 0x09, 0x00, 0x11, 0x02, 0x05, 0xff, 0x01, 0x72, 0x01, 0x80, 0x01, 0x31}).DoAndReturn(func(ip string, data []byte) error {
+		close(responded)
+		return nil
+	})
+
+	n.Start(ctx)
+	n.AddDevice(newTestSensor(2))
+
+	// unchanged value is not announced
+	err := n.SetProperty(NewObject(SensorGroup, TemperatureSensor, 2), OperationStatus, Data{0x30})
+	if err != nil {
+		t.Fatalf("SetProperty failed: %s", err)
+	}
+	err = n.SetProperty(NewObject(SensorGroup, TemperatureSensor, 2), OperationStatus, Data{0x31})
+	if err != nil {
+		t.Fatalf("SetProperty failed: %s", err)
+	}
+	err = n.SetProperty(NewObject(SensorGroup, TemperatureSensor, 3), OperationStatus, Data{0x31})
+	if err == nil {
+		t.Errorf("SetProperty succeeded for object not in the node")
+	}
+
+	mch <- transport.ReceiveResult{Data: []byte{0x10, 0x81, 0x00, 0x09, 0x05, 0xff, 0x01, 0x00, 0x11, 0x02, 0x62, 0x01, 0x80, 0x00}, Address: "192.168.1.5"}
+
+	select {
+	case <-responded:
+	case <-ctx.Done():
+		t.Fatal("no response to Get")
+	}
+}
+
+func TestDeviceNode_Controller(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// the controller and the device node are connected by their unicast senders
+	cs := transport.NewMockUnicastSender(ctrl)
+	ds := transport.NewMockUnicastSender(ctrl)
+	cch := make(chan transport.ReceiveResult, 1)
+	dch := make(chan transport.ReceiveResult, 1)
+	c := &ControllerNode{UnicastSender: cs, RequestTimeout: time.Second}
+	go c.handleUnicastResult(ctx, cch)
+	n := &DeviceNode{UnicastSender: ds, MulticastSender: transport.NewMockMulticastSender(ctrl)}
+	n.AddDevice(newTestSensor(1))
+	go n.handleResult(ctx, dch)
+
+	cs.EXPECT().Send("192.168.1.20", gomock.Any()).DoAndReturn(func(ip string, data []byte) error {
+		dch <- transport.ReceiveResult{Data: data, Address: "192.168.1.5:3610"}
+		return nil
+	}).AnyTimes()
+	ds.EXPECT().Send("192.168.1.5", gomock.Any()).DoAndReturn(func(ip string, data []byte) error {
+		cch <- transport.ReceiveResult{Data: data, Address: "192.168.1.20:3610"}
+		return nil
+	}).AnyTimes()
+
+	got, err := c.Get(ctx, "192.168.1.20", NewObject(SensorGroup, TemperatureSensor, 1), MeasuredTemperature)
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	want := []Property{{Code: byte(MeasuredTemperature), Len: 2, Data: Data{0x00, 0xfa}}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+	}
+
+	_, err = c.Get(ctx, "192.168.1.20", NewObject(SensorGroup, TemperatureSensor, 1), MeasuredRoomTemperature)
+	checkError(t, &SNAError{Addr: "192.168.1.20", Frame: Frame{ESV: GetSNA, Properties: []Property{{Code: 0xbb, Len: 0, Data: Data{}}}}}, err)
+}
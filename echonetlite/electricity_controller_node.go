@@ -3,7 +3,11 @@ package echonetlite
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -17,36 +21,150 @@ var (
 			Help:      "",
 		},
 	)
+	gcurrent = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "home",
+			Subsystem: "smartmeter_exporter",
+			Name:      "instant_current_amperes",
+			Help:      "Measured instantaneous current of R and T phase",
+		},
+		[]string{"phase"},
+	)
+	energy = newEnergyCollector()
 )
 
 func init() {
 	prometheus.MustRegister(gpower)
+	prometheus.MustRegister(gcurrent)
+	prometheus.MustRegister(energy)
+}
+
+// energyCollector exports cumulative amounts of electric energy as counters
+// The values are read from the smart meter, so they are set instead of added.
+type energyCollector struct {
+	mu            sync.Mutex
+	cumulative    map[string]*energyCounter
+	fixedTime     map[string]*energyCounter
+	energyDesc    *prometheus.Desc
+	fixedTimeDesc *prometheus.Desc
+}
+
+// energyCounter keeps a counter increasing when the measured value rolls over
+type energyCounter struct {
+	last  float64 // kWh measured last
+	total float64 // kWh exported
+}
+
+// update updates the counter with measured v. modulus is kWh at which the measured value rolls over, 0 if unknown.
+func (c *energyCounter) update(v, modulus float64) {
+	d := v - c.last
+	if d < 0 && modulus > 0 {
+		d += modulus
+	}
+	if d < 0 {
+		// the smart-meter was reset, which the counter follows
+		c.total = v
+	} else {
+		c.total += d
+	}
+	c.last = v
+}
+
+func newEnergyCollector() *energyCollector {
+	return &energyCollector{
+		cumulative: map[string]*energyCounter{},
+		fixedTime:  map[string]*energyCounter{},
+		energyDesc: prometheus.NewDesc(
+			"home_smartmeter_exporter_energy_kwh_total",
+			"Measured cumulative amounts of electric energy",
+			[]string{"direction"}, nil,
+		),
+		fixedTimeDesc: prometheus.NewDesc(
+			"home_smartmeter_exporter_fixed_time_energy_kwh_total",
+			"Cumulative amounts of electric energy measured at fixed time (every 30 minutes)",
+			[]string{"direction"}, nil,
+		),
+	}
+}
+
+func (c *energyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.energyDesc
+	ch <- c.fixedTimeDesc
+}
+
+func (c *energyCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for direction, v := range c.cumulative {
+		ch <- prometheus.MustNewConstMetric(c.energyDesc, prometheus.CounterValue, v.total, direction)
+	}
+	for direction, v := range c.fixedTime {
+		ch <- prometheus.MustNewConstMetric(c.fixedTimeDesc, prometheus.CounterValue, v.total, direction)
+	}
 }
 
+func (c *energyCollector) setCumulative(direction string, v float64, scale EnergyScale) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	updateCounter(c.cumulative, direction, v, scale)
+}
+
+func (c *energyCollector) setFixedTime(direction string, v float64, scale EnergyScale) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	updateCounter(c.fixedTime, direction, v, scale)
+}
+
+func updateCounter(counters map[string]*energyCounter, direction string, v float64, scale EnergyScale) {
+	counter, ok := counters[direction]
+	if !ok {
+		counters[direction] = &energyCounter{last: v, total: v}
+		return
+	}
+	counter.update(v, scale.Modulus())
+}
+
+// directions of energy
+const (
+	directionNormal  = "normal"
+	directionReverse = "reverse"
+)
+
 // SmartMeterClient is interface for smart-meter cleint
 type SmartMeterClient interface {
 	Connect(ctx context.Context, bRouteID, bRoutePW string) error
 	Close()
 	Send(data []byte) ([]byte, error)
+	SendOnly(data []byte) error
+	Listen(ctx context.Context) <-chan []byte
 }
 
+// smartMeterAddr is used as address of smart-meter in errors
+const smartMeterAddr = "smart-meter"
+
 // ElectricityControllerNode is node for smart-meter
 type ElectricityControllerNode struct {
 	client SmartMeterClient
+	now    func() time.Time // clock to resolve dates of historical data
+
+	mu       sync.Mutex
+	tid      uint16
+	scale    *EnergyScale
+	notified map[string]FixedTimeEnergy // fixed-time energy notified by the smart-meter per direction
 }
 
 // NewElectricityControllerNode returns ElectricityControllerNode instance
 func NewElectricityControllerNode(c SmartMeterClient) *ElectricityControllerNode {
-	return &ElectricityControllerNode{c}
+	return &ElectricityControllerNode{client: c, now: time.Now, notified: map[string]FixedTimeEnergy{}}
 }
 
 // Close closes client
-func (n ElectricityControllerNode) Close() {
+func (n *ElectricityControllerNode) Close() {
 	n.client.Close()
 }
 
 // Start starts to connect to smart-meter
-func (n ElectricityControllerNode) Start(ctx context.Context, bRouteID, bRoutePassword string) error {
+func (n *ElectricityControllerNode) Start(ctx context.Context, bRouteID, bRoutePassword string) error {
 	err := n.client.Connect(ctx, bRouteID, bRoutePassword)
 	if err != nil {
 		return fmt.Errorf("exec Connect failed: %v", err)
@@ -54,43 +172,442 @@ func (n ElectricityControllerNode) Start(ctx context.Context, bRouteID, bRoutePa
 	return nil
 }
 
-// GetPowerConsumption requests power consumption and receives
-func (n ElectricityControllerNode) GetPowerConsumption() (int, error) {
-	f := CreateCurrentPowerConsumptionFrame(1) // TODO: increment
+func (n *ElectricityControllerNode) nextTID() uint16 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.tid++
+	return n.tid
+}
+
+// get reads properties epcs of the smart-meter.
+// If some of them are not available, it returns properties in Get_SNA with SNAError.
+func (n *ElectricityControllerNode) get(epcs ...PropertyCode) ([]Property, error) {
+	dest := NewObject(HomeEquipmentGroup, LowVoltageSmartMeter, 0x01)
+	f := CreateGetPropertiesFrame(n.nextTID(), dest, epcs)
 
 	rdata, err := n.client.Send(f.Serialize())
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	rf, err := ParseFrame(rdata)
 	if err != nil {
-		return 0, fmt.Errorf("invalid frame: %w", err)
+		return nil, fmt.Errorf("invalid frame: %w", err)
 	}
 	rf.Print()
 
 	switch rf.ESV {
-	// 応答・通知
-	case GetRes: // プロパティ値読み出し応答
-		o := rf.SrcObj()
-		switch o.classGroupCode() {
-		case HomeEquipmentGroup:
-			switch o.classCode() {
-			case LowVoltageSmartMeter:
-				for _, p := range rf.Properties {
-					switch PropertyCode(p.Code) {
-					case InstantPower:
-						power := binary.BigEndian.Uint32(p.Data)
-						gpower.Set(float64(power))
-						logger.Printf("Power: %d [W]", power)
-						return int(power), nil
-					}
-				}
+	case GetRes:
+		return rf.Properties, nil
+	case GetSNA:
+		return rf.Properties, &SNAError{Addr: smartMeterAddr, Frame: rf}
+	default:
+		return nil, fmt.Errorf("unexpected response to Get: %s", rf.ESV)
+	}
+}
+
+// getOne reads a property of the smart-meter
+func (n *ElectricityControllerNode) getOne(epc PropertyCode) (Property, error) {
+	props, err := n.get(epc)
+	if err != nil {
+		return Property{}, err
+	}
+	for _, p := range props {
+		if PropertyCode(p.Code) == epc {
+			return p, nil
+		}
+	}
+	return Property{}, fmt.Errorf("EPC[%02x] missing in response", byte(epc))
+}
+
+// GetPowerConsumption requests power consumption and receives
+func (n *ElectricityControllerNode) GetPowerConsumption() (int, error) {
+	p, err := n.getOne(InstantPower)
+	if err != nil {
+		return 0, err
+	}
+	power, err := decodeInstantPower(p)
+	if err != nil {
+		return 0, err
+	}
+	gpower.Set(float64(power))
+	logger.Printf("Power: %d [W]", power)
+	return power, nil
+}
+
+// EnergyScale is information to convert measured cumulative amounts of electric energy into kWh
+type EnergyScale struct {
+	Coefficient     int     // 0xD3, 1 if the smart-meter doesn't have it
+	EffectiveDigits int     // 0xD7
+	Unit            float64 // 0xE1 in kWh
+}
+
+// KWh converts measured value into kWh
+func (s EnergyScale) KWh(v uint32) float64 {
+	return float64(v) * float64(s.Coefficient) * s.Unit
+}
+
+// Modulus returns kWh at which measured values roll over to 0, or 0 if effective digits are unknown
+func (s EnergyScale) Modulus() float64 {
+	if s.EffectiveDigits <= 0 {
+		return 0
+	}
+	return math.Pow10(s.EffectiveDigits) * float64(s.Coefficient) * s.Unit
+}
+
+// energyUnits is kWh of each value of unit for cumulative amounts of electric energy (0xE1)
+var energyUnits = map[byte]float64{
+	0x00: 1,
+	0x01: 0.1,
+	0x02: 0.01,
+	0x03: 0.001,
+	0x04: 0.0001,
+	0x0A: 10,
+	0x0B: 100,
+	0x0C: 1000,
+	0x0D: 10000,
+}
+
+// GetEnergyScale reads coefficient (0xD3), effective digits (0xD7) and unit (0xE1) of cumulative amounts of electric energy.
+func (n *ElectricityControllerNode) GetEnergyScale() (EnergyScale, error) {
+	props, err := n.get(Coefficient, IntegralPowerConsumptionValidDigits, IntegralPowerConsumptionUnit)
+	var snaErr *SNAError
+	if err != nil && !errors.As(err, &snaErr) {
+		return EnergyScale{}, err
+	}
+
+	// Coefficient is optional and 1 if it doesn't exist
+	scale := EnergyScale{Coefficient: 1}
+	for _, p := range props {
+		if len(p.Data) == 0 {
+			if PropertyCode(p.Code) == Coefficient {
+				continue
+			}
+			if err == nil {
+				err = fmt.Errorf("EPC[%02x] missing in response", p.Code)
 			}
+			return EnergyScale{}, err
 		}
-	default:
+		switch PropertyCode(p.Code) {
+		case Coefficient:
+			if len(p.Data) != 4 {
+				return EnergyScale{}, fmt.Errorf("invalid Coefficient length: %d", len(p.Data))
+			}
+			scale.Coefficient = int(binary.BigEndian.Uint32(p.Data))
+		case IntegralPowerConsumptionValidDigits:
+			if len(p.Data) != 1 {
+				return EnergyScale{}, fmt.Errorf("invalid EffectiveDigits length: %d", len(p.Data))
+			}
+			scale.EffectiveDigits = int(p.Data[0])
+		case IntegralPowerConsumptionUnit:
+			if len(p.Data) != 1 {
+				return EnergyScale{}, fmt.Errorf("invalid Unit length: %d", len(p.Data))
+			}
+			unit, ok := energyUnits[p.Data[0]]
+			if !ok {
+				return EnergyScale{}, fmt.Errorf("unknown Unit: %02x", p.Data[0])
+			}
+			scale.Unit = unit
+		}
+	}
+	if scale.Unit == 0 {
+		return EnergyScale{}, fmt.Errorf("unit missing in response")
+	}
+
+	n.mu.Lock()
+	n.scale = &scale
+	n.mu.Unlock()
+	return scale, nil
+}
+
+// energyScale returns EnergyScale read before, or reads it at first
+func (n *ElectricityControllerNode) energyScale() (EnergyScale, error) {
+	n.mu.Lock()
+	scale := n.scale
+	n.mu.Unlock()
+	if scale != nil {
+		return *scale, nil
+	}
+	return n.GetEnergyScale()
+}
+
+// GetCumulativeEnergy reads measured cumulative amounts of electric energy in normal direction (0xE0) in kWh
+func (n *ElectricityControllerNode) GetCumulativeEnergy() (float64, error) {
+	return n.getCumulativeEnergy(IntegralPowerConsumption, directionNormal)
+}
+
+// GetCumulativeEnergyReverse reads measured cumulative amounts of electric energy in reverse direction (0xE3) in kWh
+func (n *ElectricityControllerNode) GetCumulativeEnergyReverse() (float64, error) {
+	return n.getCumulativeEnergy(IntegralPowerConsumptionRev, directionReverse)
+}
+
+func (n *ElectricityControllerNode) getCumulativeEnergy(epc PropertyCode, direction string) (float64, error) {
+	scale, err := n.energyScale()
+	if err != nil {
+		return 0, err
+	}
+	p, err := n.getOne(epc)
+	if err != nil {
+		return 0, err
+	}
+	kWh, err := decodeCumulativeEnergy(p, scale)
+	if err != nil {
+		return 0, err
+	}
+	energy.setCumulative(direction, kWh, scale)
+	return kWh, nil
+}
+
+// GetInstantCurrent reads measured instantaneous currents (0xE8) of R and T phase in ampere
+// T phase is NaN for single-phase 2-wire system.
+func (n *ElectricityControllerNode) GetInstantCurrent() (float64, float64, error) {
+	p, err := n.getOne(InstantCurrent)
+	if err != nil {
+		return 0, 0, err
+	}
+	r, t, err := decodeInstantCurrent(p)
+	if err != nil {
+		return 0, 0, err
+	}
+	setCurrentMetrics(r, t)
+	return r, t, nil
+}
+
+// FixedTimeEnergy is cumulative amounts of electric energy measured at fixed time
+type FixedTimeEnergy struct {
+	Time   time.Time
+	Energy float64 // kWh
+}
+
+// GetFixedTimeCumulativeEnergy reads cumulative amounts of electric energy measured at fixed time in normal direction (0xEA)
+func (n *ElectricityControllerNode) GetFixedTimeCumulativeEnergy() (FixedTimeEnergy, error) {
+	return n.getFixedTimeCumulativeEnergy(PeriodicalIntegralPowerConsumption, directionNormal)
+}
+
+// GetFixedTimeCumulativeEnergyReverse reads cumulative amounts of electric energy measured at fixed time in reverse direction (0xEB)
+func (n *ElectricityControllerNode) GetFixedTimeCumulativeEnergyReverse() (FixedTimeEnergy, error) {
+	return n.getFixedTimeCumulativeEnergy(PeriodicalIntegralPowerConsumptionRev, directionReverse)
+}
+
+func (n *ElectricityControllerNode) getFixedTimeCumulativeEnergy(epc PropertyCode, direction string) (FixedTimeEnergy, error) {
+	scale, err := n.energyScale()
+	if err != nil {
+		return FixedTimeEnergy{}, err
+	}
+	p, err := n.getOne(epc)
+	if err != nil {
+		return FixedTimeEnergy{}, err
+	}
+	e, err := decodeFixedTimeEnergy(p, scale)
+	if err != nil {
+		return FixedTimeEnergy{}, err
+	}
+	energy.setFixedTime(direction, e.Energy, scale)
+	return e, nil
+}
+
+// HandleNotifications receives frames the smart-meter sends spontaneously until ctx is done.
+// Cumulative amounts of electric energy measured at fixed time (0xEA, 0xEB) are recorded into metrics,
+// and INFC is acknowledged with INFC_Res.
+func (n *ElectricityControllerNode) HandleNotifications(ctx context.Context) {
+	for data := range n.client.Listen(ctx) {
+		n.handleNotification(data)
+	}
+}
+
+func (n *ElectricityControllerNode) handleNotification(data []byte) {
+	f, err := ParseFrame(data)
+	if err != nil {
+		logger.Printf("[Error] invalid frame from smart-meter: %s", err)
+		return
+	}
+	f.Print()
+	if f.ESV != Inf && f.ESV != InfC {
+		logger.Printf("[Error] unexpected frame from smart-meter: %s", f.ESV)
+		return
+	}
+
+	for _, p := range f.Properties {
+		var direction string
+		switch PropertyCode(p.Code) {
+		case PeriodicalIntegralPowerConsumption:
+			direction = directionNormal
+		case PeriodicalIntegralPowerConsumptionRev:
+			direction = directionReverse
+		default:
+			continue
+		}
+		scale, err := n.energyScale()
+		if err != nil {
+			logger.Printf("[Error] failed to get energy scale: %s", err)
+			break
+		}
+		e, err := decodeFixedTimeEnergy(p, scale)
+		if err != nil {
+			logger.Printf("[Error] %s", err)
+			continue
+		}
+		energy.setFixedTime(direction, e.Energy, scale)
+		n.mu.Lock()
+		n.notified[direction] = e
+		n.mu.Unlock()
 	}
 
-	return 0, nil
+	if f.ESV == InfC {
+		props := make([]Property, 0, len(f.Properties))
+		for _, p := range f.Properties {
+			props = append(props, Property{Code: p.Code, Len: 0, Data: Data{}})
+		}
+		res := NewFrame(binary.BigEndian.Uint16(f.TID), f.DEOJ, f.SEOJ, InfCRes, props)
+		err := n.client.SendOnly(res.Serialize())
+		if err != nil {
+			logger.Printf("[Error] failed to send INFC_Res: %s", err)
+		}
+	}
+}
+
+// NotifiedFixedTimeEnergy returns the latest cumulative amounts of electric energy measured at fixed time
+// in normal and reverse direction which the smart-meter notified. They are zero until notified.
+func (n *ElectricityControllerNode) NotifiedFixedTimeEnergy() (FixedTimeEnergy, FixedTimeEnergy) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.notified[directionNormal], n.notified[directionReverse]
+}
+
+// SmartMeterReading is set of values read from low-voltage smart electric energy meter
+type SmartMeterReading struct {
+	InstantPower            int     // W
+	InstantCurrentR         float64 // A
+	InstantCurrentT         float64 // A, NaN for single-phase 2-wire system
+	CumulativeEnergy        float64 // kWh
+	CumulativeEnergyReverse float64 // kWh
+	FixedTimeEnergy         FixedTimeEnergy
+	FixedTimeEnergyReverse  FixedTimeEnergy
+}
+
+// GetReadings reads instant power, currents and cumulative amounts of electric energy in one request
+// and updates metrics of them. Optional properties the smart-meter doesn't have, and fixed time energy
+// it has not measured, are left zero.
+func (n *ElectricityControllerNode) GetReadings() (SmartMeterReading, error) {
+	scale, err := n.energyScale()
+	if err != nil {
+		return SmartMeterReading{}, err
+	}
+
+	props, err := n.get(InstantPower, InstantCurrent,
+		IntegralPowerConsumption, IntegralPowerConsumptionRev,
+		PeriodicalIntegralPowerConsumption, PeriodicalIntegralPowerConsumptionRev)
+	var snaErr *SNAError
+	if err != nil && !errors.As(err, &snaErr) {
+		return SmartMeterReading{}, err
+	}
+
+	r := SmartMeterReading{InstantCurrentT: math.NaN()}
+	for _, p := range props {
+		if len(p.Data) == 0 {
+			switch PropertyCode(p.Code) {
+			case IntegralPowerConsumptionRev, PeriodicalIntegralPowerConsumptionRev:
+				continue
+			}
+			if err == nil {
+				err = fmt.Errorf("EPC[%02x] missing in response", p.Code)
+			}
+			return SmartMeterReading{}, err
+		}
+		switch PropertyCode(p.Code) {
+		case InstantPower:
+			r.InstantPower, err = decodeInstantPower(p)
+		case InstantCurrent:
+			r.InstantCurrentR, r.InstantCurrentT, err = decodeInstantCurrent(p)
+		case IntegralPowerConsumption:
+			r.CumulativeEnergy, err = decodeCumulativeEnergy(p, scale)
+		case IntegralPowerConsumptionRev:
+			r.CumulativeEnergyReverse, err = decodeCumulativeEnergy(p, scale)
+		case PeriodicalIntegralPowerConsumption:
+			r.FixedTimeEnergy, err = decodeFixedTimeEnergy(p, scale)
+		case PeriodicalIntegralPowerConsumptionRev:
+			r.FixedTimeEnergyReverse, err = decodeFixedTimeEnergy(p, scale)
+		}
+		if errors.Is(err, ErrNoData) {
+			// left zero, and the metric is not updated
+			logger.Printf("%s", err)
+			err = nil
+			continue
+		}
+		if err != nil {
+			return SmartMeterReading{}, err
+		}
+
+		switch PropertyCode(p.Code) {
+		case InstantPower:
+			gpower.Set(float64(r.InstantPower))
+		case InstantCurrent:
+			setCurrentMetrics(r.InstantCurrentR, r.InstantCurrentT)
+		case IntegralPowerConsumption:
+			energy.setCumulative(directionNormal, r.CumulativeEnergy, scale)
+		case IntegralPowerConsumptionRev:
+			energy.setCumulative(directionReverse, r.CumulativeEnergyReverse, scale)
+		case PeriodicalIntegralPowerConsumption:
+			energy.setFixedTime(directionNormal, r.FixedTimeEnergy.Energy, scale)
+		case PeriodicalIntegralPowerConsumptionRev:
+			energy.setFixedTime(directionReverse, r.FixedTimeEnergyReverse.Energy, scale)
+		}
+	}
+	logger.Printf("Power: %d [W] Current: R %.1f [A] T %.1f [A] Energy: %.3f [kWh] Reverse: %.3f [kWh]",
+		r.InstantPower, r.InstantCurrentR, r.InstantCurrentT, r.CumulativeEnergy, r.CumulativeEnergyReverse)
+	return r, nil
+}
+
+func setCurrentMetrics(r, t float64) {
+	gcurrent.WithLabelValues("R").Set(r)
+	if !math.IsNaN(t) {
+		gcurrent.WithLabelValues("T").Set(t)
+	}
+}
+
+func decodeInstantPower(p Property) (int, error) {
+	if len(p.Data) != 4 {
+		return 0, fmt.Errorf("invalid InstantPower length: %d", len(p.Data))
+	}
+	return int(int32(binary.BigEndian.Uint32(p.Data))), nil
+}
+
+// noCurrentT is value of T phase current for single-phase 2-wire system
+const noCurrentT = 0x7FFE
+
+func decodeInstantCurrent(p Property) (float64, float64, error) {
+	if len(p.Data) != 4 {
+		return 0, 0, fmt.Errorf("invalid InstantCurrent length: %d", len(p.Data))
+	}
+	r := int16(binary.BigEndian.Uint16(p.Data[0:2]))
+	t := int16(binary.BigEndian.Uint16(p.Data[2:4]))
+	if t == noCurrentT {
+		return float64(r) / 10, math.NaN(), nil
+	}
+	return float64(r) / 10, float64(t) / 10, nil
+}
+
+func decodeCumulativeEnergy(p Property, scale EnergyScale) (float64, error) {
+	if len(p.Data) != 4 {
+		return 0, fmt.Errorf("invalid CumulativeEnergy length: %d", len(p.Data))
+	}
+	return scale.KWh(binary.BigEndian.Uint32(p.Data)), nil
+}
+
+// ErrNoData is returned if the smart-meter has not measured the value
+var ErrNoData = errors.New("no data")
+
+func decodeFixedTimeEnergy(p Property, scale EnergyScale) (FixedTimeEnergy, error) {
+	// YYYY(2) MM DD hh mm ss and cumulative amounts (4)
+	if len(p.Data) != 11 {
+		return FixedTimeEnergy{}, fmt.Errorf("invalid FixedTimeEnergy length: %d", len(p.Data))
+	}
+	d := p.Data
+	t := time.Date(int(binary.BigEndian.Uint16(d[0:2])), time.Month(d[2]), int(d[3]), int(d[4]), int(d[5]), int(d[6]), 0, time.Local)
+	v := binary.BigEndian.Uint32(d[7:11])
+	if v == noHistoryData {
+		return FixedTimeEnergy{}, fmt.Errorf("FixedTimeEnergy at %s: %w", t.Format("2006-01-02 15:04:05"), ErrNoData)
+	}
+	return FixedTimeEnergy{Time: t, Energy: scale.KWh(v)}, nil
 }
 
 // CreateCurrentPowerConsumptionFrame creates GET current power consumption frame
@@ -2,11 +2,15 @@ package echonetlite
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/u-one/go-el-controller/wisun"
 )
 
@@ -115,7 +119,17 @@ func TestGetPowerConsumption(t *testing.T) {
 					Return([]byte("\x10\x81"), nil)
 			},
 			want: 0,
-			err:  fmt.Errorf("invalid frame: size is too short:2"),
+			err:  fmt.Errorf("invalid frame: size is too short:2: frame truncated"),
+		},
+		{
+			name: "invalid InstantPower length",
+			client: func(m *wisun.MockClient) {
+				m.EXPECT().
+					Send([]byte("\x10\x81\x00\x01\x05\xff\x01\x02\x88\x01\x62\x01\xe7\x00")).
+					Return([]byte("\x10\x81\x00\x01\x02\x88\x01\x05\xff\x01\x72\x01\xe7\x02\x01\xf8"), nil)
+			},
+			want: 0,
+			err:  fmt.Errorf("invalid InstantPower length: 2"),
 		},
 	}
 
@@ -194,3 +208,306 @@ func TestCreateCurrentPowerConsumptionFrame(t *testing.T) {
 		t.Errorf("ParseFrame differs: (-want +got)\n%s", diff)
 	}
 }
+
+// requests and responses of smart-meter
+var (
+	getEnergyScaleReq = []byte("\x10\x81\x00\x01\x05\xff\x01\x02\x88\x01\x62\x03\xd3\x00\xd7\x00\xe1\x00")
+	// coefficient not supported, 6 digits, 0.1kWh
+	getEnergyScaleRes = []byte("\x10\x81\x00\x01\x02\x88\x01\x05\xff\x01\x52\x03\xd3\x00\xd7\x01\x06\xe1\x01\x01")
+)
+
+func TestGetEnergyScale(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name string
+		res  []byte
+		want EnergyScale
+		err  error
+	}{
+		{
+			name: "without coefficient",
+			res:  getEnergyScaleRes,
+			want: EnergyScale{Coefficient: 1, EffectiveDigits: 6, Unit: 0.1},
+		},
+		{
+			name: "with coefficient",
+			res:  []byte("\x10\x81\x00\x01\x02\x88\x01\x05\xff\x01\x72\x03\xd3\x04\x00\x00\x00\x0a\xd7\x01\x06\xe1\x01\x0a"),
+			want: EnergyScale{Coefficient: 10, EffectiveDigits: 6, Unit: 10},
+		},
+		{
+			name: "unit not supported",
+			res:  []byte("\x10\x81\x00\x01\x02\x88\x01\x05\xff\x01\x52\x03\xd3\x00\xd7\x01\x06\xe1\x00"),
+			err:  fmt.Errorf("Get_SNA from smart-meter: EPC[d3] PDC[0] EDT[] EPC[d7] PDC[1] EDT[06] EPC[e1] PDC[0] EDT[]"),
+		},
+		{
+			name: "unknown unit",
+			res:  []byte("\x10\x81\x00\x01\x02\x88\x01\x05\xff\x01\x72\x03\xd3\x04\x00\x00\x00\x01\xd7\x01\x06\xe1\x01\x05"),
+			err:  fmt.Errorf("unknown Unit: 05"),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mock := wisun.NewMockClient(ctrl)
+			mock.EXPECT().Send(getEnergyScaleReq).Return(tc.res, nil)
+
+			node := NewElectricityControllerNode(mock)
+			got, err := node.GetEnergyScale()
+
+			checkError(t, tc.err, err)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("EnergyScale differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetCumulativeEnergy(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock := wisun.NewMockClient(ctrl)
+	gomock.InOrder(
+		mock.EXPECT().Send(getEnergyScaleReq).Return(getEnergyScaleRes, nil),
+		mock.EXPECT().
+			Send([]byte("\x10\x81\x00\x02\x05\xff\x01\x02\x88\x01\x62\x01\xe0\x00")).
+			Return([]byte("\x10\x81\x00\x02\x02\x88\x01\x05\xff\x01\x72\x01\xe0\x04\x00\x00\x30\x39"), nil),
+		// scale is read only once
+		mock.EXPECT().
+			Send([]byte("\x10\x81\x00\x03\x05\xff\x01\x02\x88\x01\x62\x01\xe3\x00")).
+			Return([]byte("\x10\x81\x00\x03\x02\x88\x01\x05\xff\x01\x52\x01\xe3\x00"), nil),
+	)
+
+	node := NewElectricityControllerNode(mock)
+	got, err := node.GetCumulativeEnergy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(1234.5, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("CumulativeEnergy differs: (-want +got)\n%s", diff)
+	}
+
+	_, err = node.GetCumulativeEnergyReverse()
+	checkError(t, fmt.Errorf("Get_SNA from smart-meter: EPC[e3] PDC[0] EDT[]"), err)
+}
+
+func TestGetInstantCurrent(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name  string
+		res   []byte
+		wantR float64
+		wantT float64
+		err   error
+	}{
+		{
+			name:  "three-phase",
+			res:   []byte("\x10\x81\x00\x01\x02\x88\x01\x05\xff\x01\x72\x01\xe8\x04\x00\x32\x00\x14"),
+			wantR: 5.0,
+			wantT: 2.0,
+		},
+		{
+			name:  "single-phase",
+			res:   []byte("\x10\x81\x00\x01\x02\x88\x01\x05\xff\x01\x72\x01\xe8\x04\xff\xf6\x7f\xfe"),
+			wantR: -1.0,
+			wantT: math.NaN(),
+		},
+		{
+			name: "invalid length",
+			res:  []byte("\x10\x81\x00\x01\x02\x88\x01\x05\xff\x01\x72\x01\xe8\x02\x00\x32"),
+			err:  fmt.Errorf("invalid InstantCurrent length: 2"),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mock := wisun.NewMockClient(ctrl)
+			mock.EXPECT().Send([]byte("\x10\x81\x00\x01\x05\xff\x01\x02\x88\x01\x62\x01\xe8\x00")).Return(tc.res, nil)
+
+			node := NewElectricityControllerNode(mock)
+			r, tp, err := node.GetInstantCurrent()
+
+			checkError(t, tc.err, err)
+			if diff := cmp.Diff([]float64{tc.wantR, tc.wantT}, []float64{r, tp}, cmpopts.EquateNaNs()); diff != "" {
+				t.Errorf("currents differ: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetReadings(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock := wisun.NewMockClient(ctrl)
+	gomock.InOrder(
+		mock.EXPECT().Send(getEnergyScaleReq).Return(getEnergyScaleRes, nil),
+		mock.EXPECT().
+			Send([]byte("\x10\x81\x00\x02\x05\xff\x01\x02\x88\x01\x62\x06\xe7\x00\xe8\x00\xe0\x00\xe3\x00\xea\x00\xeb\x00")).
+			Return([]byte("\x10\x81\x00\x02\x02\x88\x01\x05\xff\x01\x52\x06"+
+				"\xe7\x04\xff\xff\xff\x9c"+
+				"\xe8\x04\x00\x32\x7f\xfe"+
+				"\xe0\x04\x00\x00\x30\x39"+
+				"\xe3\x00"+
+				"\xea\x0b\x07\xea\x0a\x11\x0c\x00\x00\x00\x00\x30\x30"+
+				"\xeb\x00"), nil),
+	)
+
+	node := NewElectricityControllerNode(mock)
+	got, err := node.GetReadings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := SmartMeterReading{
+		InstantPower:     -100,
+		InstantCurrentR:  5.0,
+		InstantCurrentT:  math.NaN(),
+		CumulativeEnergy: 1234.5,
+		FixedTimeEnergy: FixedTimeEnergy{
+			Time:   time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local),
+			Energy: 1233.6,
+		},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateNaNs(), cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("SmartMeterReading differs: (-want +got)\n%s", diff)
+	}
+}
+
+func TestHandleNotifications(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock := wisun.NewMockClient(ctrl)
+
+	ctx := context.Background()
+	frames := make(chan []byte, 3)
+	// INF of 0xEA, INFC of 0xEA and 0xEB, and unrelated INF of instance list
+	frames <- []byte("\x10\x81\x00\x05\x02\x88\x01\x05\xff\x01\x73\x01\xea\x0b\x07\xea\x0a\x11\x0c\x00\x00\x00\x00\x30\x30")
+	frames <- []byte("\x10\x81\x00\x06\x02\x88\x01\x05\xff\x01\x74\x02" +
+		"\xea\x0b\x07\xea\x0a\x11\x0c\x1e\x00\x00\x00\x30\x39" +
+		"\xeb\x0b\x07\xea\x0a\x11\x0c\x1e\x00\x00\x00\x00\x0a")
+	frames <- []byte("\x10\x81\x00\x07\x0e\xf0\x01\x0e\xf0\x01\x73\x01\xd5\x04\x01\x02\x88\x01")
+	close(frames)
+
+	gomock.InOrder(
+		mock.EXPECT().Listen(ctx).Return((<-chan []byte)(frames)),
+		mock.EXPECT().Send(getEnergyScaleReq).Return(getEnergyScaleRes, nil),
+		mock.EXPECT().SendOnly([]byte("\x10\x81\x00\x06\x05\xff\x01\x02\x88\x01\x7a\x02\xea\x00\xeb\x00")).Return(nil),
+	)
+
+	node := NewElectricityControllerNode(mock)
+	node.HandleNotifications(ctx)
+
+	normal, reverse := node.NotifiedFixedTimeEnergy()
+	want := []FixedTimeEnergy{
+		{Time: time.Date(2026, 10, 17, 12, 30, 0, 0, time.Local), Energy: 1234.5},
+		{Time: time.Date(2026, 10, 17, 12, 30, 0, 0, time.Local), Energy: 1.0},
+	}
+	if diff := cmp.Diff(want, []FixedTimeEnergy{normal, reverse}, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("FixedTimeEnergy differs: (-want +got)\n%s", diff)
+	}
+}
+
+func Test_decodeFixedTimeEnergy(t *testing.T) {
+	scale := EnergyScale{Coefficient: 1, EffectiveDigits: 6, Unit: 0.1}
+	testcases := []struct {
+		name  string
+		input string
+		want  FixedTimeEnergy
+		err   error
+	}{
+		{
+			name:  "measured",
+			input: "\x07\xea\x0a\x11\x0c\x00\x00\x00\x00\x30\x30",
+			want:  FixedTimeEnergy{Time: time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local), Energy: 1233.6},
+		},
+		{
+			name:  "no data",
+			input: "\x07\xea\x0a\x11\x0c\x00\x00\xff\xff\xff\xfe",
+			err:   fmt.Errorf("FixedTimeEnergy at 2026-10-17 12:00:00: %w", ErrNoData),
+		},
+		{
+			name:  "invalid length",
+			input: "\x07\xea\x0a\x11\x0c\x00\x00\x00\x30\x30",
+			err:   fmt.Errorf("invalid FixedTimeEnergy length: 10"),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := Property{Code: byte(PeriodicalIntegralPowerConsumption), Len: len(tc.input), Data: Data(tc.input)}
+			got, err := decodeFixedTimeEnergy(p, scale)
+			checkError(t, tc.err, err)
+			if errors.Is(tc.err, ErrNoData) != errors.Is(err, ErrNoData) {
+				t.Errorf("Diffrent result: want:%v, got:%v", tc.err, err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_energyCounter(t *testing.T) {
+	testcases := []struct {
+		name   string
+		scale  EnergyScale
+		values []float64
+		want   float64
+	}{
+		{
+			name:   "increase",
+			scale:  EnergyScale{Coefficient: 1, EffectiveDigits: 6, Unit: 0.1},
+			values: []float64{1000.0, 1000.5, 1002.0},
+			want:   1002.0,
+		},
+		{
+			name:   "rollover",
+			scale:  EnergyScale{Coefficient: 1, EffectiveDigits: 6, Unit: 0.1},
+			values: []float64{99999.5, 99999.9, 0.4},
+			want:   100000.4,
+		},
+		{
+			name:   "reset without effective digits",
+			scale:  EnergyScale{Coefficient: 1, Unit: 0.1},
+			values: []float64{99999.5, 0.4},
+			want:   0.4,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			counters := map[string]*energyCounter{}
+			for _, v := range tc.values {
+				updateCounter(counters, directionNormal, v, tc.scale)
+			}
+			if diff := cmp.Diff(tc.want, counters[directionNormal].total, cmpopts.EquateApprox(0, 1e-6)); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
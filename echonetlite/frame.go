@@ -2,6 +2,7 @@ package echonetlite
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -35,14 +36,19 @@ const (
 )
 
 // Frame is Echonet-Lite frame
+// For SetGet, SetGet_Res and SetGet_SNA, OPC and Properties hold the OPCSet block
+// and OPCGet and GetProperties hold the OPCGet block which follows it.
 type Frame struct {
-	EHD        Data    // Echonet Lite Header
-	TID        Data    // Transaction ID
-	SEOJ       Object  // Source Echonet Lite Object
-	DEOJ       Object  // Destination Echonet Lite Object
-	ESV        ESVType // Echonet Lite Service
-	OPC        byte    // Num of Properties
-	Properties []Property
+	EHD           Data    // Echonet Lite Header
+	TID           Data    // Transaction ID
+	SEOJ          Object  // Source Echonet Lite Object
+	DEOJ          Object  // Destination Echonet Lite Object
+	ESV           ESVType // Echonet Lite Service
+	OPC           byte    // Num of Properties
+	Properties    []Property
+	OPCGet        byte // Num of Properties to get (SetGet only)
+	GetProperties []Property
+	Payload       Data // EDATA of arbitrary message format (EHD2 = 0x82)
 }
 
 // NewFrame retunrs Frame
@@ -59,8 +65,32 @@ func NewFrame(transID uint16, src, dest Object, service ESVType, props []Propert
 	return f
 }
 
+// NewSetGetFrame returns Frame which has both of set and get properties
+func NewSetGetFrame(transID uint16, src, dest Object, service ESVType, sets, gets []Property) Frame {
+	f := NewFrame(transID, src, dest, service, sets)
+	f.OPCGet = byte(len(gets))
+	f.GetProperties = gets
+	return f
+}
+
+// NewArbitraryFrame returns Frame in arbitrary message format which carries payload as EDATA
+func NewArbitraryFrame(transID uint16, payload Data) Frame {
+	ehd := []byte{byte(EchonetLite), byte(ArbitraryFormat)}
+	tid := []byte{byte(transID >> 8 & 0xFF), byte(transID & 0xFF)}
+
+	return Frame{EHD: ehd, TID: tid, Payload: payload}
+}
+
+// IsArbitrary returns true if the frame is in arbitrary message format
+func (f Frame) IsArbitrary() bool {
+	return len(f.EHD) == ehdLen && f.EHD[1] == ArbitraryFormat
+}
+
 // EData returns serialized EDATA part
 func (f Frame) EData() Data {
+	if f.IsArbitrary() {
+		return append(Data{}, f.Payload...)
+	}
 	eData := []byte{}
 	eData = append(eData, f.SEOJ.Data()...)
 	eData = append(eData, f.DEOJ.Data()...)
@@ -69,6 +99,12 @@ func (f Frame) EData() Data {
 	for _, p := range f.Properties {
 		eData = append(eData, p.Serialize()...)
 	}
+	if f.ESV.isSetGet() {
+		eData = append(eData, f.OPCGet)
+		for _, p := range f.GetProperties {
+			eData = append(eData, p.Serialize()...)
+		}
+	}
 	return eData
 }
 
@@ -81,47 +117,102 @@ func (f Frame) Serialize() Data {
 	return frame
 }
 
+// Errors returned by ParseFrame
+var (
+	// ErrTruncated is returned when data ends before all fields of the frame are read
+	ErrTruncated = errors.New("frame truncated")
+	// ErrUnsupportedEHD is returned when EHD1/EHD2 is not a known ECHONET Lite header
+	ErrUnsupportedEHD = errors.New("unsupported EHD")
+	// ErrOPCMismatch is returned when properties do not match the count given by OPC
+	ErrOPCMismatch = errors.New("OPC mismatch")
+)
+
+const (
+	ehdLen        = 2
+	tidLen        = 2
+	eDataFixedLen = 8 // SEOJ(3) + DEOJ(3) + ESV(1) + OPC(1)
+	headerLen     = ehdLen + tidLen + eDataFixedLen
+)
+
 // ParseFrame returns Frame
 func ParseFrame(data []byte) (Frame, error) {
-	if len(data) < 9 {
-		return Frame{}, fmt.Errorf("size is too short:%d", len(data))
+	if len(data) < ehdLen {
+		return Frame{}, fmt.Errorf("size is too short:%d: %w", len(data), ErrTruncated)
 	}
 	frame := Data(data)
-	EHD := frame[:2]
-	TID := frame[2:4]
-	EDATA := frame[4:]
+	EHD := frame[:ehdLen]
+	if EHD[0] != EchonetLite {
+		return Frame{}, fmt.Errorf("EHD1[%02x]: %w", EHD[0], ErrUnsupportedEHD)
+	}
+	switch EHD[1] {
+	case FixedFormat:
+	case ArbitraryFormat:
+		if len(data) < ehdLen+tidLen {
+			return Frame{}, fmt.Errorf("size is too short:%d: %w", len(data), ErrTruncated)
+		}
+		TID := frame[ehdLen : ehdLen+tidLen]
+		return Frame{EHD: EHD, TID: TID, Payload: frame[ehdLen+tidLen:]}, nil
+	default:
+		return Frame{}, fmt.Errorf("EHD2[%02x]: %w", EHD[1], ErrUnsupportedEHD)
+	}
+	if len(data) < headerLen {
+		return Frame{}, fmt.Errorf("size is too short:%d: %w", len(data), ErrTruncated)
+	}
+	TID := frame[ehdLen : ehdLen+tidLen]
+	EDATA := frame[ehdLen+tidLen:]
 	SEOJ := NewObjectFromData(EDATA[:3])
 	DEOJ := NewObjectFromData(EDATA[3:6])
-	ESV := ESVType(EDATA[6:7][0])
-	OPC := EDATA[7:8][0]
-
-	pNum := int(OPC)
-	props := make([]Property, 0, pNum)
-
-	epcOffsetBase := 8
-	epcOffset := epcOffsetBase
-	for i := 0; i < pNum; i++ {
-		EPC := EDATA[epcOffset : epcOffset+1]
-		PDC := EDATA[epcOffset+1 : epcOffset+2]
-		propertyValueLen := int(PDC[0])
-		if len(EDATA) < epcOffset+2+propertyValueLen {
-			return Frame{}, fmt.Errorf("invalid EDT length")
-		}
-		EDT := EDATA[epcOffset+2 : epcOffset+2+propertyValueLen]
-
-		props = append(props, Property{Code: EPC[0], Len: int(PDC[0]), Data: EDT})
+	ESV := ESVType(EDATA[6])
+	OPC := EDATA[7]
 
-		epcOffset += (2 + propertyValueLen)
+	props, offset, err := decodeProperties(EDATA, eDataFixedLen, int(OPC))
+	if err != nil {
+		return Frame{}, err
 	}
 
 	f := Frame{EHD: EHD, TID: TID, SEOJ: SEOJ, DEOJ: DEOJ, ESV: ESV, OPC: OPC, Properties: props}
+
+	if ESV.isSetGet() {
+		if len(EDATA) < offset+1 {
+			return Frame{}, fmt.Errorf("OPCGet missing: %w", ErrTruncated)
+		}
+		f.OPCGet = EDATA[offset]
+		f.GetProperties, offset, err = decodeProperties(EDATA, offset+1, int(f.OPCGet))
+		if err != nil {
+			return Frame{}, err
+		}
+	}
+
+	if offset != len(EDATA) {
+		return Frame{}, fmt.Errorf("%d bytes left after %d properties: %w", len(EDATA)-offset, int(OPC)+int(f.OPCGet), ErrOPCMismatch)
+	}
 	return f, nil
 }
 
+// decodeProperties decodes num properties from data starting at offset,
+// and returns them with the offset just after the last one
+func decodeProperties(data Data, offset, num int) ([]Property, int, error) {
+	props := make([]Property, 0, num)
+	for i := 0; i < num; i++ {
+		if len(data) < offset+2 {
+			return nil, offset, fmt.Errorf("EPC/PDC of property %d/%d missing: %w", i+1, num, ErrTruncated)
+		}
+		EPC := data[offset]
+		PDC := int(data[offset+1])
+		if len(data) < offset+2+PDC {
+			return nil, offset, fmt.Errorf("invalid EDT length: %w", ErrTruncated)
+		}
+		EDT := data[offset+2 : offset+2+PDC]
+
+		props = append(props, Property{Code: EPC, Len: PDC, Data: EDT})
+
+		offset += 2 + PDC
+	}
+	return props, offset, nil
+}
+
 // parseProperties parses properties
 func parseProperties(obj Object, properties []Property) (interface{}, error) {
-	logger.Printf("ParseProperties: %v", properties)
-
 	switch obj.classGroupCode() {
 	case ProfileGroup:
 		switch obj.classCode() {
@@ -136,11 +227,11 @@ func parseProperties(obj Object, properties []Property) (interface{}, error) {
 		switch obj.classCode() {
 		case HomeAirConditioner:
 			logger.Println("エアコン")
-			obj := AirconObject{}
+			ao := AirconObject{}
 			for _, p := range properties {
-				parseHomeAirConditionerProperty(p, &obj)
+				parseHomeAirConditionerProperty(obj, p, &ao)
 			}
-			return obj, nil
+			return ao, nil
 		}
 		break
 	}
@@ -157,8 +248,10 @@ type SuperObject struct {
 // AirconObject is object for aircon
 type AirconObject struct {
 	SuperObject
-	InternalTemp float64
-	OuterTemp    float64
+	InternalTemp    float64
+	OuterTemp       float64
+	HasInternalTemp bool // false if room temperature is not received or unmeasurable
+	HasOuterTemp    bool // false if outdoor temperature is not received or unmeasurable
 }
 
 func parseSuperObjectProperty(p Property) bool {
@@ -194,7 +287,13 @@ func parseSuperObjectProperty(p Property) bool {
 		return true
 	case ClassListS:
 		return true
-	case GetPropertyMap: //0x9F
+	case StageChangeAnnouncePropertyMap, SetPropertyMap, GetPropertyMap: // 0x9D-0x9F
+		m, err := ParsePropertyMap(p.Data)
+		if err != nil {
+			logger.Printf("[Error] PropertyMap[%x]: %s", p.Code, err)
+			return true
+		}
+		logger.Printf("PropertyMap[%x]: %s", p.Code, m)
 		return true
 	}
 	return false
@@ -206,6 +305,10 @@ func parseNodeProfileProperty(p Property) bool {
 	}
 
 	logger.Printf("Property Code: %x, %#v\n", p.Code, p.Data)
+	if len(p.Data) == 0 {
+		// properties of requests, e.g. own Get received by multicast loopback
+		return false
+	}
 	switch PropertyCode(p.Code) {
 	case NumOfInstances: // 0xD3
 		logger.Printf("Num of instances: %x", p.Data)
@@ -234,7 +337,7 @@ func parseNodeProfileProperty(p Property) bool {
 	return false
 }
 
-func parseHomeAirConditionerProperty(p Property, obj *AirconObject) bool {
+func parseHomeAirConditionerProperty(o Object, p Property, obj *AirconObject) bool {
 	if parseSuperObjectProperty(p) {
 		return true
 	}
@@ -277,22 +380,32 @@ func parseHomeAirConditionerProperty(p Property, obj *AirconObject) bool {
 		}
 		return true
 	case MeasuredRoomTemperature:
-		if p.Len != 1 {
-			logger.Printf("[Error] MeasuredRoomTemperature invalid length: %d", p.Len)
+		temp, err := decodingCodecs().DecodeNumber(o, p)
+		if errors.Is(err, ErrOutOfRange) {
+			logger.Printf("MeasuredRoomTemperature unmeasurable")
+			return true
+		}
+		if err != nil {
+			logger.Printf("[Error] MeasuredRoomTemperature: %s", err)
 			return true
 		}
-		temp := int(p.Data[0])
-		obj.InternalTemp = float64(temp)
-		logger.Printf("室温:%d℃\n", temp)
+		obj.InternalTemp = float64(temp.Value)
+		obj.HasInternalTemp = true
+		logger.Printf("室温:%s\n", temp)
 		return true
 	case MeasuredOutdoorTemperature:
-		if p.Len != 1 {
-			logger.Printf("[Error] MeasuredOutdoorTemperature invalid length: %d", p.Len)
+		temp, err := decodingCodecs().DecodeNumber(o, p)
+		if errors.Is(err, ErrOutOfRange) {
+			logger.Printf("MeasuredOutdoorTemperature unmeasurable")
 			return true
 		}
-		temp := int(p.Data[0])
-		obj.OuterTemp = float64(temp)
-		logger.Printf("外気温:%d℃\n", temp)
+		if err != nil {
+			logger.Printf("[Error] MeasuredOutdoorTemperature: %s", err)
+			return true
+		}
+		obj.OuterTemp = float64(temp.Value)
+		obj.HasOuterTemp = true
+		logger.Printf("外気温:%s\n", temp)
 		return true
 	}
 	return false
@@ -308,11 +421,35 @@ func (f Frame) DstObj() Object {
 	return f.DEOJ
 }
 
+// TargetObj returns Object which properties of the frame belong to
+// i.e. SEOJ for responses and notifications, DEOJ for requests
+func (f Frame) TargetObj() Object {
+	if f.ESV.isResponseOrNotification() {
+		return f.SrcObj()
+	}
+	return f.DstObj()
+}
+
 // String returns string
 func (f Frame) String() string {
+	if f.IsArbitrary() {
+		return fmt.Sprintf("%s EHD[%s] TID[%s] EDATA[%s]", f.Serialize(), f.EHD, f.TID, f.Payload)
+	}
+	obj := f.TargetObj()
+	if f.ESV.isSetGet() {
+		str := fmt.Sprintf("%s EHD[%s] TID[%s] SEOJ[%s] DEOJ[%s] ESV[%s] OPCSet[%d]", f.Serialize(), f.EHD, f.TID, f.SEOJ, f.DEOJ, f.ESV, f.OPC)
+		for i, p := range f.Properties {
+			str = str + fmt.Sprintf(" %d %s", i, p.Format(obj))
+		}
+		str = str + fmt.Sprintf(" OPCGet[%d]", f.OPCGet)
+		for i, p := range f.GetProperties {
+			str = str + fmt.Sprintf(" %d %s", i, p.Format(obj))
+		}
+		return str
+	}
 	str := fmt.Sprintf("%s EHD[%s] TID[%s] SEOJ[%s] DEOJ[%s] ESV[%s] OPC[%d]", f.Serialize(), f.EHD, f.TID, f.SEOJ, f.DEOJ, f.ESV, f.OPC)
 	for i, p := range f.Properties {
-		str = str + fmt.Sprintf(" %d %s", i, p)
+		str = str + fmt.Sprintf(" %d %s", i, p.Format(obj))
 	}
 	return str
 }
@@ -393,6 +530,9 @@ func CreateGetFrame(transID uint16) *Frame {
 	return &frame
 }
 
+// airconStateProperties is properties to get air conditioner states
+var airconStateProperties = []PropertyCode{InstallationLocation, ID, MeasuredRoomTemperature, MeasuredOutdoorTemperature}
+
 // CreateAirconGetFrame creates GET air-con info frame
 func CreateAirconGetFrame(transID uint16) *Frame {
 	// Get
@@ -408,3 +548,37 @@ func CreateAirconGetFrame(transID uint16) *Frame {
 	frame := NewFrame(transID, src, dest, Get, props)
 	return &frame
 }
+
+// CreateSetGetFrame creates SetGet frame which writes sets and reads gets of dest
+func CreateSetGetFrame(transID uint16, dest Object, sets []Property, gets []PropertyCode) *Frame {
+	src := NewObject(ControllerGroup, Controller, 0x01)
+
+	getProps := []Property{}
+	for _, code := range gets {
+		getProps = append(getProps, Property{Code: byte(code), Len: 0, Data: []byte{}})
+	}
+
+	frame := NewSetGetFrame(transID, src, dest, SetGet, sets, getProps)
+	return &frame
+}
+
+// CreateGetPropertiesFrame creates GET frame which reads epcs of dest
+func CreateGetPropertiesFrame(transID uint16, dest Object, epcs []PropertyCode) *Frame {
+	src := NewObject(ControllerGroup, Controller, 0x01)
+
+	props := []Property{}
+	for _, code := range epcs {
+		props = append(props, Property{Code: byte(code), Len: 0, Data: []byte{}})
+	}
+
+	frame := NewFrame(transID, src, dest, Get, props)
+	return &frame
+}
+
+// CreateSetCFrame creates SetC frame which writes props of dest
+func CreateSetCFrame(transID uint16, dest Object, props []Property) *Frame {
+	src := NewObject(ControllerGroup, Controller, 0x01)
+
+	frame := NewFrame(transID, src, dest, SetC, props)
+	return &frame
+}
@@ -0,0 +1,40 @@
+//go:build go1.18
+// +build go1.18
+
+package echonetlite
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func FuzzParseFrame(f *testing.F) {
+	f.Add([]byte{0x10, 0x81, 0x0, 0x0, 0x05, 0xff, 0x01, 0x01, 0x30, 0x01, 0x62, 0x02, 0xbb, 0x00, 0xbe, 0x00})
+	f.Add([]byte("\x10\x81\x00\x01\x02\x88\x01\x05\xff\x01\x72\x01\xe7\x04\x00\x00\x01\xf8"))
+	f.Add([]byte("\x10\x81\x00\x02\x0e\xf0\x01\x05\xff\x01\x52\x02\x80\x01\x30\x9f\x0e\x0d\x80\x82\x83\x89\x8a\x9d\x9e\x9f\xbf\xd3\xd4\xd6\xd7"))
+	f.Add([]byte("\x10\x81\x00\x03\x01\x30\x01\x05\xff\x01\x7e\x01\x80\x00\x02\x81\x01\x3f\xb3\x01\x18"))
+	f.Add([]byte{0x10, 0x82, 0x00, 0x05, 0xde, 0xad, 0xbe, 0xef})
+	f.Add([]byte{0x10, 0x81, 0x0, 0x1, 0x2, 0x88, 0x1, 0x5, 0xff, 0x1, 0x72, 0x1, 0xe7, 0x4, 0x0, 0x0, 0x3})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		frame, err := ParseFrame(data)
+		if err != nil {
+			return
+		}
+
+		serialized := frame.Serialize()
+		if !bytes.Equal(data, serialized) {
+			t.Fatalf("Serialize differs: want:%x, got:%x", data, serialized)
+		}
+
+		reparsed, err := ParseFrame(serialized)
+		if err != nil {
+			t.Fatalf("failed to parse serialized frame %x: %s", serialized, err)
+		}
+		if diff := cmp.Diff(frame, reparsed); diff != "" {
+			t.Errorf("ParseFrame differs: (-want +got)\n%s", diff)
+		}
+	})
+}
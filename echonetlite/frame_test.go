@@ -2,6 +2,7 @@ package echonetlite
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -112,11 +113,100 @@ func Test_ParseFrame(t *testing.T) {
 			wantData:  toData(t, "108100020ef00105ff0152088001308204010c0100d303000001d4020002d500d60401013001d7030101309f0e0d808283898a9d9e9fbfd3d4d6d7"),
 			wantEData: toData(t, "0ef00105ff0152088001308204010c0100d303000001d4020002d500d60401013001d7030101309f0e0d808283898a9d9e9fbfd3d4d6d7"),
 		},
+		{
+			name:  "SetGet_Res",
+			input: toByteArray(t, "1081000301300105ff017e0180000281013fb30118"),
+			wantFrame: Frame{
+				EHD:  toData(t, "1081"),
+				TID:  toData(t, "0003"),
+				SEOJ: NewObjectFromData(toData(t, "013001")),
+				DEOJ: NewObjectFromData(toData(t, "05ff01")),
+				ESV:  SetGetRes,
+				OPC:  0x01,
+				Properties: []Property{
+					{Code: 0x80, Len: 0, Data: toData(t, "")},
+				},
+				OPCGet: 0x02,
+				GetProperties: []Property{
+					{Code: 0x81, Len: 1, Data: toData(t, "3f")},
+					{Code: 0xb3, Len: 1, Data: toData(t, "18")},
+				},
+			},
+			wantData:  toData(t, "1081000301300105ff017e0180000281013fb30118"),
+			wantEData: toData(t, "01300105ff017e0180000281013fb30118"),
+		},
+		{
+			name:      "SetGet OPCGet missing",
+			input:     toByteArray(t, "1081000305ff010130016e01800130"),
+			wantFrame: Frame{},
+			wantErr:   fmt.Errorf("OPCGet missing: frame truncated"),
+			wantData:  []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			wantEData: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:  "arbitrary format",
+			input: toByteArray(t, "10820005deadbeef0123"),
+			wantFrame: Frame{
+				EHD:     toData(t, "1082"),
+				TID:     toData(t, "0005"),
+				Payload: toData(t, "deadbeef0123"),
+			},
+			wantData:  toData(t, "10820005deadbeef0123"),
+			wantEData: toData(t, "deadbeef0123"),
+		},
+		{
+			name:      "arbitrary format TID missing",
+			input:     toByteArray(t, "108200"),
+			wantFrame: Frame{},
+			wantErr:   fmt.Errorf("size is too short:3: frame truncated"),
+			wantData:  []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			wantEData: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
 		{
 			name:      "invalid EDT length",
 			input:     []byte{0x10, 0x81, 0x0, 0x1, 0x2, 0x88, 0x1, 0x5, 0xff, 0x1, 0x72, 0x1, 0xe7, 0x4, 0x0, 0x0, 0x3},
 			wantFrame: Frame{},
-			wantErr:   fmt.Errorf("invalid EDT length"),
+			wantErr:   fmt.Errorf("invalid EDT length: frame truncated"),
+			wantData:  []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			wantEData: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:      "EPC missing",
+			input:     toByteArray(t, "1081000102880105ff017202e70400000100"),
+			wantFrame: Frame{},
+			wantErr:   fmt.Errorf("EPC/PDC of property 2/2 missing: frame truncated"),
+			wantData:  []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			wantEData: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:      "header too short",
+			input:     toByteArray(t, "1081000102880105ff0172"),
+			wantFrame: Frame{},
+			wantErr:   fmt.Errorf("size is too short:11: frame truncated"),
+			wantData:  []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			wantEData: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:      "unsupported EHD1",
+			input:     toByteArray(t, "1181000102880105ff017201e70400000100"),
+			wantFrame: Frame{},
+			wantErr:   fmt.Errorf("EHD1[11]: unsupported EHD"),
+			wantData:  []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			wantEData: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:      "unsupported EHD2",
+			input:     toByteArray(t, "1083000102880105ff017201e70400000100"),
+			wantFrame: Frame{},
+			wantErr:   fmt.Errorf("EHD2[83]: unsupported EHD"),
+			wantData:  []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			wantEData: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:      "trailing garbage",
+			input:     toByteArray(t, "1081000102880105ff017201e70400000100ff"),
+			wantFrame: Frame{},
+			wantErr:   fmt.Errorf("1 bytes left after 1 properties: OPC mismatch"),
 			wantData:  []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
 			wantEData: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
 		},
@@ -157,6 +247,32 @@ func Test_ParseFrame(t *testing.T) {
 
 }
 
+func Test_ParseFrame_ErrorType(t *testing.T) {
+	testcases := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{name: "empty", input: "", want: ErrTruncated},
+		{name: "EDT truncated", input: "1081000102880105ff017201e7040000", want: ErrTruncated},
+		{name: "EHD", input: "0081000102880105ff017201e70400000100", want: ErrUnsupportedEHD},
+		{name: "OPC", input: "1081000102880105ff017200e70400000100", want: ErrOPCMismatch},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseFrame(toByteArray(t, tc.input))
+			if !errors.Is(err, tc.want) {
+				t.Errorf("Diffrent result: want:%v, got:%v", tc.want, err)
+			}
+		})
+	}
+}
+
 func TestFrame_PerseProperties(t *testing.T) {
 	input := Frame{
 		EHD:  toData(t, "1081"),
@@ -174,9 +290,11 @@ func TestFrame_PerseProperties(t *testing.T) {
 	}
 
 	want := AirconObject{
-		SuperObject:  SuperObject{InstallLocation: Location{Code: Room, Number: 1}},
-		InternalTemp: 28,
-		OuterTemp:    25,
+		SuperObject:     SuperObject{InstallLocation: Location{Code: Room, Number: 1}},
+		InternalTemp:    28,
+		OuterTemp:       25,
+		HasInternalTemp: true,
+		HasOuterTemp:    true,
 	}
 
 	got, err := parseProperties(input.SrcObj(), input.Properties)
@@ -189,6 +307,49 @@ func TestFrame_PerseProperties(t *testing.T) {
 	}
 }
 
+func Test_parseHomeAirConditionerProperty(t *testing.T) {
+	obj := NewObject(AirConditionerGroup, HomeAirConditioner, 1)
+	testcases := []struct {
+		name  string
+		input Property
+		want  AirconObject
+	}{
+		{name: "room", input: Property{Code: 0xbb, Len: 1, Data: toData(t, "1c")}, want: AirconObject{InternalTemp: 28, HasInternalTemp: true}},
+		{name: "below zero", input: Property{Code: 0xbe, Len: 1, Data: toData(t, "f6")}, want: AirconObject{OuterTemp: -10, HasOuterTemp: true}},
+		{name: "unmeasurable", input: Property{Code: 0xbe, Len: 1, Data: toData(t, "7e")}, want: AirconObject{}},
+		{name: "invalid length", input: Property{Code: 0xbb, Len: 2, Data: toData(t, "001c")}, want: AirconObject{}},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := AirconObject{}
+			if !parseHomeAirConditionerProperty(obj, tc.input, &got) {
+				t.Errorf("not parsed")
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFrame_ParsePropertiesOfRequest(t *testing.T) {
+	// properties of Get have no EDT
+	input := CreateGetFrame(0)
+
+	got, err := parseProperties(input.DstObj(), input.Properties)
+	if err != nil {
+		t.Error(err)
+	}
+	if got != nil {
+		t.Errorf("Diffrent result: want:nil, got:%#v", got)
+	}
+}
+
 func TestLocationCode(t *testing.T) {
 
 	want := LocationCode(0x1)
@@ -222,6 +383,87 @@ func TestNewFrame(t *testing.T) {
 
 }
 
+func TestCreateSetGetFrame(t *testing.T) {
+	dest := NewObject(AirConditionerGroup, HomeAirConditioner, 0x01)
+	sets := []Property{{Code: 0xb3, Len: 1, Data: Data{0x19}}}
+	gets := []PropertyCode{OperationStatus, 0xb3}
+
+	got := CreateSetGetFrame(0x10, dest, sets, gets)
+
+	wantData := toData(t, "1081001005ff010130016e01b30119028000b300")
+	if diff := cmp.Diff(wantData, got.Serialize()); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+
+	want, err := ParseFrame(wantData)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if diff := cmp.Diff(want, *got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+
+	wantString := "1081001005ff010130016e01b30119028000b300 EHD[1081] TID[0010] SEOJ[05 ff 01] DEOJ[01 30 01] ESV[SetGet] OPCSet[1] 0 EPC[b3] PDC[1] EDT[19] OPCGet[2] 0 EPC[80] PDC[0] EDT[] 1 EPC[b3] PDC[0] EDT[]"
+	if diff := cmp.Diff(wantString, got.String()); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
+func TestFrame_String(t *testing.T) {
+	// a codec of the class only, which formats properties of frames of it
+	light := NewObject(0x02, 0x90, 0x01)
+	codecs.Register(0x02, 0x90, 0xf0, NumberCodec{Size: 1, Unit: "%"})
+	props := []Property{{Code: 0xf0, Len: 1, Data: Data{0x32}}}
+
+	testcases := []struct {
+		name  string
+		input Frame
+		want  string
+	}{
+		{
+			name:  "Get_Res",
+			input: NewFrame(0x01, light, NewObject(ControllerGroup, Controller, 0x01), GetRes, props),
+			want:  "1081000102900105ff017201f00132 EHD[1081] TID[0001] SEOJ[02 90 01] DEOJ[05 ff 01] ESV[Get_Res] OPC[1] 0 EPC[f0] PDC[1] EDT[32](50 %)",
+		},
+		{
+			name:  "SetGet_Res",
+			input: NewSetGetFrame(0x01, light, NewObject(ControllerGroup, Controller, 0x01), SetGetRes, []Property{{Code: 0xf0, Len: 0, Data: Data{}}}, props),
+			want:  "1081000102900105ff017e01f00001f00132 EHD[1081] TID[0001] SEOJ[02 90 01] DEOJ[05 ff 01] ESV[SetGet_Res] OPCSet[1] 0 EPC[f0] PDC[0] EDT[] OPCGet[1] 0 EPC[f0] PDC[1] EDT[32](50 %)",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tc.want, tc.input.String()); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewArbitraryFrame(t *testing.T) {
+	f := NewArbitraryFrame(0x0102, Data{0xca, 0xfe})
+
+	if !f.IsArbitrary() {
+		t.Errorf("IsArbitrary should be true")
+	}
+
+	got := f.Serialize()
+	want := Data{0x10, 0x82, 0x01, 0x02, 0xca, 0xfe}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+
+	wantString := "10820102cafe EHD[1082] TID[0102] EDATA[cafe]"
+	if diff := cmp.Diff(wantString, f.String()); diff != "" {
+		t.Errorf("(-want +got):\n%s", diff)
+	}
+}
+
 func TestCreateInfFrame(t *testing.T) {
 	got := CreateInfFrame(1)
 	wantdata := []byte{0x10, 0x81, 0x00, 0x01, 0x0e, 0xf0, 0x01, 0x0e, 0xf0, 0x01, 0x73, 0x01, 0xd5, 0x04, 0x01, 0x05, 0xff, 0x01}
@@ -0,0 +1,98 @@
+package echonetlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/u-one/go-el-controller/transport"
+)
+
+// MulticastIPv6 is Echonet-Lite multicast address on IPv6, all nodes on the link
+const MulticastIPv6 = "ff02::1"
+
+// NetworkConfig selects the network which a node communicates on
+type NetworkConfig struct {
+	Interface string // name, e.g. "eth0", or CIDR of the address, e.g. "192.168.1.0/24", of the interface. Chosen by the system if empty.
+	IPv6      bool   // use MulticastIPv6 instead of MulticastIP. Interface is required, as MulticastIPv6 is scoped to a link.
+}
+
+// MulticastGroup returns the multicast address of the network
+func (cfg NetworkConfig) MulticastGroup() string {
+	if cfg.IPv6 {
+		return MulticastIPv6
+	}
+	return MulticastIP
+}
+
+// udpTransports are transports of a node on UDP
+type udpTransports struct {
+	group             string
+	multicastReceiver transport.MulticastReceiver
+	unicastReceiver   transport.UnicastReceiver
+	multicastSender   transport.MulticastSender
+	unicastSender     transport.UnicastSender
+}
+
+// openUDPTransports opens UDP transports on the network of cfg
+func openUDPTransports(cfg NetworkConfig) (udpTransports, error) {
+	if cfg.IPv6 && cfg.Interface == "" {
+		return udpTransports{}, fmt.Errorf("interface is required for IPv6, on whose link %s is sent", MulticastIPv6)
+	}
+	ifi, err := transport.ResolveInterface(cfg.Interface)
+	if err != nil {
+		return udpTransports{}, err
+	}
+	group := cfg.MulticastGroup()
+	ms, err := transport.NewUDPMulticastSenderOn(ifi, group, Port)
+	if err != nil {
+		return udpTransports{}, err
+	}
+	us, err := transport.NewUDPUnicastSenderOn(ifi, cfg.IPv6, Port)
+	if err != nil {
+		ms.Close()
+		return udpTransports{}, err
+	}
+	return udpTransports{
+		group:             group,
+		multicastReceiver: &transport.UDPMulticastReceiver{Interface: ifi},
+		unicastReceiver:   &transport.UDPUnicastReceiver{Interface: ifi, IPv6: cfg.IPv6},
+		multicastSender:   ms,
+		unicastSender:     us,
+	}, nil
+}
+
+// multicastGroup returns group, or MulticastIP if it is empty
+func multicastGroup(group string) string {
+	if group == "" {
+		return MulticastIP
+	}
+	return group
+}
+
+// receiver is a receiver which can be waited for
+type receiver interface {
+	Wait() error
+}
+
+// waitReceivers waits for receivers to stop, and returns the first error which stopped one of them.
+// cancel is called on the error to stop the others.
+func waitReceivers(cancel context.CancelFunc, receivers ...receiver) error {
+	errs := make(chan error, len(receivers))
+	for _, r := range receivers {
+		r := r
+		go func() {
+			err := r.Wait()
+			if err != nil {
+				cancel()
+			}
+			errs <- err
+		}()
+	}
+	var first error
+	for range receivers {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
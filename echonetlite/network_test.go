@@ -0,0 +1,37 @@
+package echonetlite
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNetworkConfig_MulticastGroup(t *testing.T) {
+	testcases := []struct {
+		name string
+		cfg  NetworkConfig
+		want string
+	}{
+		{name: "IPv4", cfg: NetworkConfig{Interface: "eth0"}, want: "224.0.23.0"},
+		{name: "IPv6", cfg: NetworkConfig{Interface: "192.168.1.0/24", IPv6: true}, want: "ff02::1"},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.cfg.MulticastGroup(); got != tc.want {
+				t.Errorf("Diffrent result: want:%v, got:%v", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_openUDPTransports_IPv6WithoutInterface(t *testing.T) {
+	t.Parallel()
+
+	// ff02::1 can't be sent without the zone of the link
+	_, err := openUDPTransports(NetworkConfig{IPv6: true})
+	checkError(t, fmt.Errorf("interface is required for IPv6, on whose link ff02::1 is sent"), err)
+}
@@ -0,0 +1,189 @@
+package echonetlite
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// NodeList is list of node profile objects keyed by address
+type NodeList map[string]Node
+
+// Node represents a node profile object
+type Node struct {
+	Address  string
+	Profile  Device // node profile object
+	Devices  []Device
+	LastSeen time.Time
+}
+
+// Device represents a device object in a node
+type Device struct {
+	Object           Object
+	ManufacturerCode Data        // 0x8A
+	AnnoPropertyMap  PropertyMap // 0x9D
+	SetPropertyMap   PropertyMap // 0x9E
+	GetPropertyMap   PropertyMap // 0x9F
+}
+
+// Update updates information of the device with props
+func (d *Device) Update(props []Property) error {
+	for _, p := range props {
+		if p.Len == 0 {
+			continue
+		}
+		var err error
+		switch PropertyCode(p.Code) {
+		case ManufacturerCode:
+			d.ManufacturerCode = p.Data
+		case StageChangeAnnouncePropertyMap:
+			d.AnnoPropertyMap, err = ParsePropertyMap(p.Data)
+		case SetPropertyMap:
+			d.SetPropertyMap, err = ParsePropertyMap(p.Data)
+		case GetPropertyMap:
+			d.GetPropertyMap, err = ParsePropertyMap(p.Data)
+		}
+		if err != nil {
+			return fmt.Errorf("EPC[%02x]: %w", p.Code, err)
+		}
+	}
+	return nil
+}
+
+// Supported returns codes which can be got from the device
+// If property map of the device is not known yet, it returns codes as they are.
+func (d Device) Supported(codes []PropertyCode) []PropertyCode {
+	if d.GetPropertyMap.Len() == 0 {
+		return codes
+	}
+	return d.GetPropertyMap.Filter(codes)
+}
+
+// Add adds Node at addr if not exists, and updates time it was seen last
+func (nlist NodeList) Add(addr string, seen time.Time) {
+	n := nlist[addr]
+	n.Address = addr
+	n.LastSeen = seen
+	nlist[addr] = n
+}
+
+// AddDevices adds objs to devices of Node at addr, and returns objects newly added
+func (nlist NodeList) AddDevices(addr string, objs []Object) []Object {
+	n, ok := nlist[addr]
+	if !ok {
+		return nil
+	}
+	added := []Object{}
+	for _, obj := range objs {
+		if n.hasDevice(obj) {
+			continue
+		}
+		n.Devices = append(n.Devices, Device{Object: obj})
+		added = append(added, obj)
+	}
+	nlist[addr] = n
+	return added
+}
+
+// SetDevices replaces devices of Node at addr with objs, and returns objects newly added
+func (nlist NodeList) SetDevices(addr string, objs []Object) []Object {
+	n, ok := nlist[addr]
+	if !ok {
+		return nil
+	}
+	devices := make([]Device, 0, len(objs))
+	added := []Object{}
+	for _, obj := range objs {
+		if d, ok := n.device(obj); ok {
+			devices = append(devices, d)
+			continue
+		}
+		devices = append(devices, Device{Object: obj})
+		added = append(added, obj)
+	}
+	n.Devices = devices
+	nlist[addr] = n
+	return added
+}
+
+// UpdateDevice replaces Device which has the same object in Node at addr with d
+func (nlist NodeList) UpdateDevice(addr string, d Device) {
+	n, ok := nlist[addr]
+	if !ok {
+		return
+	}
+	devices := make([]Device, 0, len(n.Devices))
+	for _, old := range n.Devices {
+		if old.Object == d.Object {
+			old = d
+		}
+		devices = append(devices, old)
+	}
+	n.Devices = devices
+	nlist[addr] = n
+}
+
+// UpdateProfile updates node profile object of Node at addr with props
+func (nlist NodeList) UpdateProfile(addr string, obj Object, props []Property) error {
+	n, ok := nlist[addr]
+	if !ok {
+		return nil
+	}
+	n.Profile.Object = obj
+	err := n.Profile.Update(props)
+	nlist[addr] = n
+	return err
+}
+
+// Evict removes nodes which have not been seen since before, and returns their addresses
+func (nlist NodeList) Evict(before time.Time) []string {
+	evicted := []string{}
+	for addr, n := range nlist {
+		if n.LastSeen.Before(before) {
+			delete(nlist, addr)
+			evicted = append(evicted, addr)
+		}
+	}
+	sort.Strings(evicted)
+	return evicted
+}
+
+// Addresses returns sorted addresses of nodes
+func (nlist NodeList) Addresses() []string {
+	addrs := make([]string, 0, len(nlist))
+	for addr := range nlist {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+func (n Node) device(obj Object) (Device, bool) {
+	for _, d := range n.Devices {
+		if d.Object == obj {
+			return d, true
+		}
+	}
+	return Device{}, false
+}
+
+func (n Node) hasDevice(obj Object) bool {
+	_, ok := n.device(obj)
+	return ok
+}
+
+// ParseInstanceList returns objects in EDT of instance list notification (0xD5) or self-node instance list S (0xD6)
+func ParseInstanceList(edt Data) ([]Object, error) {
+	if len(edt) < 1 {
+		return nil, fmt.Errorf("num of instances missing: %w", ErrTruncated)
+	}
+	num := int(edt[0])
+	if len(edt) < 1+num*3 {
+		return nil, fmt.Errorf("%d instances in %d bytes: %w", num, len(edt)-1, ErrTruncated)
+	}
+	objs := make([]Object, 0, num)
+	for i := 0; i < num; i++ {
+		objs = append(objs, NewObjectFromData(edt[1+i*3:4+i*3]))
+	}
+	return objs, nil
+}
@@ -0,0 +1,122 @@
+package echonetlite
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseInstanceList(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name  string
+		input Data
+		want  []Object
+		err   error
+	}{
+		{
+			name:  "one",
+			input: Data{0x01, 0x01, 0x30, 0x01},
+			want:  []Object{NewObject(AirConditionerGroup, HomeAirConditioner, 0x01)},
+		},
+		{
+			name:  "two",
+			input: Data{0x02, 0x01, 0x30, 0x01, 0x02, 0x88, 0x01},
+			want: []Object{
+				NewObject(AirConditionerGroup, HomeAirConditioner, 0x01),
+				NewObject(HomeEquipmentGroup, LowVoltageSmartMeter, 0x01),
+			},
+		},
+		{
+			name:  "empty",
+			input: Data{},
+			err:   fmt.Errorf("num of instances missing: frame truncated"),
+		},
+		{
+			name:  "truncated",
+			input: Data{0x02, 0x01, 0x30, 0x01, 0x02},
+			err:   fmt.Errorf("2 instances in 4 bytes: frame truncated"),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseInstanceList(tc.input)
+			checkError(t, tc.err, err)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("objects differ: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNodeList_Devices(t *testing.T) {
+	t.Parallel()
+
+	aircon1 := NewObject(AirConditionerGroup, HomeAirConditioner, 0x01)
+	aircon2 := NewObject(AirConditionerGroup, HomeAirConditioner, 0x02)
+	meter := NewObject(HomeEquipmentGroup, LowVoltageSmartMeter, 0x01)
+	seen := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	nlist := NodeList{}
+	if added := nlist.AddDevices("192.168.1.10", []Object{aircon1}); added != nil {
+		t.Errorf("devices added to unknown node: %v", added)
+	}
+
+	nlist.Add("192.168.1.10", seen)
+	added := nlist.AddDevices("192.168.1.10", []Object{aircon1})
+	if diff := cmp.Diff([]Object{aircon1}, added); diff != "" {
+		t.Errorf("added objects differ: (-want +got)\n%s", diff)
+	}
+
+	nlist.UpdateDevice("192.168.1.10", Device{Object: aircon1, ManufacturerCode: Data{0x00, 0x00, 0x08}})
+
+	added = nlist.AddDevices("192.168.1.10", []Object{aircon1, aircon2})
+	if diff := cmp.Diff([]Object{aircon2}, added); diff != "" {
+		t.Errorf("added objects differ: (-want +got)\n%s", diff)
+	}
+
+	added = nlist.SetDevices("192.168.1.10", []Object{aircon1, meter})
+	if diff := cmp.Diff([]Object{meter}, added); diff != "" {
+		t.Errorf("added objects differ: (-want +got)\n%s", diff)
+	}
+
+	want := NodeList{
+		"192.168.1.10": Node{
+			Address: "192.168.1.10",
+			Devices: []Device{
+				{Object: aircon1, ManufacturerCode: Data{0x00, 0x00, 0x08}},
+				{Object: meter},
+			},
+			LastSeen: seen,
+		},
+	}
+	if diff := cmp.Diff(want, nlist); diff != "" {
+		t.Errorf("NodeList differs: (-want +got)\n%s", diff)
+	}
+}
+
+func TestNodeList_Evict(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	nlist := NodeList{}
+	nlist.Add("192.168.1.10", now.Add(-11*time.Minute))
+	nlist.Add("192.168.1.15", now.Add(-9*time.Minute))
+	nlist.Add("192.168.1.20", now.Add(-20*time.Minute))
+
+	got := nlist.Evict(now.Add(-10 * time.Minute))
+	if diff := cmp.Diff([]string{"192.168.1.10", "192.168.1.20"}, got); diff != "" {
+		t.Errorf("evicted addresses differ: (-want +got)\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"192.168.1.15"}, nlist.Addresses()); diff != "" {
+		t.Errorf("addresses differ: (-want +got)\n%s", diff)
+	}
+}
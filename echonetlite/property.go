@@ -1,7 +1,5 @@
 package echonetlite
 
-import "fmt"
-
 // PropertyCode represents property code
 type PropertyCode byte
 
@@ -43,13 +41,24 @@ const (
 	InstanceListS            PropertyCode = 0xD6 // 自ノードインスタンスリストS
 	ClassListS               PropertyCode = 0xD7 // 自ノードクラスリストS
 
+	// 温度センサクラス
+	// Class Group Code: 0x00, Class Code: 0x11
+	MeasuredTemperature PropertyCode = 0xE0 // 温度計測値
+
 	// 家庭用エアコンクラス
-	MeasuredRoomTemperature    PropertyCode = 0xBB
-	MeasuredOutdoorTemperature PropertyCode = 0xBE
+	// Class Group Code: 0x01, Class Code: 0x30
+	AirFlowRate                PropertyCode = 0xA0 // 風量設定
+	AutoAirFlowDirection       PropertyCode = 0xA1 // 風向自動設定
+	AirFlowDirectionVertical   PropertyCode = 0xA4 // 風向上下設定
+	OperationMode              PropertyCode = 0xB0 // 運転モード設定
+	SetTemperature             PropertyCode = 0xB3 // 温度設定値
+	MeasuredRoomHumidity       PropertyCode = 0xBA // 室内相対湿度計測値
+	MeasuredRoomTemperature    PropertyCode = 0xBB // 室内温度計測値
+	MeasuredOutdoorTemperature PropertyCode = 0xBE // 外気温度計測値
 
 	// 低圧スマート電力量メータクラス
 	// Class Group Code: 0x02, Class Code: 0x88
-	Coefficient                           PropertyCode = 0xD7 // 係数
+	Coefficient                           PropertyCode = 0xD3 // 係数
 	IntegralPowerConsumptionValidDigits   PropertyCode = 0xD7 // 積算電力量有効桁数
 	IntegralPowerConsumption              PropertyCode = 0xE0 // 積算電力量計測値(正方向計測値)
 	IntegralPowerConsumptionUnit          PropertyCode = 0xE1 // 積算電力量単位(正方向、逆方向計測値)
@@ -82,6 +91,14 @@ func (p Property) Serialize() Data {
 	return d
 }
 
+// String returns string with name and decoded value of the property if they are known without class.
+// Use Format to describe properties of the object.
 func (p Property) String() string {
-	return fmt.Sprintf("EPC[%x] PDC[%d] EDT[%s]", p.Code, p.Len, p.Data)
+	return p.Format(Object{})
+}
+
+// Format returns string with name and decoded value of the property of the object
+// e.g. EPC[bb](室内温度計測値) PDC[1] EDT[1b](27 ℃)
+func (p Property) Format(obj Object) string {
+	return codecs.Format(obj, p)
 }
@@ -0,0 +1,160 @@
+package echonetlite
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PropertyMap is set of property codes which is the value of property maps (0x9B-0x9F)
+// It is stored in the same layout as the bitmap format of EDT,
+// where bit j of byte i represents property code 0x80 + 0x10*j + i.
+type PropertyMap [16]byte
+
+const propertyMapListMax = 15 // max num of properties in list format
+
+// NewPropertyMap returns PropertyMap which has codes
+func NewPropertyMap(codes ...PropertyCode) PropertyMap {
+	m := PropertyMap{}
+	for _, code := range codes {
+		m.Add(code)
+	}
+	return m
+}
+
+// ParsePropertyMap parses EDT of property map
+// EDT is list of codes following num of them if num < 16, otherwise 16 bytes bitmap.
+func ParsePropertyMap(edt Data) (PropertyMap, error) {
+	if len(edt) < 1 {
+		return PropertyMap{}, fmt.Errorf("num of properties missing: %w", ErrTruncated)
+	}
+	num := int(edt[0])
+
+	m := PropertyMap{}
+	if num <= propertyMapListMax {
+		if len(edt) < 1+num {
+			return PropertyMap{}, fmt.Errorf("%d properties in %d bytes: %w", num, len(edt)-1, ErrTruncated)
+		}
+		for _, code := range edt[1 : 1+num] {
+			if code < 0x80 {
+				return PropertyMap{}, fmt.Errorf("invalid property code in property map: %02x", code)
+			}
+			m.Add(PropertyCode(code))
+		}
+		return m, nil
+	}
+
+	if len(edt) < 1+len(m) {
+		return PropertyMap{}, fmt.Errorf("bitmap in %d bytes: %w", len(edt)-1, ErrTruncated)
+	}
+	copy(m[:], edt[1:1+len(m)])
+	if m.Len() != num {
+		return PropertyMap{}, fmt.Errorf("%d properties in bitmap of %d", m.Len(), num)
+	}
+	return m, nil
+}
+
+// Encode returns EDT of the property map
+func (m PropertyMap) Encode() Data {
+	num := m.Len()
+	if num <= propertyMapListMax {
+		edt := Data{byte(num)}
+		for _, code := range m.Codes() {
+			edt = append(edt, byte(code))
+		}
+		return edt
+	}
+	edt := Data{byte(num)}
+	return append(edt, m[:]...)
+}
+
+func position(code PropertyCode) (int, byte, bool) {
+	if code < 0x80 {
+		return 0, 0, false
+	}
+	return int(code & 0x0F), 1 << ((code >> 4) - 8), true
+}
+
+// Add adds code to the property map. Codes less than 0x80 are ignored.
+func (m *PropertyMap) Add(code PropertyCode) {
+	if i, bit, ok := position(code); ok {
+		m[i] |= bit
+	}
+}
+
+// Remove removes code from the property map
+func (m *PropertyMap) Remove(code PropertyCode) {
+	if i, bit, ok := position(code); ok {
+		m[i] &^= bit
+	}
+}
+
+// Has returns true if the property map has code
+func (m PropertyMap) Has(code PropertyCode) bool {
+	i, bit, ok := position(code)
+	return ok && m[i]&bit != 0
+}
+
+// Len returns num of properties
+func (m PropertyMap) Len() int {
+	num := 0
+	for _, b := range m {
+		for ; b != 0; b &= b - 1 {
+			num++
+		}
+	}
+	return num
+}
+
+// Codes returns property codes in ascending order
+func (m PropertyMap) Codes() []PropertyCode {
+	codes := []PropertyCode{}
+	for code := 0x80; code <= 0xFF; code++ {
+		if m.Has(PropertyCode(code)) {
+			codes = append(codes, PropertyCode(code))
+		}
+	}
+	return codes
+}
+
+// Union returns property map which has codes in m or other
+func (m PropertyMap) Union(other PropertyMap) PropertyMap {
+	for i := range m {
+		m[i] |= other[i]
+	}
+	return m
+}
+
+// Intersect returns property map which has codes in both of m and other
+func (m PropertyMap) Intersect(other PropertyMap) PropertyMap {
+	for i := range m {
+		m[i] &= other[i]
+	}
+	return m
+}
+
+// Difference returns property map which has codes in m but not in other
+func (m PropertyMap) Difference(other PropertyMap) PropertyMap {
+	for i := range m {
+		m[i] &^= other[i]
+	}
+	return m
+}
+
+// Filter returns codes which the property map has, keeping the order
+func (m PropertyMap) Filter(codes []PropertyCode) []PropertyCode {
+	filtered := []PropertyCode{}
+	for _, code := range codes {
+		if m.Has(code) {
+			filtered = append(filtered, code)
+		}
+	}
+	return filtered
+}
+
+func (m PropertyMap) String() string {
+	codes := []string{}
+	for _, code := range m.Codes() {
+		codes = append(codes, fmt.Sprintf("%02x", byte(code)))
+	}
+	return "[" + strings.Join(codes, " ") + "]"
+}
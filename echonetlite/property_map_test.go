@@ -0,0 +1,120 @@
+package echonetlite
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParsePropertyMap(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name  string
+		input Data
+		want  []PropertyCode
+		err   error
+	}{
+		{
+			name:  "list",
+			input: toData(t, "0d808283898a9d9e9fbfd3d4d6d7"),
+			want:  []PropertyCode{0x80, 0x82, 0x83, 0x89, 0x8a, 0x9d, 0x9e, 0x9f, 0xbf, 0xd3, 0xd4, 0xd6, 0xd7},
+		},
+		{
+			name:  "empty list",
+			input: toData(t, "00"),
+			want:  []PropertyCode{},
+		},
+		{
+			name: "bitmap",
+			// 0x80-0x8F, 0x9D-0x9F, 0xB0, 0xB3, 0xBB, 0xBE
+			input: toData(t, "1709010109010101010101010901030b03"),
+			want: []PropertyCode{
+				0x80, 0x81, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89, 0x8a, 0x8b, 0x8c, 0x8d, 0x8e, 0x8f,
+				0x9d, 0x9e, 0x9f, 0xb0, 0xb3, 0xbb, 0xbe,
+			},
+		},
+		{
+			name:  "bitmap num mismatch",
+			input: toData(t, "1609010109010101010101010901030b03"),
+			err:   fmt.Errorf("23 properties in bitmap of 22"),
+		},
+		{
+			name:  "missing num",
+			input: Data{},
+			err:   fmt.Errorf("num of properties missing: frame truncated"),
+		},
+		{
+			name:  "list truncated",
+			input: toData(t, "038082"),
+			err:   fmt.Errorf("3 properties in 2 bytes: frame truncated"),
+		},
+		{
+			name:  "bitmap truncated",
+			input: toData(t, "100101"),
+			err:   fmt.Errorf("bitmap in 2 bytes: frame truncated"),
+		},
+		{
+			name:  "invalid code",
+			input: toData(t, "027f80"),
+			err:   fmt.Errorf("invalid property code in property map: 7f"),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParsePropertyMap(tc.input)
+			checkError(t, tc.err, err)
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want, got.Codes()); diff != "" {
+				t.Errorf("codes differ: (-want +got)\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.input, got.Encode()); diff != "" {
+				t.Errorf("Encode differs: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPropertyMap_Operations(t *testing.T) {
+	t.Parallel()
+
+	a := NewPropertyMap(0x80, 0x81, 0x9f, 0xff)
+	b := NewPropertyMap(0x81, 0xbb, 0xff)
+
+	if !a.Has(0xff) || a.Has(0xbb) || a.Has(0x00) {
+		t.Errorf("Diffrent result of Has: %s", a)
+	}
+
+	testcases := []struct {
+		name string
+		got  PropertyMap
+		want []PropertyCode
+	}{
+		{name: "Union", got: a.Union(b), want: []PropertyCode{0x80, 0x81, 0x9f, 0xbb, 0xff}},
+		{name: "Intersect", got: a.Intersect(b), want: []PropertyCode{0x81, 0xff}},
+		{name: "Difference", got: a.Difference(b), want: []PropertyCode{0x80, 0x9f}},
+	}
+	for _, tc := range testcases {
+		if diff := cmp.Diff(tc.want, tc.got.Codes()); diff != "" {
+			t.Errorf("%s differs: (-want +got)\n%s", tc.name, diff)
+		}
+	}
+
+	a.Remove(0x9f)
+	if got, want := a.String(), "[80 81 ff]"; got != want {
+		t.Errorf("Diffrent result: want:%s, got:%s", want, got)
+	}
+
+	got := b.Filter([]PropertyCode{0xff, 0x80, 0xbb})
+	if diff := cmp.Diff([]PropertyCode{0xff, 0xbb}, got); diff != "" {
+		t.Errorf("Filter differs: (-want +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,50 @@
+package echonetlite
+
+import (
+	"fmt"
+	"sync"
+)
+
+// PropertyStore stores values (EDT) of properties of a device object
+// Implement it to serve values read from sensors on demand.
+type PropertyStore interface {
+	Get(code PropertyCode) (Data, bool)
+	Set(code PropertyCode, edt Data) error // returns error to reject the value
+}
+
+// MemoryPropertyStore is PropertyStore which keeps values in memory
+type MemoryPropertyStore struct {
+	mu     sync.Mutex
+	values map[PropertyCode]Data
+}
+
+// NewMemoryPropertyStore returns MemoryPropertyStore which has values initially
+func NewMemoryPropertyStore(values map[PropertyCode]Data) *MemoryPropertyStore {
+	s := &MemoryPropertyStore{values: map[PropertyCode]Data{}}
+	for code, edt := range values {
+		s.values[code] = append(Data{}, edt...)
+	}
+	return s
+}
+
+// Get returns value of code
+func (s *MemoryPropertyStore) Get(code PropertyCode) (Data, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	edt, ok := s.values[code]
+	if !ok {
+		return nil, false
+	}
+	return append(Data{}, edt...), true
+}
+
+// Set sets value of code. Empty value is rejected.
+func (s *MemoryPropertyStore) Set(code PropertyCode, edt Data) error {
+	if len(edt) == 0 {
+		return fmt.Errorf("empty EDT for EPC[%02x]", byte(code))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[code] = append(Data{}, edt...)
+	return nil
+}
@@ -0,0 +1,129 @@
+package echonetlite
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/u-one/go-el-controller/transport"
+)
+
+// findDevices waits for the controller to find devices of the node at addr with their property maps
+func findDevices(ctx context.Context, t *testing.T, c *ControllerNode, addr string) []Device {
+	t.Helper()
+	for {
+		for _, node := range c.Nodes() {
+			if node.Address == addr && len(node.Devices) > 0 && node.Devices[0].GetPropertyMap.Len() > 0 {
+				return node.Devices
+			}
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("device not found: %#v", c.Nodes())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	testcases := []struct {
+		name      string
+		newWriter func(buf *bytes.Buffer) (transport.CaptureWriter, error)
+	}{
+		{
+			name: "JSONL",
+			newWriter: func(buf *bytes.Buffer) (transport.CaptureWriter, error) {
+				return transport.NewJSONLWriter(buf), nil
+			},
+		},
+		{
+			name: "pcapng",
+			newWriter: func(buf *bytes.Buffer) (transport.CaptureWriter, error) {
+				return transport.NewPcapngWriter(buf)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+			defer cancel()
+
+			// capture a controller finding a device
+			lan := transport.NewVirtualLAN(1)
+			lan.Latency = 5 * time.Millisecond
+
+			dh := lan.Host("192.168.1.20")
+			n := &DeviceNode{
+				MulticastReceiver: dh.MulticastReceiver(),
+				UnicastReceiver:   dh.UnicastReceiver(),
+				MulticastSender:   dh.MulticastSender(MulticastIP, Port),
+				UnicastSender:     dh.UnicastSender(Port),
+			}
+			n.AddDevice(newTestSensor(1))
+			n.Start(ctx)
+			defer n.Close()
+
+			buf := &bytes.Buffer{}
+			w, err := tc.newWriter(buf)
+			if err != nil {
+				t.Fatalf("failed to create writer: %s", err)
+			}
+			capture := transport.NewCapture(w)
+			ch := lan.Host("192.168.1.10")
+			c := &ControllerNode{
+				MulticastReceiver: capture.MulticastReceiver(ch.MulticastReceiver()),
+				UnicastReceiver:   capture.UnicastReceiver(ch.UnicastReceiver()),
+				MulticastSender:   capture.MulticastSender(ch.MulticastSender(MulticastIP, Port), MulticastIP),
+				UnicastSender:     capture.UnicastSender(ch.UnicastSender(Port)),
+			}
+			cctx, ccancel := context.WithCancel(ctx)
+			done := make(chan error)
+			go func() {
+				done <- c.Run(cctx)
+			}()
+			want := findDevices(ctx, t, c, "192.168.1.20")
+			ccancel()
+			if err := <-done; err != nil {
+				t.Fatalf("Run failed: %s", err)
+			}
+			c.Close()
+			if err := capture.Close(); err != nil {
+				t.Fatalf("Close failed: %s", err)
+			}
+
+			packets, err := transport.ReadCapture(buf)
+			if err != nil {
+				t.Fatalf("ReadCapture failed: %s", err)
+			}
+
+			// replay it to another controller
+			replay := transport.NewReplay(packets)
+			rc := &ControllerNode{
+				MulticastReceiver: replay.MulticastReceiver(),
+				UnicastReceiver:   replay.UnicastReceiver(),
+				MulticastSender:   replay.MulticastSender(MulticastIP),
+				UnicastSender:     replay.UnicastSender(),
+			}
+			rc.Start(ctx)
+			defer rc.Close()
+
+			// done only after the controller has sent the requests which were responded in the capture
+			select {
+			case <-replay.Done():
+			case <-ctx.Done():
+				t.Fatalf("replay not done: sent %d packets", len(replay.Sent()))
+			}
+			got := findDevices(ctx, t, rc, "192.168.1.20")
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
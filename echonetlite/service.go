@@ -98,3 +98,31 @@ func (t ESVType) isResponseOrNotification() bool {
 	}
 	return false
 }
+
+// isResponseTo returns true if t is a response to request req, whether it is accepted or not
+func (t ESVType) isResponseTo(req ESVType) bool {
+	switch req {
+	case SetI:
+		return t == SetISNA
+	case SetC:
+		return t == SetRes || t == SetCSNA
+	case Get:
+		return t == GetRes || t == GetSNA
+	case InfReq:
+		return t == Inf || t == InfSNA
+	case SetGet:
+		return t == SetGetRes || t == SetGetSNA
+	}
+	return false
+}
+
+// isSetGet returns true if frames of the type have both of OPCSet and OPCGet blocks
+func (t ESVType) isSetGet() bool {
+	switch t {
+	case SetGet,
+		SetGetRes,
+		SetGetSNA:
+		return true
+	}
+	return false
+}
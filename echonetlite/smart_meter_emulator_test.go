@@ -0,0 +1,81 @@
+package echonetlite
+
+import (
+	"context"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/u-one/go-el-controller/transport"
+	"github.com/u-one/go-el-controller/wisun"
+)
+
+// smartMeterScenario is a smart-meter without reverse direction, whose instant power and energy increase
+const smartMeterScenario = `{
+  "name": "smart meter",
+  "rules": [
+    {"command": "SKSETPWD", "responses": [[{"ok": true}]]},
+    {"command": "SKSETRBID", "responses": [[{"ok": true}]]},
+    {"command": "SKSCAN", "responses": [[
+      {"ok": true},
+      {"line": "EVENT 20 FE80:0000:0000:0000:021D:1290:1234:5678 0"},
+      {"line": "EPANDESC"},
+      {"line": " Channel:21"},
+      {"line": " Pan ID:8888"},
+      {"line": " Addr:12345678ABCDEF01"},
+      {"line": " PairID:AABBCCDD"}
+    ]]},
+    {"command": "SKLL64", "responses": [[{"line": "FE80:0000:0000:0000:021D:1290:1234:ABCD"}]]},
+    {"command": "SKSREG", "responses": [[{"ok": true}]]},
+    {"command": "SKJOIN", "responses": [[{"ok": true}, {"pana": true}]]},
+    {"command": "SKSENDTO", "responses": [[{"line": "EVENT 21 FE80:0000:0000:0000:021D:1290:1234:5678 0 00"}, {"ok": true}, {"reply": true}]]},
+    {"command": "SKTERM", "responses": [[{"ok": true}]]}
+  ],
+  "properties": {
+    "D7": {"data": "06"},
+    "E1": {"data": "01"},
+    "E7": {"values": [504, 1000]},
+    "E8": {"data": "001E7FFE"},
+    "E0": {"start": 12345, "step": 5},
+    "EA": {"data": "07EA0A110C0000000030B9"}
+  }
+}`
+
+func TestGetReadings_Emulator(t *testing.T) {
+	s, err := wisun.ParseScenario([]byte(smartMeterScenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPort, emulatorPort := net.Pipe()
+	e := wisun.NewBP35C2EmulatorWithPort(emulatorPort)
+	e.SetScenario(s)
+	go e.Start()
+	defer e.Close()
+
+	node := NewElectricityControllerNode(wisun.NewBP35C2ClientWithSerial(transport.NewSerialWithPort(clientPort)))
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err = node.Start(ctx, "00000000000000000000000000000000", "PASSWORD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	fixedTime := FixedTimeEnergy{Time: time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local), Energy: 1247.3}
+	want := []SmartMeterReading{
+		{InstantPower: 504, InstantCurrentR: 3.0, InstantCurrentT: math.NaN(), CumulativeEnergy: 1234.5, FixedTimeEnergy: fixedTime},
+		{InstantPower: 1000, InstantCurrentR: 3.0, InstantCurrentT: math.NaN(), CumulativeEnergy: 1235.0, FixedTimeEnergy: fixedTime},
+	}
+	for _, w := range want {
+		got, err := node.GetReadings()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(w, got, cmpopts.EquateApprox(0, 1e-9), cmpopts.EquateNaNs()); diff != "" {
+			t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+		}
+	}
+}
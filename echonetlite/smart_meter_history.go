@@ -0,0 +1,180 @@
+package echonetlite
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+const (
+	// maxHistoryDay is the oldest day which can be specified as collection day of historical data 1 (0xE5)
+	maxHistoryDay = 99
+	// historySlots is number of values in historical data 1 (0xE2, 0xE4)
+	historySlots = 48
+	// maxHistory2Count is max number of collection segments of historical data 2 (0xED)
+	maxHistory2Count = 12
+	// historyInterval is interval between values in historical data
+	historyInterval = 30 * time.Minute
+	// noHistoryData is value of historical data which was not measured
+	noHistoryData = 0xFFFFFFFE
+)
+
+// Reading is cumulative amounts of electric energy read from historical data
+type Reading struct {
+	Time time.Time `json:"time"`
+	KWh  float64   `json:"kwh"`
+}
+
+// set writes properties of the smart-meter with SetC
+func (n *ElectricityControllerNode) set(props ...Property) error {
+	dest := NewObject(HomeEquipmentGroup, LowVoltageSmartMeter, 0x01)
+	f := CreateSetCFrame(n.nextTID(), dest, props)
+
+	rdata, err := n.client.Send(f.Serialize())
+	if err != nil {
+		return err
+	}
+	rf, err := ParseFrame(rdata)
+	if err != nil {
+		return fmt.Errorf("invalid frame: %w", err)
+	}
+	rf.Print()
+
+	switch rf.ESV {
+	case SetRes:
+		return nil
+	case SetCSNA:
+		return &SNAError{Addr: smartMeterAddr, Frame: rf}
+	default:
+		return fmt.Errorf("unexpected response to SetC: %s", rf.ESV)
+	}
+}
+
+// History reads historical data 1 of cumulative amounts of electric energy in normal direction (0xE2).
+// day is number of days before today (0-99). Readings the smart-meter doesn't have are skipped.
+func (n *ElectricityControllerNode) History(ctx context.Context, day int) ([]Reading, error) {
+	return n.history(ctx, day, IntegralPowerConsumptionHist1)
+}
+
+// HistoryReverse reads historical data 1 of cumulative amounts of electric energy in reverse direction (0xE4).
+func (n *ElectricityControllerNode) HistoryReverse(ctx context.Context, day int) ([]Reading, error) {
+	return n.history(ctx, day, IntegralPowerConsumptionRevHist1)
+}
+
+func (n *ElectricityControllerNode) history(ctx context.Context, day int, epc PropertyCode) ([]Reading, error) {
+	if day < 0 || day > maxHistoryDay {
+		return nil, fmt.Errorf("collection day out of range: %d", day)
+	}
+	scale, err := n.energyScale()
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	err = n.set(Property{Code: byte(IntegralPowerConsumptionHistCollDate1), Len: 1, Data: Data{byte(day)}})
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p, err := n.getOne(epc)
+	if err != nil {
+		return nil, err
+	}
+
+	y, m, d := n.now().Date()
+	return decodeHistory(p, scale, day, time.Date(y, m, d-day, 0, 0, 0, 0, time.Local))
+}
+
+// History2 reads historical data 2 of cumulative amounts of electric energy (0xEC) in normal and reverse direction.
+// It returns count (1-12) readings every 30 minutes going back from t, which must be on the hour or half past.
+func (n *ElectricityControllerNode) History2(ctx context.Context, t time.Time, count int) ([]Reading, []Reading, error) {
+	if count < 1 || count > maxHistory2Count {
+		return nil, nil, fmt.Errorf("number of collection segments out of range: %d", count)
+	}
+	t = t.In(time.Local)
+	if t.Minute()%30 != 0 || t.Second() != 0 || t.Nanosecond() != 0 {
+		return nil, nil, fmt.Errorf("collection time must be on the hour or half past: %s", t.Format("2006-01-02 15:04:05"))
+	}
+	scale, err := n.energyScale()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	err = n.set(Property{Code: byte(IntegralPowerConsumptionHistCollDate2), Len: 7, Data: encodeHistory2Header(t, count)})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	p, err := n.getOne(IntegralPowerConsumptionHist2)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeHistory2(p, scale)
+}
+
+func decodeHistory(p Property, scale EnergyScale, day int, date time.Time) ([]Reading, error) {
+	// collection day (2) and 48 cumulative amounts (4) from 00:00 every 30 minutes
+	if len(p.Data) != 2+historySlots*4 {
+		return nil, fmt.Errorf("invalid History length: %d", len(p.Data))
+	}
+	if d := int(binary.BigEndian.Uint16(p.Data[0:2])); d != day {
+		return nil, fmt.Errorf("unexpected collection day: %d, expected %d", d, day)
+	}
+
+	readings := []Reading{}
+	for i := 0; i < historySlots; i++ {
+		v := binary.BigEndian.Uint32(p.Data[2+i*4:])
+		if v == noHistoryData {
+			continue
+		}
+		t := time.Date(date.Year(), date.Month(), date.Day(), 0, i*30, 0, 0, time.Local)
+		readings = append(readings, Reading{Time: t, KWh: scale.KWh(v)})
+	}
+	return readings, nil
+}
+
+// encodeHistory2Header encodes YYYY(2) MM DD hh mm and number of collection segments
+func encodeHistory2Header(t time.Time, count int) Data {
+	d := make(Data, 7)
+	binary.BigEndian.PutUint16(d[0:2], uint16(t.Year()))
+	d[2] = byte(t.Month())
+	d[3] = byte(t.Day())
+	d[4] = byte(t.Hour())
+	d[5] = byte(t.Minute())
+	d[6] = byte(count)
+	return d
+}
+
+func decodeHistory2(p Property, scale EnergyScale) ([]Reading, []Reading, error) {
+	// YYYY(2) MM DD hh mm, number of collection segments and
+	// cumulative amounts in normal (4) and reverse (4) direction going back every 30 minutes
+	if len(p.Data) < 7 {
+		return nil, nil, fmt.Errorf("invalid History2 length: %d", len(p.Data))
+	}
+	d := p.Data
+	count := int(d[6])
+	if len(d) != 7+count*8 {
+		return nil, nil, fmt.Errorf("invalid History2 length: %d for %d segments", len(d), count)
+	}
+	t := time.Date(int(binary.BigEndian.Uint16(d[0:2])), time.Month(d[2]), int(d[3]), int(d[4]), int(d[5]), 0, 0, time.Local)
+
+	normal, reverse := []Reading{}, []Reading{}
+	for i := 0; i < count; i++ {
+		ti := t.Add(-historyInterval * time.Duration(i))
+		if v := binary.BigEndian.Uint32(d[7+i*8:]); v != noHistoryData {
+			normal = append(normal, Reading{Time: ti, KWh: scale.KWh(v)})
+		}
+		if v := binary.BigEndian.Uint32(d[11+i*8:]); v != noHistoryData {
+			reverse = append(reverse, Reading{Time: ti, KWh: scale.KWh(v)})
+		}
+	}
+	return normal, reverse, nil
+}
@@ -0,0 +1,155 @@
+package echonetlite
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/u-one/go-el-controller/wisun"
+)
+
+// smartMeterFrame returns frame of tid from smart-meter with a property
+func smartMeterFrame(tid uint16, esv ESVType, epc PropertyCode, edt []byte) []byte {
+	f := NewFrame(tid, NewObject(HomeEquipmentGroup, LowVoltageSmartMeter, 0x01), NewObject(ControllerGroup, Controller, 0x01),
+		esv, []Property{{Code: byte(epc), Len: len(edt), Data: edt}})
+	return f.Serialize()
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	// day 1, 00:00 is 100.0kWh, 00:30 is missing and 01:00 is 100.5kWh. The rest are missing.
+	edt := make([]byte, 2+48*4)
+	binary.BigEndian.PutUint16(edt[0:2], 1)
+	for i := 0; i < 48; i++ {
+		binary.BigEndian.PutUint32(edt[2+i*4:], noHistoryData)
+	}
+	binary.BigEndian.PutUint32(edt[2:], 1000)
+	binary.BigEndian.PutUint32(edt[10:], 1005)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock := wisun.NewMockClient(ctrl)
+	gomock.InOrder(
+		mock.EXPECT().Send(getEnergyScaleReq).Return(getEnergyScaleRes, nil),
+		mock.EXPECT().
+			Send([]byte("\x10\x81\x00\x02\x05\xff\x01\x02\x88\x01\x61\x01\xe5\x01\x01")).
+			Return([]byte("\x10\x81\x00\x02\x02\x88\x01\x05\xff\x01\x71\x01\xe5\x00"), nil),
+		mock.EXPECT().
+			Send([]byte("\x10\x81\x00\x03\x05\xff\x01\x02\x88\x01\x62\x01\xe2\x00")).
+			Return(smartMeterFrame(3, GetRes, IntegralPowerConsumptionHist1, edt), nil),
+		mock.EXPECT().
+			Send([]byte("\x10\x81\x00\x04\x05\xff\x01\x02\x88\x01\x61\x01\xe5\x01\x01")).
+			Return([]byte("\x10\x81\x00\x04\x02\x88\x01\x05\xff\x01\x51\x01\xe5\x01\x01"), nil),
+	)
+
+	node := NewElectricityControllerNode(mock)
+	node.now = func() time.Time { return time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local) }
+	ctx := context.Background()
+
+	got, err := node.History(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Reading{
+		{Time: time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local), KWh: 100.0},
+		{Time: time.Date(2026, 10, 16, 1, 0, 0, 0, time.Local), KWh: 100.5},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("History differs: (-want +got)\n%s", diff)
+	}
+
+	_, err = node.HistoryReverse(ctx, 1)
+	checkError(t, fmt.Errorf("SetC_SNA from smart-meter: EPC[e5] PDC[1] EDT[01]"), err)
+
+	_, err = node.History(ctx, 100)
+	checkError(t, fmt.Errorf("collection day out of range: 100"), err)
+}
+
+func TestHistory2(t *testing.T) {
+	t.Parallel()
+
+	// 2026-10-17 12:00 and 2 segments
+	header := []byte{0x07, 0xea, 0x0a, 0x11, 0x0c, 0x00, 0x02}
+	edt := append(append([]byte{}, header...),
+		0x00, 0x00, 0x03, 0xe8, 0xff, 0xff, 0xff, 0xfe, // 12:00
+		0x00, 0x00, 0x03, 0xe3, 0x00, 0x00, 0x00, 0x0a, // 11:30
+	)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mock := wisun.NewMockClient(ctrl)
+	gomock.InOrder(
+		mock.EXPECT().Send(getEnergyScaleReq).Return(getEnergyScaleRes, nil),
+		mock.EXPECT().
+			Send(append([]byte("\x10\x81\x00\x02\x05\xff\x01\x02\x88\x01\x61\x01\xed\x07"), header...)).
+			Return([]byte("\x10\x81\x00\x02\x02\x88\x01\x05\xff\x01\x71\x01\xed\x00"), nil),
+		mock.EXPECT().
+			Send([]byte("\x10\x81\x00\x03\x05\xff\x01\x02\x88\x01\x62\x01\xec\x00")).
+			Return(smartMeterFrame(3, GetRes, IntegralPowerConsumptionHist2, edt), nil),
+	)
+
+	node := NewElectricityControllerNode(mock)
+	ctx := context.Background()
+
+	normal, reverse, err := node.History2(ctx, time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantNormal := []Reading{
+		{Time: time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local), KWh: 100.0},
+		{Time: time.Date(2026, 10, 17, 11, 30, 0, 0, time.Local), KWh: 99.5},
+	}
+	wantReverse := []Reading{
+		{Time: time.Date(2026, 10, 17, 11, 30, 0, 0, time.Local), KWh: 1.0},
+	}
+	if diff := cmp.Diff(wantNormal, normal, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("normal direction differs: (-want +got)\n%s", diff)
+	}
+	if diff := cmp.Diff(wantReverse, reverse, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("reverse direction differs: (-want +got)\n%s", diff)
+	}
+
+	_, _, err = node.History2(ctx, time.Date(2026, 10, 17, 12, 15, 0, 0, time.Local), 2)
+	checkError(t, fmt.Errorf("collection time must be on the hour or half past: 2026-10-17 12:15:00"), err)
+
+	_, _, err = node.History2(ctx, time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local), 13)
+	checkError(t, fmt.Errorf("number of collection segments out of range: 13"), err)
+}
+
+func Test_decodeHistory2(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name string
+		edt  []byte
+		err  error
+	}{
+		{
+			name: "too short",
+			edt:  []byte{0x07, 0xea, 0x0a, 0x11, 0x0c, 0x00},
+			err:  fmt.Errorf("invalid History2 length: 6"),
+		},
+		{
+			name: "segments mismatch",
+			edt:  []byte{0x07, 0xea, 0x0a, 0x11, 0x0c, 0x00, 0x02, 0x00, 0x00, 0x03, 0xe8, 0x00, 0x00, 0x00, 0x00},
+			err:  fmt.Errorf("invalid History2 length: 15 for 2 segments"),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			p := Property{Code: byte(IntegralPowerConsumptionHist2), Len: len(tc.edt), Data: tc.edt}
+			_, _, err := decodeHistory2(p, EnergyScale{Coefficient: 1, Unit: 0.1})
+			checkError(t, tc.err, err)
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package echonetlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// DefaultRequestTimeout is default timeout for each attempt of a request
+	DefaultRequestTimeout = 3 * time.Second
+	// DefaultRequestRetries is default num of retries of a request
+	DefaultRequestRetries = 2
+	// NoRequestRetries is set to ControllerNode.RequestRetries to send each request only once
+	NoRequestRetries = -1
+)
+
+// ErrRequestTimeout is returned when no response is received for any attempt of a request
+var ErrRequestTimeout = errors.New("request timeout")
+
+// SNAError is returned when a request is not accepted by the destination object
+// Frame is the *_SNA response which has properties that could not be processed.
+type SNAError struct {
+	Addr  string
+	Frame Frame
+}
+
+func (e *SNAError) Error() string {
+	str := fmt.Sprintf("%s from %s:", e.Frame.ESV, e.Addr)
+	for _, p := range e.Frame.Properties {
+		str = str + fmt.Sprintf(" %s", p)
+	}
+	for _, p := range e.Frame.GetProperties {
+		str = str + fmt.Sprintf(" %s", p)
+	}
+	return str
+}
+
+// transactionKey identifies a request by the peer it was sent to and its TID
+type transactionKey struct {
+	addr string
+	tid  uint16
+}
+
+// transaction is a request waiting for its response
+type transaction struct {
+	esv ESVType // of the request
+	ch  chan Frame
+}
+
+// hostOf returns host part of addr which may have port
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// await registers request esv sent to addr with tid, and returns channel which receives its response
+func (elc *ControllerNode) await(addr string, tid uint16, esv ESVType) (<-chan Frame, func()) {
+	key := transactionKey{addr: hostOf(addr), tid: tid}
+	ch := make(chan Frame, 1)
+
+	elc.mu.Lock()
+	if elc.pending == nil {
+		elc.pending = map[transactionKey]transaction{}
+	}
+	elc.pending[key] = transaction{esv: esv, ch: ch}
+	elc.mu.Unlock()
+
+	return ch, func() {
+		elc.mu.Lock()
+		delete(elc.pending, key)
+		elc.mu.Unlock()
+	}
+}
+
+// resolve passes response frame to the request waiting for it.
+// Frames which are not responses to the request, e.g. INF with the same TID, are ignored.
+func (elc *ControllerNode) resolve(addr string, f Frame) {
+	if len(f.TID) != tidLen {
+		return
+	}
+	key := transactionKey{addr: hostOf(addr), tid: uint16(f.TID[0])<<8 | uint16(f.TID[1])}
+
+	elc.mu.Lock()
+	t, ok := elc.pending[key]
+	ok = ok && f.ESV.isResponseTo(t.esv)
+	if ok {
+		delete(elc.pending, key)
+	}
+	elc.mu.Unlock()
+
+	if ok {
+		t.ch <- f
+	}
+}
+
+// request sends the frame created by create only to addr, and returns its response.
+// The frame is sent again with the same TID when no response is received within RequestTimeout.
+func (elc *ControllerNode) request(ctx context.Context, addr string, create func(tid uint16) *Frame) (Frame, error) {
+	timeout := elc.RequestTimeout
+	if timeout == 0 {
+		timeout = DefaultRequestTimeout
+	}
+	retries := elc.RequestRetries
+	switch {
+	case retries == 0:
+		retries = DefaultRequestRetries
+	case retries < 0:
+		retries = 0
+	}
+
+	tid := elc.nextTID()
+	f := create(tid)
+	res, cancel := elc.await(addr, tid, f.ESV)
+	defer cancel()
+
+	for attempt := 0; attempt <= retries; attempt++ {
+		elc.sendFrameTo(addr, f)
+
+		timer := time.NewTimer(timeout)
+		select {
+		case rf := <-res:
+			timer.Stop()
+			return rf, nil
+		case <-ctx.Done():
+			timer.Stop()
+			return Frame{}, fmt.Errorf("%s to %s: %w", f.ESV, addr, ctx.Err())
+		case <-timer.C:
+			clogger.Printf("[%v] no response for TID[%s] (attempt %d)\n", addr, f.TID, attempt+1)
+		}
+	}
+	return Frame{}, fmt.Errorf("%s to %s: %w", f.ESV, addr, ErrRequestTimeout)
+}
+
+// Get reads properties epcs of obj on the node at addr.
+// If some of them are not available, it returns properties in Get_SNA with SNAError.
+func (elc *ControllerNode) Get(ctx context.Context, addr string, obj Object, epcs ...PropertyCode) ([]Property, error) {
+	rf, err := elc.request(ctx, addr, func(tid uint16) *Frame {
+		return CreateGetPropertiesFrame(tid, obj, epcs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch rf.ESV {
+	case GetRes:
+		return rf.Properties, nil
+	case GetSNA:
+		return rf.Properties, &SNAError{Addr: addr, Frame: rf}
+	default:
+		return nil, fmt.Errorf("unexpected response to Get: %s", rf.ESV)
+	}
+}
+
+// SetC writes properties props of obj on the node at addr and waits for Set_Res.
+func (elc *ControllerNode) SetC(ctx context.Context, addr string, obj Object, props ...Property) error {
+	rf, err := elc.request(ctx, addr, func(tid uint16) *Frame {
+		return CreateSetCFrame(tid, obj, props)
+	})
+	if err != nil {
+		return err
+	}
+	switch rf.ESV {
+	case SetRes:
+		return nil
+	case SetCSNA:
+		return &SNAError{Addr: addr, Frame: rf}
+	default:
+		return fmt.Errorf("unexpected response to SetC: %s", rf.ESV)
+	}
+}
+
+// SetGet writes sets and reads gets of obj on the node at addr in one transaction.
+// It returns properties of OPCSet and OPCGet blocks in the response.
+func (elc *ControllerNode) SetGet(ctx context.Context, addr string, obj Object, sets []Property, gets []PropertyCode) ([]Property, []Property, error) {
+	rf, err := elc.request(ctx, addr, func(tid uint16) *Frame {
+		return CreateSetGetFrame(tid, obj, sets, gets)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	switch rf.ESV {
+	case SetGetRes:
+		return rf.Properties, rf.GetProperties, nil
+	case SetGetSNA:
+		return rf.Properties, rf.GetProperties, &SNAError{Addr: addr, Frame: rf}
+	default:
+		return nil, nil, fmt.Errorf("unexpected response to SetGet: %s", rf.ESV)
+	}
+}
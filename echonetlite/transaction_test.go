@@ -0,0 +1,285 @@
+package echonetlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/u-one/go-el-controller/transport"
+)
+
+// newTestController returns ControllerNode whose sender replies responses[i] to the i-th frame sent.
+// nil response means no reply.
+func newTestController(t *testing.T, ctx context.Context, ctrl *gomock.Controller, want []byte, responses [][]byte) *ControllerNode {
+	t.Helper()
+
+	s := transport.NewMockUnicastSender(ctrl)
+	uch := make(chan transport.ReceiveResult, len(responses))
+	c := &ControllerNode{UnicastSender: s, RequestTimeout: 100 * time.Millisecond}
+	go c.handleUnicastResult(ctx, uch)
+
+	sent := 0
+	s.EXPECT().Send("192.168.1.5", want).DoAndReturn(func(ip string, data []byte) error {
+		if sent < len(responses) && responses[sent] != nil {
+			uch <- transport.ReceiveResult{Data: responses[sent], Address: "192.168.1.5:3610"}
+		}
+		sent++
+		return nil
+	}).Times(len(responses))
+	return c
+}
+
+func checkError(t *testing.T, want, got error) {
+	t.Helper()
+
+	if want != nil && got != nil {
+		if want.Error() != got.Error() {
+			t.Errorf("Diffrent result: want:%#v, got:%#v", want, got)
+		}
+	} else if want != got {
+		t.Errorf("Diffrent result: want:%#v, got:%#v", want, got)
+	}
+}
+
+func TestController_Get(t *testing.T) {
+	request := []byte{0x10, 0x81, 0x00, 0x00, 0x05, 0xff, 0x01, 0x01, 0x30, 0x01, 0x62, 0x02, 0xbb, 0x00, 0xbe, 0x00}
+
+	testcases := []struct {
+		name      string
+		retries   int
+		responses [][]byte
+		want      []Property
+		err       error
+	}{
+		{
+			name: "Get_Res",
+			responses: [][]byte{
+				{0x10, 0x81, 0x00, 0x00, 0x01, 0x30, 0x01, 0x05, 0xff, 0x01, 0x72, 0x02, 0xbb, 0x01, 0x1b, 0xbe, 0x01, 0x16},
+			},
+			want: []Property{{Code: 0xbb, Len: 1, Data: Data{0x1b}}, {Code: 0xbe, Len: 1, Data: Data{0x16}}},
+		},
+		{
+			name: "Get_SNA",
+			responses: [][]byte{
+				{0x10, 0x81, 0x00, 0x00, 0x01, 0x30, 0x01, 0x05, 0xff, 0x01, 0x52, 0x02, 0xbb, 0x01, 0x1b, 0xbe, 0x00},
+			},
+			want: []Property{{Code: 0xbb, Len: 1, Data: Data{0x1b}}, {Code: 0xbe, Len: 0, Data: Data{}}},
+			err:  fmt.Errorf("Get_SNA from 192.168.1.5: EPC[bb] PDC[1] EDT[1b] EPC[be] PDC[0] EDT[]"),
+		},
+		{
+			name: "retry",
+			responses: [][]byte{
+				nil,
+				{0x10, 0x81, 0x00, 0x00, 0x01, 0x30, 0x01, 0x05, 0xff, 0x01, 0x72, 0x02, 0xbb, 0x01, 0x1b, 0xbe, 0x01, 0x16},
+			},
+			want: []Property{{Code: 0xbb, Len: 1, Data: Data{0x1b}}, {Code: 0xbe, Len: 1, Data: Data{0x16}}},
+		},
+		{
+			name: "timeout",
+			responses: [][]byte{
+				nil, nil, nil,
+			},
+			err: fmt.Errorf("Get to 192.168.1.5: request timeout"),
+		},
+		{
+			name:    "no retries",
+			retries: NoRequestRetries,
+			responses: [][]byte{
+				nil,
+			},
+			err: fmt.Errorf("Get to 192.168.1.5: request timeout"),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			c := newTestController(t, ctx, ctrl, request, tc.responses)
+			c.RequestRetries = tc.retries
+			got, err := c.Get(ctx, "192.168.1.5", NewObject(AirConditionerGroup, HomeAirConditioner, 0x01), MeasuredRoomTemperature, MeasuredOutdoorTemperature)
+
+			checkError(t, tc.err, err)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("properties differ: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestController_GetErrorType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	request := []byte{0x10, 0x81, 0x00, 0x00, 0x05, 0xff, 0x01, 0x01, 0x30, 0x01, 0x62, 0x01, 0xbb, 0x00}
+	response := []byte{0x10, 0x81, 0x00, 0x00, 0x01, 0x30, 0x01, 0x05, 0xff, 0x01, 0x52, 0x01, 0xbb, 0x00}
+	c := newTestController(t, ctx, ctrl, request, [][]byte{response})
+
+	_, err := c.Get(ctx, "192.168.1.5", NewObject(AirConditionerGroup, HomeAirConditioner, 0x01), MeasuredRoomTemperature)
+
+	var snaErr *SNAError
+	if !errors.As(err, &snaErr) {
+		t.Fatalf("SNAError expected: %v", err)
+	}
+	if snaErr.Frame.ESV != GetSNA {
+		t.Errorf("Diffrent ESV: want:%v, got:%v", GetSNA, snaErr.Frame.ESV)
+	}
+
+	c = newTestController(t, ctx, ctrl, request, [][]byte{nil, nil, nil})
+	_, err = c.Get(ctx, "192.168.1.5", NewObject(AirConditionerGroup, HomeAirConditioner, 0x01), MeasuredRoomTemperature)
+	if !errors.Is(err, ErrRequestTimeout) {
+		t.Errorf("ErrRequestTimeout expected: %v", err)
+	}
+}
+
+func TestController_SetC(t *testing.T) {
+	request := []byte{0x10, 0x81, 0x00, 0x00, 0x05, 0xff, 0x01, 0x01, 0x30, 0x01, 0x61, 0x01, 0x80, 0x01, 0x30}
+
+	testcases := []struct {
+		name     string
+		response []byte
+		err      error
+	}{
+		{
+			name:     "Set_Res",
+			response: []byte{0x10, 0x81, 0x00, 0x00, 0x01, 0x30, 0x01, 0x05, 0xff, 0x01, 0x71, 0x01, 0x80, 0x00},
+		},
+		{
+			name:     "SetC_SNA",
+			response: []byte{0x10, 0x81, 0x00, 0x00, 0x01, 0x30, 0x01, 0x05, 0xff, 0x01, 0x51, 0x01, 0x80, 0x01, 0x30},
+			err:      fmt.Errorf("SetC_SNA from 192.168.1.5: EPC[80] PDC[1] EDT[30]"),
+		},
+		{
+			name:     "not a response to SetC",
+			response: []byte{0x10, 0x81, 0x00, 0x00, 0x01, 0x30, 0x01, 0x05, 0xff, 0x01, 0x72, 0x01, 0x80, 0x01, 0x30},
+			err:      fmt.Errorf("SetC to 192.168.1.5: request timeout"),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			c := newTestController(t, ctx, ctrl, request, [][]byte{tc.response})
+			c.RequestRetries = NoRequestRetries
+			err := c.SetC(ctx, "192.168.1.5", NewObject(AirConditionerGroup, HomeAirConditioner, 0x01), Property{Code: 0x80, Len: 1, Data: Data{0x30}})
+
+			checkError(t, tc.err, err)
+		})
+	}
+}
+
+func TestController_SetGet(t *testing.T) {
+	request := []byte{0x10, 0x81, 0x00, 0x00, 0x05, 0xff, 0x01, 0x01, 0x30, 0x01, 0x6e, 0x01, 0xb3, 0x01, 0x19, 0x01, 0xb3, 0x00}
+
+	testcases := []struct {
+		name     string
+		response []byte
+		wantSets []Property
+		wantGets []Property
+		err      error
+	}{
+		{
+			name:     "SetGet_Res",
+			response: []byte{0x10, 0x81, 0x00, 0x00, 0x01, 0x30, 0x01, 0x05, 0xff, 0x01, 0x7e, 0x01, 0xb3, 0x00, 0x01, 0xb3, 0x01, 0x19},
+			wantSets: []Property{{Code: 0xb3, Len: 0, Data: Data{}}},
+			wantGets: []Property{{Code: 0xb3, Len: 1, Data: Data{0x19}}},
+		},
+		{
+			name:     "SetGet_SNA",
+			response: []byte{0x10, 0x81, 0x00, 0x00, 0x01, 0x30, 0x01, 0x05, 0xff, 0x01, 0x5e, 0x01, 0xb3, 0x01, 0x19, 0x01, 0xb3, 0x00},
+			wantSets: []Property{{Code: 0xb3, Len: 1, Data: Data{0x19}}},
+			wantGets: []Property{{Code: 0xb3, Len: 0, Data: Data{}}},
+			err:      fmt.Errorf("SetGet_SNA from 192.168.1.5: EPC[b3] PDC[1] EDT[19] EPC[b3] PDC[0] EDT[]"),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			c := newTestController(t, ctx, ctrl, request, [][]byte{tc.response})
+			sets := []Property{{Code: 0xb3, Len: 1, Data: Data{0x19}}}
+			gotSets, gotGets, err := c.SetGet(ctx, "192.168.1.5", NewObject(AirConditionerGroup, HomeAirConditioner, 0x01), sets, []PropertyCode{0xb3})
+
+			checkError(t, tc.err, err)
+			if diff := cmp.Diff(tc.wantSets, gotSets); diff != "" {
+				t.Errorf("set properties differ: (-want +got)\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantGets, gotGets); diff != "" {
+				t.Errorf("get properties differ: (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestController_resolve(t *testing.T) {
+	testcases := []struct {
+		name     string
+		request  ESVType
+		response ESVType
+		resolved bool
+	}{
+		{name: "Get_Res", request: Get, response: GetRes, resolved: true},
+		{name: "Get_SNA", request: Get, response: GetSNA, resolved: true},
+		{name: "INF", request: Get, response: Inf, resolved: false},
+		{name: "Set_Res", request: SetC, response: SetRes, resolved: true},
+		{name: "Get_Res to SetC", request: SetC, response: GetRes, resolved: false},
+		{name: "SetGet_Res", request: SetGet, response: SetGetRes, resolved: true},
+		{name: "INF to INF_REQ", request: InfReq, response: Inf, resolved: true},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &ControllerNode{}
+			res, cancel := c.await("192.168.1.5", 0x0102, tc.request)
+			defer cancel()
+
+			f := NewFrame(0x0102, NewObject(AirConditionerGroup, HomeAirConditioner, 0x01), NewObject(ControllerGroup, Controller, 0x01), tc.response, []Property{})
+			c.resolve("192.168.1.5:3610", f)
+
+			select {
+			case got := <-res:
+				if !tc.resolved {
+					t.Errorf("resolved with %s", got.ESV)
+				}
+			default:
+				if tc.resolved {
+					t.Errorf("not resolved")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package echonetlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/u-one/go-el-controller/transport"
+)
+
+func TestVirtualLAN(t *testing.T) {
+	testcases := []struct {
+		name          string
+		controllerIP  string
+		deviceIP      string
+		group         string
+		latency       time.Duration
+		lossRate      float64
+		duplicateRate float64
+	}{
+		{
+			name:         "reliable",
+			controllerIP: "192.168.1.10",
+			deviceIP:     "192.168.1.20",
+			latency:      10 * time.Millisecond,
+		},
+		{
+			name:          "lossy",
+			controllerIP:  "192.168.1.10",
+			deviceIP:      "192.168.1.20",
+			latency:       5 * time.Millisecond,
+			lossRate:      0.2,
+			duplicateRate: 0.2,
+		},
+		{
+			name:         "IPv6",
+			controllerIP: "fe80::10%eth0",
+			deviceIP:     "fe80::20%eth0",
+			group:        MulticastIPv6,
+			latency:      10 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+			defer cancel()
+
+			lan := transport.NewVirtualLAN(1)
+			lan.Latency = tc.latency
+			lan.LossRate = tc.lossRate
+			lan.DuplicateRate = tc.duplicateRate
+
+			group := multicastGroup(tc.group)
+			dh := lan.Host(tc.deviceIP)
+			n := &DeviceNode{
+				MulticastReceiver: dh.MulticastReceiver(),
+				UnicastReceiver:   dh.UnicastReceiver(),
+				MulticastSender:   dh.MulticastSender(group, Port),
+				UnicastSender:     dh.UnicastSender(Port),
+				MulticastGroup:    tc.group,
+			}
+			n.AddDevice(newTestSensor(1))
+			n.Start(ctx)
+			defer n.Close()
+
+			ch := lan.Host(tc.controllerIP)
+			c := &ControllerNode{
+				MulticastReceiver: ch.MulticastReceiver(),
+				UnicastReceiver:   ch.UnicastReceiver(),
+				MulticastSender:   ch.MulticastSender(group, Port),
+				UnicastSender:     ch.UnicastSender(Port),
+				MulticastGroup:    tc.group,
+				RequestTimeout:    100 * time.Millisecond,
+				RequestRetries:    10,
+				NodeTTL:           2 * time.Second,
+			}
+			c.Start(ctx)
+			defer c.Close()
+
+			// discovery
+			obj := NewObject(SensorGroup, TemperatureSensor, 1)
+			var got Device
+			for found := false; !found; {
+				for _, node := range c.Nodes() {
+					if node.Address == tc.deviceIP && len(node.Devices) == 1 && node.Devices[0].GetPropertyMap.Len() > 0 {
+						got, found = node.Devices[0], true
+					}
+				}
+				select {
+				case <-ctx.Done():
+					t.Fatalf("device not found: %#v", c.Nodes())
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+			want := Device{
+				Object:          obj,
+				AnnoPropertyMap: NewPropertyMap(OperationStatus, InstallationLocation),
+				SetPropertyMap:  NewPropertyMap(InstallationLocation),
+				GetPropertyMap:  NewPropertyMap(OperationStatus, InstallationLocation, MeasuredTemperature, StageChangeAnnouncePropertyMap, SetPropertyMap, GetPropertyMap),
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+
+			// Set and Get
+			err := c.SetC(ctx, tc.deviceIP, obj, Property{Code: byte(InstallationLocation), Len: 1, Data: Data{0x10}})
+			if err != nil {
+				t.Fatalf("SetC failed: %s", err)
+			}
+			props, err := c.Get(ctx, tc.deviceIP, obj, InstallationLocation, MeasuredTemperature)
+			if err != nil {
+				t.Fatalf("Get failed: %s", err)
+			}
+			wantProps := []Property{
+				{Code: byte(InstallationLocation), Len: 1, Data: Data{0x10}},
+				{Code: byte(MeasuredTemperature), Len: 2, Data: Data{0x00, 0xfa}},
+			}
+			if diff := cmp.Diff(wantProps, props); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/u-one/go-el-controller
 
-go 1.13
+go 1.16
 
 require (
 	github.com/goburrow/serial v0.1.0
@@ -0,0 +1,37 @@
+// Package envflag sets flags from environment variables,
+// so that commands can be configured by either of them.
+package envflag
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Parse parses command line flags, and then sets flags in env (flag name to name of environment variable)
+// which are not given in command line from the environment variables.
+func Parse(env map[string]string) error {
+	flag.Parse()
+	return Set(flag.CommandLine, env, os.LookupEnv)
+}
+
+// Set sets flags of fs in env which are not set yet from values returned by lookup
+func Set(fs *flag.FlagSet, env map[string]string, lookup func(string) (string, bool)) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for name, key := range env {
+		if set[name] {
+			continue
+		}
+		v, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		if err := fs.Set(name, v); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package envflag
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+
+	environ := map[string]string{"EL_LANG": "en", "EL_CLASS_DIR": "csv", "EL_IPV6": "maybe"}
+	lookup := func(key string) (string, bool) {
+		v, ok := environ[key]
+		return v, ok
+	}
+
+	type result struct {
+		Lang, ClassDir, Interface string
+	}
+
+	testcases := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want result
+		err  string // prefix of error
+	}{
+		{
+			name: "from env",
+			env:  map[string]string{"lang": "EL_LANG", "class-dir": "EL_CLASS_DIR", "interface": "EL_INTERFACE"},
+			want: result{Lang: "en", ClassDir: "csv", Interface: "eth0"},
+		},
+		{
+			name: "command line has priority",
+			args: []string{"-lang", "ja"},
+			env:  map[string]string{"lang": "EL_LANG", "class-dir": "EL_CLASS_DIR"},
+			want: result{Lang: "ja", ClassDir: "csv", Interface: "eth0"},
+		},
+		{
+			name: "invalid value",
+			env:  map[string]string{"ipv6": "EL_IPV6"},
+			want: result{Interface: "eth0"},
+			err:  "invalid EL_IPV6: ",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := flag.NewFlagSet(tc.name, flag.ContinueOnError)
+			var got result
+			fs.StringVar(&got.Lang, "lang", "", "")
+			fs.StringVar(&got.ClassDir, "class-dir", "", "")
+			fs.StringVar(&got.Interface, "interface", "eth0", "")
+			fs.Bool("ipv6", false, "")
+			if err := fs.Parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := Set(fs, tc.env, lookup)
+			if tc.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.err != "" && (err == nil || !strings.HasPrefix(err.Error(), tc.err)) {
+				t.Fatalf("Diffrent error: want:%s..., got:%v", tc.err, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
@@ -3,15 +3,32 @@ package main
 import (
 	"flag"
 	"log"
+	"strings"
 
 	"github.com/u-one/go-el-controller/wisun"
 )
 
 var portAddr = flag.String("port", "COM4", "Serial port address (COM4)")
+var scenario = flag.String("scenario", "default", "scenario file (*.json), or name of a builtin scenario: default, join_failure, session_expiry or error_codes")
 
 func main() {
-	log.Println("Started port:", *portAddr)
+	flag.Parse()
+
+	s, err := loadScenario(*scenario)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Started port:", *portAddr, "scenario:", s.Name)
 	e := wisun.NewBP35C2Emulator(*portAddr)
 	defer e.Close()
+	e.SetScenario(s)
 	e.Start()
 }
+
+func loadScenario(name string) (*wisun.Scenario, error) {
+	if strings.HasSuffix(name, ".json") {
+		return wisun.LoadScenario(name)
+	}
+	return wisun.BuiltinScenario(name)
+}
@@ -0,0 +1,86 @@
+// classdata-gen copies CSV files of ECHONETLite-ObjectDatabase into echonetlite/classdata,
+// which is bundled into the binary. It is run by go generate in echonetlite.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/u-one/go-el-controller/echonetlite"
+	"github.com/u-one/go-el-controller/internal/envflag"
+)
+
+var src = flag.String("src", "", "data/csv directory of ECHONETLite-ObjectDatabase (env EL_CLASS_DIR)")
+var out = flag.String("out", "classdata", "directory to write CSV files")
+
+func main() {
+	err := envflag.Parse(map[string]string{"src": "EL_CLASS_DIR"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *src == "" {
+		log.Fatal("-src or EL_CLASS_DIR is required: clone https://github.com/SonyCSL/ECHONETLite-ObjectDatabase and pass its data/csv directory")
+	}
+	for _, lang := range []string{echonetlite.LangJA, echonetlite.LangEN} {
+		n, err := copyCSV(filepath.Join(*src, lang), filepath.Join(*out, lang))
+		if err != nil {
+			log.Fatal(err)
+		}
+		// the copied files must be loadable
+		dict, err := echonetlite.LoadClassDictionary(*out, lang)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%s: %d files, %d class groups", lang, n, len(dict))
+	}
+}
+
+// copyCSV replaces CSV files in dst with ones in src, and returns num of files copied
+func copyCSV(src, dst string) (int, error) {
+	files, err := filepath.Glob(filepath.Join(src, "*.csv"))
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("no CSV files in %s", src)
+	}
+	old, err := filepath.Glob(filepath.Join(dst, "*.csv"))
+	if err != nil {
+		return 0, err
+	}
+	for _, f := range old {
+		if err := os.Remove(f); err != nil {
+			return 0, err
+		}
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return 0, err
+	}
+	for _, f := range files {
+		if err := copyFile(f, filepath.Join(dst, filepath.Base(f))); err != nil {
+			return 0, err
+		}
+	}
+	return len(files), nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	o, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(o, in); err != nil {
+		o.Close()
+		return err
+	}
+	return o.Close()
+}
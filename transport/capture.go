@@ -0,0 +1,289 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Direction is direction of a captured packet
+type Direction string
+
+const (
+	// Sent is direction of packets sent by the node
+	Sent Direction = "sent"
+	// Received is direction of packets received by the node
+	Received Direction = "received"
+)
+
+// Packet is a captured datagram
+type Packet struct {
+	Time      time.Time
+	Direction Direction
+	Peer      string // sender of a received packet as reported by the receiver, or destination IP of a sent unicast packet. Empty for sent multicast packets.
+	Group     string // multicast group, empty for unicast packets
+	Data      []byte
+}
+
+// CaptureWriter writes captured packets to a file
+type CaptureWriter interface {
+	Write(p Packet) error
+	Close() error
+}
+
+// Capture records packets sent and received through transports wrapped with it
+type Capture struct {
+	w   CaptureWriter
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewCapture returns Capture which writes packets with w
+func NewCapture(w CaptureWriter) *Capture {
+	return &Capture{w: w, now: time.Now}
+}
+
+// CreateCapture creates a capture file at path. It is written in pcapng if the extension is .pcapng, otherwise in JSONL.
+func CreateCapture(path string) (*Capture, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("capture error: %w", err)
+	}
+	var w CaptureWriter
+	if filepath.Ext(path) == ".pcapng" {
+		w, err = NewPcapngWriter(f)
+	} else {
+		w = NewJSONLWriter(f)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("capture error: %w", err)
+	}
+	return NewCapture(w), nil
+}
+
+// Close closes the writer
+func (c *Capture) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.w.Close()
+}
+
+func (c *Capture) record(p Packet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p.Time = c.now()
+	p.Data = append([]byte{}, p.Data...)
+	if err := c.w.Write(p); err != nil {
+		log.Println("capture error:", err)
+	}
+}
+
+// MulticastReceiver returns MulticastReceiver which records packets received by r
+func (c *Capture) MulticastReceiver(r MulticastReceiver) MulticastReceiver {
+	return &captureMulticastReceiver{capture: c, r: r}
+}
+
+// UnicastReceiver returns UnicastReceiver which records packets received by r
+func (c *Capture) UnicastReceiver(r UnicastReceiver) UnicastReceiver {
+	return &captureUnicastReceiver{capture: c, r: r}
+}
+
+// MulticastSender returns MulticastSender which records packets sent by s to group
+func (c *Capture) MulticastSender(s MulticastSender, group string) MulticastSender {
+	return &captureMulticastSender{capture: c, s: s, group: group}
+}
+
+// UnicastSender returns UnicastSender which records packets sent by s
+func (c *Capture) UnicastSender(s UnicastSender) UnicastSender {
+	return &captureUnicastSender{capture: c, s: s}
+}
+
+// forward records packets from in and passes them through. The channel is closed when in is closed.
+// If ctx is done while out is full, the rest of in is drained so that the receiver can stop.
+func (c *Capture) forward(ctx context.Context, wg *sync.WaitGroup, in <-chan ReceiveResult, group string) <-chan ReceiveResult {
+	out := make(chan ReceiveResult, cap(in))
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(out)
+		for result := range in {
+			if result.Err == nil {
+				c.record(Packet{Direction: Received, Peer: result.Address, Group: group, Data: result.Data})
+			}
+			select {
+			case out <- result:
+			case <-ctx.Done():
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+type captureMulticastReceiver struct {
+	capture *Capture
+	r       MulticastReceiver
+	wg      sync.WaitGroup
+}
+
+// Start starts r, and records packets received by it
+func (r *captureMulticastReceiver) Start(ctx context.Context, ip, port string) <-chan ReceiveResult {
+	return r.capture.forward(ctx, &r.wg, r.r.Start(ctx, ip, port), ip)
+}
+
+// Wait waits for r to stop
+func (r *captureMulticastReceiver) Wait() error {
+	r.wg.Wait()
+	return r.r.Wait()
+}
+
+type captureUnicastReceiver struct {
+	capture *Capture
+	r       UnicastReceiver
+	wg      sync.WaitGroup
+}
+
+// Start starts r, and records packets received by it
+func (r *captureUnicastReceiver) Start(ctx context.Context, port string) <-chan ReceiveResult {
+	return r.capture.forward(ctx, &r.wg, r.r.Start(ctx, port), "")
+}
+
+// Wait waits for r to stop
+func (r *captureUnicastReceiver) Wait() error {
+	r.wg.Wait()
+	return r.r.Wait()
+}
+
+type captureMulticastSender struct {
+	capture *Capture
+	s       MulticastSender
+	group   string
+}
+
+// Send sends data with s, and records it
+func (s *captureMulticastSender) Send(data []byte) {
+	s.capture.record(Packet{Direction: Sent, Group: s.group, Data: data})
+	s.s.Send(data)
+}
+
+// Close closes s
+func (s *captureMulticastSender) Close() {
+	s.s.Close()
+}
+
+type captureUnicastSender struct {
+	capture *Capture
+	s       UnicastSender
+}
+
+// Send sends data with s, and records it if it is sent
+func (s *captureUnicastSender) Send(ip string, data []byte) error {
+	err := s.s.Send(ip, data)
+	if err != nil {
+		return err
+	}
+	s.capture.record(Packet{Direction: Sent, Peer: ip, Data: data})
+	return nil
+}
+
+// Close closes s
+func (s *captureUnicastSender) Close() {
+	s.s.Close()
+}
+
+// jsonlPacket is a line of JSONL capture files
+type jsonlPacket struct {
+	Time      time.Time `json:"time"`
+	Direction Direction `json:"direction"`
+	Peer      string    `json:"peer,omitempty"`
+	Group     string    `json:"group,omitempty"`
+	Data      string    `json:"data"` // hex, as in logs of frames
+}
+
+type jsonlWriter struct {
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewJSONLWriter returns CaptureWriter which writes a packet as a JSON object per line
+func NewJSONLWriter(w io.Writer) CaptureWriter {
+	return &jsonlWriter{w: w, enc: json.NewEncoder(w)}
+}
+
+// Write writes p as a line
+func (w *jsonlWriter) Write(p Packet) error {
+	return w.enc.Encode(jsonlPacket{
+		Time:      p.Time,
+		Direction: p.Direction,
+		Peer:      p.Peer,
+		Group:     p.Group,
+		Data:      hex.EncodeToString(p.Data),
+	})
+}
+
+// Close closes the underlying writer if it is io.Closer
+func (w *jsonlWriter) Close() error {
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// ReadCapture reads packets from a capture file written in pcapng or JSONL
+func ReadCapture(r io.Reader) ([]Packet, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err == nil && bytes.Equal(magic, pcapngMagic) {
+		return readPcapng(br)
+	}
+	return readJSONL(br)
+}
+
+// ReadCaptureFile reads packets from the capture file at path
+func ReadCaptureFile(path string) ([]Packet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("capture error: %w", err)
+	}
+	defer f.Close()
+	return ReadCapture(f)
+}
+
+func readJSONL(r io.Reader) ([]Packet, error) {
+	var packets []Packet
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20) // hex of the largest datagram
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var jp jsonlPacket
+		if err := json.Unmarshal(scanner.Bytes(), &jp); err != nil {
+			return nil, fmt.Errorf("capture error: line %d: %w", line, err)
+		}
+		if jp.Direction != Sent && jp.Direction != Received {
+			return nil, fmt.Errorf("capture error: line %d: invalid direction %q", line, jp.Direction)
+		}
+		data, err := hex.DecodeString(jp.Data)
+		if err != nil {
+			return nil, fmt.Errorf("capture error: line %d: %w", line, err)
+		}
+		packets = append(packets, Packet{Time: jp.Time, Direction: jp.Direction, Peer: jp.Peer, Group: jp.Group, Data: data})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("capture error: %w", err)
+	}
+	return packets, nil
+}
@@ -0,0 +1,242 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCapture(t *testing.T) {
+	packets := []Packet{
+		{Direction: Sent, Group: "224.0.23.0", Data: []byte{0x10, 0x81, 0x00, 0x01}},
+		{Direction: Received, Peer: "192.168.1.20", Group: "224.0.23.0", Data: []byte{0x10, 0x81, 0x00, 0x01, 0x0e}},
+		{Direction: Sent, Peer: "192.168.1.20", Data: []byte{0x10, 0x81, 0x00, 0x02}},
+		{Direction: Received, Peer: "192.168.1.20:3610", Data: []byte{0x10, 0x81, 0x00, 0x02, 0x0e, 0xf0}},
+		{Direction: Received, Peer: "[fe80::20]:49152", Data: []byte{0x10, 0x81, 0x00, 0x03, 0x01}},
+		{Direction: Received, Peer: "fe80::20", Group: "ff02::1", Data: []byte{0x10, 0x81, 0x00, 0x04}},
+		{Direction: Sent, Group: "ff02::1", Data: []byte{}},
+	}
+
+	testcases := []struct {
+		name      string
+		newWriter func(buf *bytes.Buffer) (CaptureWriter, error)
+	}{
+		{
+			name: "JSONL",
+			newWriter: func(buf *bytes.Buffer) (CaptureWriter, error) {
+				return NewJSONLWriter(buf), nil
+			},
+		},
+		{
+			name: "pcapng",
+			newWriter: func(buf *bytes.Buffer) (CaptureWriter, error) {
+				return NewPcapngWriter(buf)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := &bytes.Buffer{}
+			w, err := tc.newWriter(buf)
+			if err != nil {
+				t.Fatalf("failed to create writer: %s", err)
+			}
+			c := NewCapture(w)
+			now := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+			c.now = func() time.Time {
+				now = now.Add(time.Millisecond)
+				return now
+			}
+			want := []Packet{}
+			for _, p := range packets {
+				c.record(p)
+				p.Time = now
+				want = append(want, p)
+			}
+			if err := c.Close(); err != nil {
+				t.Fatalf("Close failed: %s", err)
+			}
+
+			got, err := ReadCapture(buf)
+			if err != nil {
+				t.Fatalf("ReadCapture failed: %s", err)
+			}
+			if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReadCapture_Error(t *testing.T) {
+	testcases := []struct {
+		name string
+		in   string
+	}{
+		{name: "invalid JSON", in: "{\"direction\":\"sent\"\n"},
+		{name: "invalid direction", in: "{\"direction\":\"lost\",\"data\":\"1081\"}\n"},
+		{name: "invalid data", in: "{\"direction\":\"sent\",\"data\":\"108\"}\n"},
+		{name: "truncated pcapng", in: "\x0a\x0d\x0d\x0a\x1c\x00\x00\x00\x4d\x3c"},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := ReadCapture(bytes.NewBufferString(tc.in)); err == nil {
+				t.Errorf("ReadCapture succeeded")
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r := NewReplay([]Packet{
+		{Direction: Received, Peer: "192.168.1.20", Group: "224.0.23.0", Data: []byte{0x01}},
+		{Direction: Sent, Peer: "192.168.1.20", Data: []byte{0x10, 0x81, 0x00, 0x01, 0x02}},
+		{Direction: Received, Peer: "192.168.1.20:3610", Data: []byte{0x10, 0x81, 0x00, 0x01, 0x03}},
+		{Direction: Received, Peer: "192.168.1.30", Group: "224.0.23.0", Data: []byte{0x04}},
+	})
+	mch := r.MulticastReceiver().Start(ctx, "224.0.23.0", ":3610")
+	uch := r.UnicastReceiver().Start(ctx, ":3610")
+	s := r.UnicastSender()
+
+	got := <-mch
+	if diff := cmp.Diff(ReceiveResult{Data: []byte{0x01}, Address: "192.168.1.20"}, got); diff != "" {
+		t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+	}
+
+	// the rest waits for the node to send
+	select {
+	case got := <-uch:
+		t.Fatalf("received before sending: %#v", got)
+	case got := <-mch:
+		t.Fatalf("received before sending: %#v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// another TID, which the response follows
+	if err := s.Send("192.168.1.20", []byte{0x10, 0x81, 0x00, 0x07, 0x02}); err != nil {
+		t.Fatalf("Send failed: %s", err)
+	}
+
+	got = <-uch
+	if diff := cmp.Diff(ReceiveResult{Data: []byte{0x10, 0x81, 0x00, 0x07, 0x03}, Address: "192.168.1.20:3610"}, got); diff != "" {
+		t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+	}
+	got = <-mch
+	if diff := cmp.Diff(ReceiveResult{Data: []byte{0x04}, Address: "192.168.1.30"}, got); diff != "" {
+		t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+	}
+	select {
+	case <-r.Done():
+	case <-ctx.Done():
+		t.Fatalf("replay not done")
+	}
+
+	sent := r.Sent()
+	if len(sent) != 1 || sent[0].Peer != "192.168.1.20" || !bytes.Equal(sent[0].Data, []byte{0x10, 0x81, 0x00, 0x07, 0x02}) {
+		t.Errorf("Diffrent result: %#v", sent)
+	}
+}
+
+// countWriter counts packets written
+type countWriter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (w *countWriter) Write(p Packet) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.n++
+	return nil
+}
+
+func (w *countWriter) Close() error {
+	return nil
+}
+
+func (w *countWriter) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.n
+}
+
+// stubReceiver delivers results to a channel of capacity 1 until ctx is done
+type stubReceiver struct {
+	results []ReceiveResult
+	wg      sync.WaitGroup
+}
+
+func (r *stubReceiver) Start(ctx context.Context, ip, port string) <-chan ReceiveResult {
+	ch := make(chan ReceiveResult, 1)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer close(ch)
+		for _, result := range r.results {
+			select {
+			case ch <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+		<-ctx.Done()
+	}()
+	return ch
+}
+
+func (r *stubReceiver) Wait() error {
+	r.wg.Wait()
+	return nil
+}
+
+func TestCapture_CancelWithFullBuffer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := &countWriter{}
+	stub := &stubReceiver{}
+	for i := 0; i < 4; i++ {
+		stub.results = append(stub.results, ReceiveResult{Data: []byte{0x10, 0x81, 0x00, byte(i)}, Address: "192.168.1.20"})
+	}
+	r := NewCapture(w).MulticastReceiver(stub)
+	r.Start(ctx, "224.0.23.0", ":3610")
+
+	// nobody reads, so the first is buffered and the second is blocked after recorded
+	deadline := time.Now().Add(5 * time.Second)
+	for w.count() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("packets not recorded: %d", w.count())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- r.Wait()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Wait failed: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Wait not returned after cancel")
+	}
+}
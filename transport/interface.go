@@ -0,0 +1,82 @@
+package transport
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ResolveInterface returns the network interface selected by its name, e.g. "eth0",
+// or by CIDR which contains its address, e.g. "192.168.1.0/24".
+// It returns nil for empty spec, which means the interface chosen by the system.
+func ResolveInterface(spec string) (*net.Interface, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	_, cidr, err := net.ParseCIDR(spec)
+	if err != nil {
+		ifi, err := net.InterfaceByName(spec)
+		if err != nil {
+			return nil, fmt.Errorf("interface %s: %w", spec, err)
+		}
+		return ifi, nil
+	}
+
+	ifis, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("interfaces: %w", err)
+	}
+	for _, ifi := range ifis {
+		ifi := ifi
+		ip, err := interfaceIP(&ifi, func(ip net.IP) bool { return cidr.Contains(ip) })
+		if err == nil && ip != nil {
+			return &ifi, nil
+		}
+	}
+	return nil, fmt.Errorf("no interface in %s", spec)
+}
+
+// interfaceIP returns the first address of ifi which satisfies match, or nil if none
+func interfaceIP(ifi *net.Interface, match func(ip net.IP) bool) (net.IP, error) {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("addresses of %s: %w", ifi.Name, err)
+	}
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if ok && match(ipnet.IP) {
+			return ipnet.IP, nil
+		}
+	}
+	return nil, nil
+}
+
+// sameFamily returns a function which matches addresses in the same family as ip
+func sameFamily(ip net.IP) func(net.IP) bool {
+	ipv4 := ip.To4() != nil
+	return func(addr net.IP) bool {
+		return (addr.To4() != nil) == ipv4
+	}
+}
+
+// hostPort joins ip and port such as ":3610", bracketing IPv6 address
+func hostPort(ip, port string) string {
+	return net.JoinHostPort(ip, strings.TrimPrefix(port, ":"))
+}
+
+// udpAddrOn returns address of ip and port, scoped to ifi if ip is IPv6 link-local
+func udpAddrOn(ifi *net.Interface, ip, port string) (*net.UDPAddr, error) {
+	address, err := net.ResolveUDPAddr("udp", hostPort(ip, port))
+	if err != nil {
+		return nil, err
+	}
+	if ifi != nil && address.Zone == "" && (address.IP.IsLinkLocalMulticast() || address.IP.IsLinkLocalUnicast()) && address.IP.To4() == nil {
+		address.Zone = ifi.Name
+	}
+	return address, nil
+}
+
+// senderAddress returns address of the sender reported in ReceiveResult, keeping zone of IPv6 link-local address
+func senderAddress(addr *net.UDPAddr) string {
+	return (&net.IPAddr{IP: addr.IP, Zone: addr.Zone}).String()
+}
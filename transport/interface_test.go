@@ -0,0 +1,127 @@
+package transport
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// loopback returns the loopback interface, whose name differs among systems
+func loopback(t *testing.T) *net.Interface {
+	t.Helper()
+
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Fatalf("Interfaces failed: %s", err)
+	}
+	for _, ifi := range ifis {
+		if ifi.Flags&net.FlagLoopback != 0 && ifi.Flags&net.FlagUp != 0 {
+			ifi := ifi
+			return &ifi
+		}
+	}
+	t.Skip("no loopback interface")
+	return nil
+}
+
+func TestResolveInterface(t *testing.T) {
+	lo := loopback(t)
+
+	testcases := []struct {
+		name string
+		spec string
+		want string // name of the interface, empty for nil
+		err  string // prefix of the error, which differs among systems
+	}{
+		{name: "empty", spec: ""},
+		{name: "name", spec: lo.Name, want: lo.Name},
+		{name: "CIDR", spec: "127.0.0.0/8", want: lo.Name},
+		{name: "no match", spec: "198.51.100.0/24", err: "no interface in 198.51.100.0/24"},
+		{name: "unknown name", spec: "nosuchif0", err: "interface nosuchif0: "},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ifi, err := ResolveInterface(tc.spec)
+			if (err == nil) != (tc.err == "") || (err != nil && !strings.HasPrefix(err.Error(), tc.err)) {
+				t.Errorf("Diffrent error: want:%s, got:%v", tc.err, err)
+			}
+			got := ""
+			if ifi != nil {
+				got = ifi.Name
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_udpAddrOn(t *testing.T) {
+	lo := loopback(t)
+
+	testcases := []struct {
+		name string
+		ifi  *net.Interface
+		ip   string
+		want string
+	}{
+		{name: "IPv4 multicast", ifi: lo, ip: "224.0.23.0", want: "224.0.23.0:3610"},
+		{name: "IPv6 link-local multicast", ifi: lo, ip: "ff02::1", want: "[ff02::1%" + lo.Name + "]:3610"},
+		{name: "IPv6 link-local unicast", ifi: lo, ip: "fe80::1", want: "[fe80::1%" + lo.Name + "]:3610"},
+		{name: "IPv6 link-local with zone", ifi: lo, ip: "fe80::1%eth9", want: "[fe80::1%eth9]:3610"},
+		{name: "IPv6 global", ifi: lo, ip: "fd00::2", want: "[fd00::2]:3610"},
+		{name: "no interface", ip: "ff02::1", want: "[ff02::1]:3610"},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := udpAddrOn(tc.ifi, tc.ip, ":3610")
+			if err != nil {
+				t.Fatalf("udpAddrOn failed: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, got.String()); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_interfaceIP(t *testing.T) {
+	lo := loopback(t)
+
+	testcases := []struct {
+		name  string
+		match func(net.IP) bool
+		want  net.IP
+	}{
+		{name: "IPv4 source address", match: sameFamily(net.ParseIP("224.0.23.0")), want: net.IPv4(127, 0, 0, 1)},
+		{name: "no match", match: func(ip net.IP) bool { return false }},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := interfaceIP(lo, tc.match)
+			if err != nil {
+				t.Fatalf("interfaceIP failed: %s", err)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("Diffrent result: want:%v, got:%v", tc.want, got)
+			}
+		})
+	}
+}
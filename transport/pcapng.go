@@ -0,0 +1,403 @@
+package transport
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pcapng block types
+const (
+	pcapngSectionHeader     = 0x0A0D0D0A
+	pcapngInterface         = 0x00000001
+	pcapngEnhancedPacket    = 0x00000006
+	pcapngByteOrderMagic    = 0x1A2B3C4D
+	pcapngLinkTypeRaw       = 101 // IPv4 or IPv6 packets without link layer header
+	pcapngOptionEndOfOpt    = 0
+	pcapngOptionFlags       = 2 // epb_flags
+	pcapngFlagInbound       = 1
+	pcapngFlagOutbound      = 2
+	pcapngFlagDirectionMask = 3
+)
+
+// capturePort is the UDP port written to pcapng for addresses which have no port, i.e. the port of Echonet-Lite
+const capturePort = 3610
+
+const udpProtocol = 17
+
+var pcapngMagic = []byte{0x0A, 0x0D, 0x0D, 0x0A}
+
+var errInvalidPcapng = errors.New("invalid pcapng")
+
+type pcapngWriter struct {
+	w io.Writer
+}
+
+// NewPcapngWriter returns CaptureWriter which writes packets in pcapng, so that they can be opened with Wireshark.
+// Datagrams are written with IP and UDP headers. Address of the node itself is written as unspecified address,
+// and ports which are unknown as 3610.
+func NewPcapngWriter(w io.Writer) (CaptureWriter, error) {
+	pw := &pcapngWriter{w: w}
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:], pcapngByteOrderMagic)
+	binary.LittleEndian.PutUint16(shb[4:], 1)                  // major version
+	binary.LittleEndian.PutUint16(shb[6:], 0)                  // minor version
+	binary.LittleEndian.PutUint64(shb[8:], 0xFFFFFFFFFFFFFFFF) // section length is not specified
+	if err := pw.writeBlock(pcapngSectionHeader, shb); err != nil {
+		return nil, err
+	}
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb[0:], pcapngLinkTypeRaw)
+	binary.LittleEndian.PutUint32(idb[4:], 0) // no limit of snap length
+	if err := pw.writeBlock(pcapngInterface, idb); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// writeBlock writes a block with body, which must be padded to 32 bits
+func (w *pcapngWriter) writeBlock(blockType uint32, body []byte) error {
+	length := uint32(12 + len(body))
+	b := make([]byte, 0, length)
+	b = appendUint32(binary.LittleEndian, b, blockType)
+	b = appendUint32(binary.LittleEndian, b, length)
+	b = append(b, body...)
+	b = appendUint32(binary.LittleEndian, b, length)
+	_, err := w.w.Write(b)
+	return err
+}
+
+// Write writes p as an enhanced packet block
+func (w *pcapngWriter) Write(p Packet) error {
+	packet, err := ipPacket(p)
+	if err != nil {
+		return err
+	}
+	ts := uint64(p.Time.UnixNano() / int64(time.Microsecond)) // default resolution of timestamps
+	flags := uint32(pcapngFlagInbound)
+	if p.Direction == Sent {
+		flags = pcapngFlagOutbound
+	}
+
+	b := make([]byte, 0, 20+len(packet)+3+12)
+	b = appendUint32(binary.LittleEndian, b, 0) // interface
+	b = appendUint32(binary.LittleEndian, b, uint32(ts>>32))
+	b = appendUint32(binary.LittleEndian, b, uint32(ts))
+	b = appendUint32(binary.LittleEndian, b, uint32(len(packet))) // captured length
+	b = appendUint32(binary.LittleEndian, b, uint32(len(packet))) // original length
+	b = append(b, packet...)
+	b = append(b, make([]byte, padding(len(packet)))...)
+	b = appendUint16(binary.LittleEndian, b, pcapngOptionFlags)
+	b = appendUint16(binary.LittleEndian, b, 4)
+	b = appendUint32(binary.LittleEndian, b, flags)
+	b = appendUint16(binary.LittleEndian, b, pcapngOptionEndOfOpt)
+	b = appendUint16(binary.LittleEndian, b, 0)
+	return w.writeBlock(pcapngEnhancedPacket, b)
+}
+
+// Close closes the underlying writer if it is io.Closer
+func (w *pcapngWriter) Close() error {
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func appendUint16(order binary.ByteOrder, b []byte, v uint16) []byte {
+	var buf [2]byte
+	order.PutUint16(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint32(order binary.ByteOrder, b []byte, v uint32) []byte {
+	var buf [4]byte
+	order.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// padding returns length to pad n bytes to 32 bits
+func padding(n int) int {
+	return (4 - n%4) % 4
+}
+
+// splitPeer returns IP and port of addr, which is an IP or IP:port. port is capturePort if addr has no port.
+func splitPeer(addr string) (net.IP, int, error) {
+	host, port := addr, capturePort
+	if h, p, err := net.SplitHostPort(addr); err == nil {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid port: %s", addr)
+		}
+		host, port = h, n
+	}
+	// zone of link-local address is not written
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, 0, fmt.Errorf("invalid address: %s", addr)
+	}
+	return ip, port, nil
+}
+
+// ipPacket returns IP packet which carries p in UDP
+func ipPacket(p Packet) ([]byte, error) {
+	var remote net.IP
+	remotePort := capturePort
+	if p.Peer != "" {
+		ip, port, err := splitPeer(p.Peer)
+		if err != nil {
+			return nil, err
+		}
+		remote, remotePort = ip, port
+	}
+	var group net.IP
+	if p.Group != "" {
+		ip, _, err := splitPeer(p.Group)
+		if err != nil {
+			return nil, err
+		}
+		group = ip
+	}
+	if remote == nil && group == nil {
+		return nil, fmt.Errorf("no address: %#v", p)
+	}
+
+	family := remote
+	if family == nil {
+		family = group
+	}
+	local := net.IPv6unspecified
+	if family.To4() != nil {
+		local = net.IPv4zero
+	}
+
+	var src, dst net.IP
+	srcPort, dstPort := capturePort, capturePort
+	switch {
+	case p.Direction == Received && group != nil:
+		src, dst, srcPort = remote, group, remotePort
+		if src == nil {
+			src = local
+		}
+	case p.Direction == Received:
+		src, dst, srcPort = remote, local, remotePort
+	case group != nil:
+		src, dst = local, group
+	default:
+		src, dst, dstPort = local, remote, remotePort
+	}
+	return udpPacket(src, dst, srcPort, dstPort, p.Data), nil
+}
+
+// udpPacket returns IPv4 or IPv6 packet of UDP datagram
+func udpPacket(src, dst net.IP, srcPort, dstPort int, data []byte) []byte {
+	udp := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint16(udp[0:], uint16(srcPort))
+	binary.BigEndian.PutUint16(udp[2:], uint16(dstPort))
+	binary.BigEndian.PutUint16(udp[4:], uint16(8+len(data)))
+	udp = append(udp, data...)
+
+	if src4, dst4 := src.To4(), dst.To4(); src4 != nil && dst4 != nil {
+		pseudo := append(append([]byte{}, src4...), dst4...)
+		pseudo = append(pseudo, 0, udpProtocol, udp[4], udp[5])
+		binary.BigEndian.PutUint16(udp[6:], udpChecksum(pseudo, udp))
+
+		ip := make([]byte, 20, 20+len(udp))
+		ip[0] = 0x45 // version 4, header length 20
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+len(udp)))
+		ip[8] = 64 // TTL
+		ip[9] = udpProtocol
+		copy(ip[12:], src4)
+		copy(ip[16:], dst4)
+		binary.BigEndian.PutUint16(ip[10:], ^checksum(0, ip))
+		return append(ip, udp...)
+	}
+
+	pseudo := append(append([]byte{}, src.To16()...), dst.To16()...)
+	pseudo = appendUint32(binary.BigEndian, pseudo, uint32(len(udp)))
+	pseudo = append(pseudo, 0, 0, 0, udpProtocol)
+	binary.BigEndian.PutUint16(udp[6:], udpChecksum(pseudo, udp))
+
+	ip := make([]byte, 40, 40+len(udp))
+	ip[0] = 0x60 // version 6
+	binary.BigEndian.PutUint16(ip[4:], uint16(len(udp)))
+	ip[6] = udpProtocol
+	ip[7] = 64 // hop limit
+	copy(ip[8:], src.To16())
+	copy(ip[24:], dst.To16())
+	return append(ip, udp...)
+}
+
+// checksum adds b to the ones' complement sum
+func checksum(sum uint32, b []byte) uint16 {
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return uint16(sum)
+}
+
+func udpChecksum(pseudo, udp []byte) uint16 {
+	sum := ^checksum(uint32(checksum(0, pseudo)), udp)
+	if sum == 0 {
+		// 0 means no checksum
+		return 0xffff
+	}
+	return sum
+}
+
+// readPcapng reads packets written by pcapngWriter
+func readPcapng(r io.Reader) ([]Packet, error) {
+	var packets []Packet
+	var order binary.ByteOrder = binary.LittleEndian
+	var linkTypes []uint16
+	header := make([]byte, 8)
+	for {
+		_, err := io.ReadFull(r, header)
+		if err == io.EOF {
+			return packets, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("capture error: %w", err)
+		}
+		blockType := binary.LittleEndian.Uint32(header)
+		if blockType == pcapngSectionHeader {
+			// byte order of the section follows the length
+			var magic [4]byte
+			if _, err := io.ReadFull(r, magic[:]); err != nil {
+				return nil, fmt.Errorf("capture error: %w", err)
+			}
+			if binary.BigEndian.Uint32(magic[:]) == pcapngByteOrderMagic {
+				order = binary.BigEndian
+			} else if binary.LittleEndian.Uint32(magic[:]) == pcapngByteOrderMagic {
+				order = binary.LittleEndian
+			} else {
+				return nil, fmt.Errorf("capture error: %w: byte order magic", errInvalidPcapng)
+			}
+			header = append(header, magic[:]...)
+			linkTypes = nil
+		} else {
+			blockType = order.Uint32(header)
+		}
+		length := int(order.Uint32(header[4:]))
+		if length < len(header)+4 || length%4 != 0 {
+			return nil, fmt.Errorf("capture error: %w: block length %d", errInvalidPcapng, length)
+		}
+		block := make([]byte, length-len(header))
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, fmt.Errorf("capture error: %w", err)
+		}
+		header = header[:8]
+		body := block[:len(block)-4]
+
+		switch blockType {
+		case pcapngInterface:
+			if len(body) < 8 {
+				return nil, fmt.Errorf("capture error: %w: interface block", errInvalidPcapng)
+			}
+			linkTypes = append(linkTypes, order.Uint16(body))
+		case pcapngEnhancedPacket:
+			p, err := parseEnhancedPacket(order, linkTypes, body)
+			if err != nil {
+				return nil, fmt.Errorf("capture error: packet %d: %w", len(packets)+1, err)
+			}
+			packets = append(packets, p)
+		}
+	}
+}
+
+func parseEnhancedPacket(order binary.ByteOrder, linkTypes []uint16, body []byte) (Packet, error) {
+	if len(body) < 20 {
+		return Packet{}, errInvalidPcapng
+	}
+	iface := int(order.Uint32(body))
+	if iface >= len(linkTypes) {
+		return Packet{}, fmt.Errorf("%w: interface %d", errInvalidPcapng, iface)
+	}
+	if linkTypes[iface] != pcapngLinkTypeRaw {
+		return Packet{}, fmt.Errorf("unsupported link type %d", linkTypes[iface])
+	}
+	ts := uint64(order.Uint32(body[4:]))<<32 | uint64(order.Uint32(body[8:]))
+	captured := int(order.Uint32(body[12:]))
+	if len(body) < 20+captured {
+		return Packet{}, errInvalidPcapng
+	}
+	p := Packet{
+		Time:      time.Unix(0, int64(ts)*int64(time.Microsecond)),
+		Direction: Received,
+	}
+
+	options := body[20+captured+padding(captured):]
+	for len(options) >= 4 {
+		code, length := order.Uint16(options), int(order.Uint16(options[2:]))
+		if code == pcapngOptionEndOfOpt || len(options) < 4+length {
+			break
+		}
+		if code == pcapngOptionFlags && length == 4 && order.Uint32(options[4:])&pcapngFlagDirectionMask == pcapngFlagOutbound {
+			p.Direction = Sent
+		}
+		options = options[4+length+padding(length):]
+	}
+
+	src, dst, srcPort, data, err := parseUDPPacket(body[20 : 20+captured])
+	if err != nil {
+		return Packet{}, err
+	}
+	p.Data = data
+	if dst.IsMulticast() {
+		p.Group = dst.String()
+	}
+	switch {
+	case p.Direction == Sent && p.Group == "":
+		p.Peer = dst.String()
+	case p.Direction == Received && p.Group != "":
+		// UDPMulticastReceiver reports only IP of the sender
+		p.Peer = src.String()
+	case p.Direction == Received:
+		p.Peer = net.JoinHostPort(src.String(), strconv.Itoa(srcPort))
+	}
+	return p, nil
+}
+
+// parseUDPPacket returns addresses and payload of IPv4 or IPv6 packet of UDP datagram
+func parseUDPPacket(b []byte) (src, dst net.IP, srcPort int, data []byte, err error) {
+	if len(b) == 0 {
+		return nil, nil, 0, nil, errInvalidPcapng
+	}
+	var udp []byte
+	switch b[0] >> 4 {
+	case 4:
+		hl := int(b[0]&0x0f) * 4
+		if len(b) < hl || hl < 20 || b[9] != udpProtocol {
+			return nil, nil, 0, nil, fmt.Errorf("%w: not UDP over IPv4", errInvalidPcapng)
+		}
+		src, dst, udp = net.IP(b[12:16]), net.IP(b[16:20]), b[hl:]
+	case 6:
+		if len(b) < 40 || b[6] != udpProtocol {
+			return nil, nil, 0, nil, fmt.Errorf("%w: not UDP over IPv6", errInvalidPcapng)
+		}
+		src, dst, udp = net.IP(b[8:24]), net.IP(b[24:40]), b[40:]
+	default:
+		return nil, nil, 0, nil, fmt.Errorf("%w: IP version %d", errInvalidPcapng, b[0]>>4)
+	}
+	if len(udp) < 8 {
+		return nil, nil, 0, nil, fmt.Errorf("%w: UDP header", errInvalidPcapng)
+	}
+	length := int(binary.BigEndian.Uint16(udp[4:]))
+	if length < 8 || len(udp) < length {
+		return nil, nil, 0, nil, fmt.Errorf("%w: UDP length %d", errInvalidPcapng, length)
+	}
+	return src, dst, int(binary.BigEndian.Uint16(udp)), append([]byte{}, udp[8:length]...), nil
+}
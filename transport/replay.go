@@ -0,0 +1,299 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// Replay feeds received packets of a capture to a node, so that the node behaves as it did when captured.
+//
+// Each packet the node sends is matched to the first unmatched packet of the capture which has the same
+// destination and data except TID. A received packet is delivered after all packets sent before it in the
+// capture have been matched, and after the packets received before it. So the replay does not depend on
+// timing of the capture. TID of a response is rewritten to TID of the request the node sent, because
+// the node may have numbered its requests in another order. The replay stops if the node does not send
+// what it sent in the capture, which can be examined with Sent.
+type Replay struct {
+	mu        sync.Mutex
+	received  []replayPacket
+	requests  []replayRequest // packets sent in the capture
+	delivered int             // num of received packets delivered
+	sent      []Packet        // packets sent by the node during the replay
+	changed   chan struct{}   // closed when delivered or sent changes
+	done      chan struct{}
+}
+
+// replayPacket is a received packet, and num of packets sent before it
+type replayPacket struct {
+	Packet
+	index int // in received packets
+	sends int
+}
+
+// replayRequest is a packet sent in the capture, and TID of the packet matched to it in the replay
+type replayRequest struct {
+	Packet
+	matched bool
+	tid     [2]byte
+}
+
+// NewReplay returns Replay of packets
+func NewReplay(packets []Packet) *Replay {
+	r := &Replay{changed: make(chan struct{}), done: make(chan struct{})}
+	for _, p := range packets {
+		if p.Direction == Sent {
+			r.requests = append(r.requests, replayRequest{Packet: p})
+			continue
+		}
+		r.received = append(r.received, replayPacket{Packet: p, index: len(r.received), sends: len(r.requests)})
+	}
+	if len(r.received) == 0 {
+		close(r.done)
+	}
+	return r
+}
+
+// Done returns a channel which is closed when all received packets have been delivered
+func (r *Replay) Done() <-chan struct{} {
+	return r.done
+}
+
+// Sent returns packets sent by the node during the replay
+func (r *Replay) Sent() []Packet {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Packet{}, r.sent...)
+}
+
+// MulticastReceiver returns MulticastReceiver which receives multicast packets of the capture
+func (r *Replay) MulticastReceiver() MulticastReceiver {
+	return &replayMulticastReceiver{replay: r}
+}
+
+// UnicastReceiver returns UnicastReceiver which receives unicast packets of the capture
+func (r *Replay) UnicastReceiver() UnicastReceiver {
+	return &replayUnicastReceiver{replay: r}
+}
+
+// MulticastSender returns MulticastSender which records packets sent to group
+func (r *Replay) MulticastSender(group string) MulticastSender {
+	return &replayMulticastSender{replay: r, group: group}
+}
+
+// UnicastSender returns UnicastSender which records packets sent
+func (r *Replay) UnicastSender() UnicastSender {
+	return &replayUnicastSender{replay: r}
+}
+
+// notify wakes up receivers waiting for changes. mu must be held.
+func (r *Replay) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+func (r *Replay) send(p Packet) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p.Time = time.Now()
+	p.Data = append([]byte{}, p.Data...)
+	r.sent = append(r.sent, p)
+	for i := range r.requests {
+		q := &r.requests[i]
+		if !q.matched && q.Peer == p.Peer && q.Group == p.Group && sameExceptTID(q.Data, p.Data) {
+			q.matched = true
+			if tid, ok := frameTID(p.Data); ok {
+				copy(q.tid[:], tid)
+			}
+			break
+		}
+	}
+	r.notify()
+}
+
+// frameTID returns TID of Echonet-Lite frame in data
+func frameTID(data []byte) ([]byte, bool) {
+	if len(data) < 4 || data[0] != 0x10 {
+		return nil, false
+	}
+	return data[2:4], true
+}
+
+// sameExceptTID reports whether a and b are the same, except TID if they are Echonet-Lite frames
+func sameExceptTID(a, b []byte) bool {
+	_, ok := frameTID(a)
+	if !ok || len(a) != len(b) {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(a[:2], b[:2]) && bytes.Equal(a[4:], b[4:])
+}
+
+// response returns data of p, whose TID is rewritten if p responds to a request of the capture. mu must be held.
+func (r *Replay) response(p replayPacket) []byte {
+	data := append([]byte{}, p.Data...)
+	tid, ok := frameTID(data)
+	if !ok {
+		return data
+	}
+	host := p.Peer
+	if h, _, err := net.SplitHostPort(p.Peer); err == nil {
+		host = h
+	}
+	for i := p.sends - 1; i >= 0; i-- {
+		q := r.requests[i]
+		qtid, ok := frameTID(q.Data)
+		if !ok || !bytes.Equal(qtid, tid) || (q.Group == "" && q.Peer != host) {
+			continue
+		}
+		copy(data[2:], q.tid[:])
+		break
+	}
+	return data
+}
+
+// listen delivers received packets which match multicast to the channel until ctx is done, and closes it then
+func (r *Replay) listen(ctx context.Context, wg *sync.WaitGroup, multicast bool) <-chan ReceiveResult {
+	results := make(chan ReceiveResult, 5)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(results)
+		for _, p := range r.received {
+			if (p.Group != "") != multicast {
+				continue
+			}
+			if !r.wait(ctx, p) {
+				return
+			}
+			r.mu.Lock()
+			data := r.response(p)
+			r.mu.Unlock()
+			select {
+			case results <- ReceiveResult{Data: data, Address: p.Peer}:
+			case <-ctx.Done():
+				return
+			}
+			r.mu.Lock()
+			r.delivered++
+			if r.delivered == len(r.received) {
+				close(r.done)
+			}
+			r.notify()
+			r.mu.Unlock()
+		}
+		<-ctx.Done()
+	}()
+	return results
+}
+
+// matched reports whether the first n packets sent in the capture have been matched. mu must be held.
+func (r *Replay) matched(n int) bool {
+	for _, q := range r.requests[:n] {
+		if !q.matched {
+			return false
+		}
+	}
+	return true
+}
+
+// wait waits until p can be delivered. It returns false if ctx is done.
+func (r *Replay) wait(ctx context.Context, p replayPacket) bool {
+	for {
+		r.mu.Lock()
+		ready := r.delivered == p.index && r.matched(p.sends)
+		changed := r.changed
+		r.mu.Unlock()
+		if ready {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+type replayMulticastReceiver struct {
+	replay *Replay
+	wg     sync.WaitGroup
+}
+
+// Start starts to deliver multicast packets until ctx is done. The channel is closed then.
+func (r *replayMulticastReceiver) Start(ctx context.Context, ip, port string) <-chan ReceiveResult {
+	return r.replay.listen(ctx, &r.wg, true)
+}
+
+// Wait waits for receiving to stop. It never fails.
+func (r *replayMulticastReceiver) Wait() error {
+	r.wg.Wait()
+	return nil
+}
+
+type replayUnicastReceiver struct {
+	replay *Replay
+	wg     sync.WaitGroup
+}
+
+// Start starts to deliver unicast packets until ctx is done. The channel is closed then.
+func (r *replayUnicastReceiver) Start(ctx context.Context, port string) <-chan ReceiveResult {
+	return r.replay.listen(ctx, &r.wg, false)
+}
+
+// Wait waits for receiving to stop. It never fails.
+func (r *replayUnicastReceiver) Wait() error {
+	r.wg.Wait()
+	return nil
+}
+
+type replayMulticastSender struct {
+	replay *Replay
+	group  string
+	mu     sync.Mutex
+	closed bool
+}
+
+// Send records data
+func (s *replayMulticastSender) Send(data []byte) {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return
+	}
+	s.replay.send(Packet{Direction: Sent, Group: s.group, Data: data})
+}
+
+// Close closes the sender
+func (s *replayMulticastSender) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+}
+
+type replayUnicastSender struct {
+	replay *Replay
+	mu     sync.Mutex
+	closed bool
+}
+
+// Send records data
+func (s *replayUnicastSender) Send(ip string, data []byte) error {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+	s.replay.send(Packet{Direction: Sent, Peer: ip, Data: data})
+	return nil
+}
+
+// Close closes the sender
+func (s *replayUnicastSender) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+}
@@ -2,6 +2,7 @@ package transport
 
 import (
 	"bufio"
+	"io"
 	"log"
 	"time"
 
@@ -13,14 +14,15 @@ import (
 // Serial is the interface that communicates data through serial port
 type Serial interface {
 	Send([]byte) error     // sends data
-	Recv() ([]byte, error) // receives data and returns them
+	Recv() ([]byte, error) // receives a line and returns it with the line ending
 	Close()                // closes active serial connection
 }
 
 // SerialImpl is a concrete implementation of Serial interface
 type SerialImpl struct {
-	port   serial.Port
-	reader *bufio.Reader
+	port    io.ReadWriteCloser
+	reader  *bufio.Reader
+	partial []byte // line read until timeout
 }
 
 // NewSerialImpl opens default serial connection and returns SerialImpl
@@ -39,24 +41,36 @@ func NewSerialImpl(addr string) *SerialImpl {
 		log.Fatal("Faild to open serial:", err)
 	}
 
-	reader := bufio.NewReaderSize(port, 4096)
+	return NewSerialWithPort(port)
+}
 
+// NewSerialWithPort returns SerialImpl which communicates through port, such as an end of net.Pipe
+func NewSerialWithPort(port io.ReadWriteCloser) *SerialImpl {
+	reader := bufio.NewReaderSize(port, 4096)
 	return &SerialImpl{port: port, reader: reader}
 }
 
 // Send sends data
-func (s SerialImpl) Send(in []byte) error {
+func (s *SerialImpl) Send(in []byte) error {
 	_, err := s.port.Write(in)
 	return err
 }
 
-// Recv receives data
-func (s SerialImpl) Recv() ([]byte, error) {
-	line, _, err := s.reader.ReadLine()
-	return line, err
+// Recv receives a line. The line ending is kept because binary data may contain CR and LF.
+func (s *SerialImpl) Recv() ([]byte, error) {
+	line, err := s.reader.ReadBytes('\n')
+	if err != nil {
+		s.partial = append(s.partial, line...)
+		return nil, err
+	}
+	if len(s.partial) > 0 {
+		line = append(s.partial, line...)
+		s.partial = nil
+	}
+	return line, nil
 }
 
 // Close closes active connection
-func (s SerialImpl) Close() {
+func (s *SerialImpl) Close() {
 	s.port.Close()
 }
@@ -7,7 +7,8 @@ import (
 	"fmt"
 	"log"
 	"net"
-	"time"
+	"strings"
+	"sync"
 )
 
 // ReceiveResult is response data
@@ -20,6 +21,7 @@ type ReceiveResult struct {
 // MulticastReceiver is multicast receiver
 type MulticastReceiver interface {
 	Start(ctx context.Context, ip, port string) <-chan ReceiveResult
+	Wait() error // waits for receiving to stop after ctx is done, and returns the error which stopped it
 }
 
 // MulticastSender is multicast sender
@@ -31,64 +33,118 @@ type MulticastSender interface {
 // UnicastReceiver is unicast receiver
 type UnicastReceiver interface {
 	Start(ctx context.Context, port string) <-chan ReceiveResult
+	Wait() error // waits for receiving to stop after ctx is done, and returns the error which stopped it
+}
+
+// UnicastSender is unicast sender
+type UnicastSender interface {
+	Send(ip string, data []byte) error
+	Close()
 }
 
 // UDPMulticastReceiver is udp multicast receiver
 type UDPMulticastReceiver struct {
+	Interface *net.Interface // joins the group on this interface, chosen by the system if nil
+	receiver
 }
 
-// Start starts to receive
+// Start starts to receive until ctx is done. The channel is closed when receiving stops.
 func (r *UDPMulticastReceiver) Start(ctx context.Context, ip, port string) <-chan ReceiveResult {
-	results := make(chan ReceiveResult, 5)
 	log.Println("Start to listen multicast udp ", ip, port)
-
-	go func() {
-		defer close(results)
-		address, err := net.ResolveUDPAddr("udp", ip+port)
+	return r.run(ctx, func() (*net.UDPConn, error) {
+		address, err := udpAddrOn(r.Interface, ip, port)
 		log.Println("resolved:", address)
 		if err != nil {
-			results <- ReceiveResult{Err: fmt.Errorf("Error: [%s]", err)}
-			return
+			return nil, fmt.Errorf("multicast error: %w", err)
 		}
-		conn, err := net.ListenMulticastUDP("udp", nil, address)
+		conn, err := net.ListenMulticastUDP("udp", r.Interface, address)
 		if err != nil {
-			results <- ReceiveResult{Err: fmt.Errorf("Error: [%s]", err)}
-			return
+			return nil, fmt.Errorf("multicast error: %w", err)
 		}
-		defer conn.Close()
-		buffer := make([]byte, 1500)
-
-		for {
-			fmt.Printf(".")
-			conn.SetDeadline(time.Now().Add(1 * time.Second))
-			length, remoteAddress, err := conn.ReadFromUDP(buffer)
-			if err != nil {
-				err, ok := err.(net.Error)
-				if !ok || !err.Timeout() {
-					results <- ReceiveResult{Err: fmt.Errorf("Error: [%s]", err)}
-				}
-			} else if length > 0 {
-				fmt.Println()
-				// Need copy because buffer will be cleared and reuse
-				data := append([]byte{}, buffer[:length]...)
-				results <- ReceiveResult{Data: data, Address: remoteAddress.IP.String(), Err: nil}
-			}
-			select {
-			case <-ctx.Done():
-				log.Println("ctx.Done")
-				return
-			default:
-				//log.Println("recv: ", length)
-			}
+		return conn, nil
+	}, senderAddress)
+}
 
-			for i := range buffer {
-				buffer[i] = 0
-			}
+// receiver runs goroutines which receive packets from UDP connections, and waits for them
+type receiver struct {
+	wg  sync.WaitGroup
+	mu  sync.Mutex
+	err error
+}
+
+// run opens a connection with listen, and sends packets read from it to the channel until ctx is done.
+// The connection is closed when ctx is done, which unblocks the read.
+func (r *receiver) run(ctx context.Context, listen func() (*net.UDPConn, error), sender func(*net.UDPAddr) string) <-chan ReceiveResult {
+	results := make(chan ReceiveResult, 5)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer close(results)
+
+		err := receive(ctx, results, listen, sender)
+		if err == nil {
+			log.Println("ctx.Done")
+			return
+		}
+		r.mu.Lock()
+		if r.err == nil {
+			r.err = err
+		}
+		r.mu.Unlock()
+		select {
+		case results <- ReceiveResult{Err: err}:
+		case <-ctx.Done():
 		}
 	}()
 	return results
 }
 
+// Wait waits for all goroutines started by Start to exit, and returns the first error which stopped one of them
+func (r *receiver) Wait() error {
+	r.wg.Wait()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// receive reads packets until ctx is done, or returns error which stopped it
+func receive(ctx context.Context, results chan<- ReceiveResult, listen func() (*net.UDPConn, error), sender func(*net.UDPAddr) string) error {
+	conn, err := listen()
+	if err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		conn.Close()
+	}()
+
+	buffer := make([]byte, 1500)
+	for {
+		length, remoteAddress, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("read error: %w", err)
+		}
+		if length == 0 {
+			continue
+		}
+		// Need copy because buffer will be reused
+		data := append([]byte{}, buffer[:length]...)
+		select {
+		case results <- ReceiveResult{Data: data, Address: sender(remoteAddress)}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
 // UDPMulticastSender is udp multicast sender
 type UDPMulticastSender struct {
 	conn net.Conn
@@ -96,7 +152,29 @@ type UDPMulticastSender struct {
 
 // NewUDPMulticastSender creates DPMulticastSender instance
 func NewUDPMulticastSender(IP, Port string) (*UDPMulticastSender, error) {
-	conn, err := net.Dial("udp", IP+Port)
+	return NewUDPMulticastSenderOn(nil, IP, Port)
+}
+
+// NewUDPMulticastSenderOn creates UDPMulticastSender instance which sends through ifi.
+// The interface is chosen by the system if ifi is nil.
+func NewUDPMulticastSenderOn(ifi *net.Interface, IP, Port string) (*UDPMulticastSender, error) {
+	raddr, err := udpAddrOn(ifi, IP, Port)
+	if err != nil {
+		return nil, fmt.Errorf("resolve error: %w", err)
+	}
+	var laddr *net.UDPAddr
+	if ifi != nil && raddr.IP.To4() != nil {
+		// IPv4 multicast is sent through the interface which has the source address
+		ip, err := interfaceIP(ifi, sameFamily(raddr.IP))
+		if err != nil {
+			return nil, err
+		}
+		if ip == nil {
+			return nil, fmt.Errorf("no IPv4 address on %s", ifi.Name)
+		}
+		laddr = &net.UDPAddr{IP: ip}
+	}
+	conn, err := net.DialUDP("udp", laddr, raddr)
 	if err != nil {
 		return nil, fmt.Errorf("Write conn error: [%s]", err)
 	}
@@ -119,57 +197,103 @@ func (ums *UDPMulticastSender) Send(data []byte) {
 
 // UDPUnicastReceiver is udp unicast receiver
 type UDPUnicastReceiver struct {
+	Interface *net.Interface // receives only at an address of this interface, at all addresses if nil
+	IPv6      bool           // receives at IPv6 link-local address of Interface instead of IPv4 address
+	receiver
 }
 
-// Start starts to receive
+// Start starts to receive until ctx is done. The channel is closed when receiving stops.
 func (r *UDPUnicastReceiver) Start(ctx context.Context, port string) <-chan ReceiveResult {
-	results := make(chan ReceiveResult, 5)
 	log.Println("Start to listen unicast udp ", port)
-
-	go func() {
-		address, err := net.ResolveUDPAddr("udp", port)
+	return r.run(ctx, func() (*net.UDPConn, error) {
+		address, err := r.localAddr(port)
 		log.Println("resolved:", address)
 		if err != nil {
-			results <- ReceiveResult{Err: fmt.Errorf("Error: [%s]", err)}
-			return
+			return nil, fmt.Errorf("unicast error: %w", err)
 		}
 		conn, err := net.ListenUDP("udp", address)
 		if err != nil {
-			results <- ReceiveResult{Err: fmt.Errorf("Unicast Error: [%s]", err)}
-			return
+			return nil, fmt.Errorf("unicast error: %w", err)
 		}
-		defer conn.Close()
-
 		err = resolveSocketOption(conn)
 		if err != nil {
-			results <- ReceiveResult{Err: fmt.Errorf("unicast error: %w", err)}
-			return
+			conn.Close()
+			return nil, fmt.Errorf("unicast error: %w", err)
 		}
+		return conn, nil
+	}, func(addr *net.UDPAddr) string {
+		return addr.String()
+	})
+}
 
-		buffer := make([]byte, 1024)
-		for {
-			length, remoteAddress, err := conn.ReadFromUDP(buffer)
-			if err != nil {
-				log.Println("Unicast Error:", err)
-			} else if length > 0 {
-				fmt.Println()
-				// Need copy because buffer will be cleared and reuse
-				data := append([]byte{}, buffer[:length]...)
-				results <- ReceiveResult{Data: data, Address: remoteAddress.String(), Err: nil}
-			}
+// localAddr returns address to receive at port
+func (r *UDPUnicastReceiver) localAddr(port string) (*net.UDPAddr, error) {
+	if r.Interface == nil {
+		return net.ResolveUDPAddr("udp", port)
+	}
+	return unicastAddrOn(r.Interface, r.IPv6, port)
+}
 
-			select {
-			case <-ctx.Done():
-				log.Println("ctx.Done")
-				return
-			default:
-			}
+// unicastAddrOn returns the IPv4 address, or IPv6 link-local address if ipv6, of ifi with port
+func unicastAddrOn(ifi *net.Interface, ipv6 bool, port string) (*net.UDPAddr, error) {
+	match := func(ip net.IP) bool { return ip.To4() != nil }
+	if ipv6 {
+		match = func(ip net.IP) bool { return ip.To4() == nil && ip.IsLinkLocalUnicast() }
+	}
+	ip, err := interfaceIP(ifi, match)
+	if err != nil {
+		return nil, err
+	}
+	if ip == nil {
+		return nil, fmt.Errorf("no unicast address on %s", ifi.Name)
+	}
+	return udpAddrOn(ifi, ip.String(), port)
+}
 
-			for i := range buffer {
-				buffer[i] = 0
-			}
+// UDPUnicastSender is udp unicast sender
+type UDPUnicastSender struct {
+	conn *net.UDPConn
+	ifi  *net.Interface
+	port string
+}
 
+// NewUDPUnicastSender creates UDPUnicastSender instance which sends to port of each destination
+func NewUDPUnicastSender(port string) (*UDPUnicastSender, error) {
+	return NewUDPUnicastSenderOn(nil, false, port)
+}
+
+// NewUDPUnicastSenderOn creates UDPUnicastSender instance which sends from the IPv4 address,
+// or IPv6 link-local address if ipv6, of ifi. The address is chosen by the system if ifi is nil.
+func NewUDPUnicastSenderOn(ifi *net.Interface, ipv6 bool, port string) (*UDPUnicastSender, error) {
+	var laddr *net.UDPAddr
+	if ifi != nil {
+		var err error
+		laddr, err = unicastAddrOn(ifi, ipv6, "0")
+		if err != nil {
+			return nil, err
 		}
-	}()
-	return results
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, fmt.Errorf("Write conn error: [%s]", err)
+	}
+	return &UDPUnicastSender{conn: conn, ifi: ifi, port: strings.TrimPrefix(port, ":")}, nil
+}
+
+// Close closes connection
+func (us *UDPUnicastSender) Close() {
+	us.conn.Close()
+}
+
+// Send sends data to ip
+func (us *UDPUnicastSender) Send(ip string, data []byte) error {
+	address, err := udpAddrOn(us.ifi, ip, us.port)
+	if err != nil {
+		return fmt.Errorf("resolve error: %w", err)
+	}
+	_, err = us.conn.WriteToUDP(data, address)
+	if err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+	return nil
 }
@@ -47,6 +47,20 @@ func (mr *MockMulticastReceiverMockRecorder) Start(ctx, ip, port interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockMulticastReceiver)(nil).Start), ctx, ip, port)
 }
 
+// Wait mocks base method
+func (m *MockMulticastReceiver) Wait() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Wait")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Wait indicates an expected call of Wait
+func (mr *MockMulticastReceiverMockRecorder) Wait() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Wait", reflect.TypeOf((*MockMulticastReceiver)(nil).Wait))
+}
+
 // MockMulticastSender is a mock of MulticastSender interface
 type MockMulticastSender struct {
 	ctrl     *gomock.Controller
@@ -130,3 +144,66 @@ func (mr *MockUnicastReceiverMockRecorder) Start(ctx, port interface{}) *gomock.
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockUnicastReceiver)(nil).Start), ctx, port)
 }
+
+// Wait mocks base method
+func (m *MockUnicastReceiver) Wait() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Wait")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Wait indicates an expected call of Wait
+func (mr *MockUnicastReceiverMockRecorder) Wait() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Wait", reflect.TypeOf((*MockUnicastReceiver)(nil).Wait))
+}
+
+// MockUnicastSender is a mock of UnicastSender interface
+type MockUnicastSender struct {
+	ctrl     *gomock.Controller
+	recorder *MockUnicastSenderMockRecorder
+}
+
+// MockUnicastSenderMockRecorder is the mock recorder for MockUnicastSender
+type MockUnicastSenderMockRecorder struct {
+	mock *MockUnicastSender
+}
+
+// NewMockUnicastSender creates a new mock instance
+func NewMockUnicastSender(ctrl *gomock.Controller) *MockUnicastSender {
+	mock := &MockUnicastSender{ctrl: ctrl}
+	mock.recorder = &MockUnicastSenderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockUnicastSender) EXPECT() *MockUnicastSenderMockRecorder {
+	return m.recorder
+}
+
+// Send mocks base method
+func (m *MockUnicastSender) Send(ip string, data []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", ip, data)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send
+func (mr *MockUnicastSenderMockRecorder) Send(ip, data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockUnicastSender)(nil).Send), ip, data)
+}
+
+// Close mocks base method
+func (m *MockUnicastSender) Close() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Close")
+}
+
+// Close indicates an expected call of Close
+func (mr *MockUnicastSenderMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockUnicastSender)(nil).Close))
+}
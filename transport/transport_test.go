@@ -0,0 +1,125 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUDPUnicastReceiver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// find a free port
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP failed: %s", err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	r := &UDPUnicastReceiver{}
+	results := r.Start(ctx, net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+
+	s, err := NewUDPUnicastSender(strconv.Itoa(port))
+	if err != nil {
+		t.Fatalf("NewUDPUnicastSender failed: %s", err)
+	}
+	defer s.Close()
+
+	// the receiver may not listen yet
+	var got ReceiveResult
+	for received := false; !received; {
+		err = s.Send("127.0.0.1", []byte{0x10, 0x81})
+		if err != nil {
+			t.Fatalf("Send failed: %s", err)
+		}
+		select {
+		case got = <-results:
+			received = true
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	if string(got.Data) != "\x10\x81" || got.Err != nil {
+		t.Errorf("Diffrent result: %#v", got)
+	}
+
+	cancel()
+	timeout := time.After(5 * time.Second)
+	for closed := false; !closed; {
+		select {
+		case _, ok := <-results:
+			// packets sent again may be left
+			closed = !ok
+		case <-timeout:
+			t.Fatalf("receiver did not stop")
+		}
+	}
+	if err := r.Wait(); err != nil {
+		t.Errorf("Wait failed: %s", err)
+	}
+}
+
+func TestUDPUnicastReceiver_ListenError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r := &UDPUnicastReceiver{}
+	results := r.Start(ctx, "invalid:port")
+
+	got, ok := <-results
+	if !ok || got.Err == nil {
+		t.Errorf("error not received: %#v", got)
+	}
+	if _, ok := <-results; ok {
+		t.Errorf("channel not closed")
+	}
+	if err := r.Wait(); err == nil {
+		t.Errorf("Wait succeeded")
+	}
+}
+
+func TestNewUDPUnicastSenderOn(t *testing.T) {
+	lo := loopback(t)
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP failed: %s", err)
+	}
+	defer conn.Close()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	s, err := NewUDPUnicastSenderOn(lo, false, strconv.Itoa(port))
+	if err != nil {
+		t.Fatalf("NewUDPUnicastSenderOn failed: %s", err)
+	}
+	defer s.Close()
+
+	// bound to the address of the interface, not to the wildcard address
+	if got := s.conn.LocalAddr().(*net.UDPAddr).IP; !got.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Diffrent local address: want:127.0.0.1, got:%s", got)
+	}
+
+	err = s.Send("127.0.0.1", []byte{0x10, 0x81})
+	if err != nil {
+		t.Fatalf("Send failed: %s", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 16)
+	n, from, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("ReadFromUDP failed: %s", err)
+	}
+	if string(buf[:n]) != "\x10\x81" || !from.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Diffrent result: %x from %s", buf[:n], from)
+	}
+
+	// loopback has no IPv6 link-local address
+	_, err = NewUDPUnicastSenderOn(lo, true, strconv.Itoa(port))
+	if want := "no unicast address on " + lo.Name; err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("Diffrent error: want:%s, got:%v", want, err)
+	}
+}
@@ -9,22 +9,22 @@ import (
 )
 
 func resolveSocketOption(conn *net.UDPConn) error {
-	f, err := conn.File()
+	// conn.File() would put the socket in blocking mode, where Close can't interrupt a read
+	raw, err := conn.SyscallConn()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	fd := f.Fd()
-	err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1)
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1)
+		if serr != nil {
+			return
+		}
+		serr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
 	if err != nil {
 		return err
 	}
-
-	err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return serr
 }
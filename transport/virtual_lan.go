@@ -0,0 +1,254 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// virtualBufferSize is num of packets buffered in each receiver of VirtualLAN.
+// Packets are dropped when it is full, like a receive buffer of a socket.
+const virtualBufferSize = 64
+
+// firstEphemeralPort is the first source port of senders on VirtualLAN
+const firstEphemeralPort = 49152
+
+// ErrClosed is returned when data is sent with a closed sender
+var ErrClosed = errors.New("sender closed")
+
+// VirtualLAN is an in-memory network which connects nodes in a process without sockets.
+// Packets are delivered after Latency, and may be dropped or duplicated at random.
+// Set the fields before sending packets.
+type VirtualLAN struct {
+	Latency       time.Duration
+	LossRate      float64 // probability that a packet is dropped
+	DuplicateRate float64 // probability that a packet is delivered twice
+
+	mu        sync.Mutex
+	rand      *rand.Rand
+	receivers map[*virtualReceiver]struct{}
+	nextPort  int
+}
+
+// virtualReceiver receives packets to port of host, or of multicast group if group is not empty
+type virtualReceiver struct {
+	host    string
+	group   string
+	port    string
+	results chan ReceiveResult
+}
+
+// NewVirtualLAN returns VirtualLAN whose loss and duplication are reproducible with seed
+func NewVirtualLAN(seed int64) *VirtualLAN {
+	return &VirtualLAN{
+		rand:      rand.New(rand.NewSource(seed)),
+		receivers: map[*virtualReceiver]struct{}{},
+		nextPort:  firstEphemeralPort,
+	}
+}
+
+// Host returns a host at ip on the LAN
+func (l *VirtualLAN) Host(ip string) *VirtualHost {
+	return &VirtualHost{lan: l, ip: ip}
+}
+
+// listen registers a receiver until ctx is done, and closes its channel then
+func (l *VirtualLAN) listen(ctx context.Context, wg *sync.WaitGroup, host, group, port string) <-chan ReceiveResult {
+	r := &virtualReceiver{host: host, group: group, port: trimPort(port), results: make(chan ReceiveResult, virtualBufferSize)}
+	l.mu.Lock()
+	l.receivers[r] = struct{}{}
+	l.mu.Unlock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		l.mu.Lock()
+		delete(l.receivers, r)
+		close(r.results)
+		l.mu.Unlock()
+	}()
+	return r.results
+}
+
+// ephemeralPort returns a source port for a new sender
+func (l *VirtualLAN) ephemeralPort() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	port := l.nextPort
+	l.nextPort++
+	return strconv.Itoa(port)
+}
+
+// copies returns how many times a packet is delivered, 0 if it is lost
+func (l *VirtualLAN) copies() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rand.Float64() < l.LossRate {
+		return 0
+	}
+	if l.rand.Float64() < l.DuplicateRate {
+		return 2
+	}
+	return 1
+}
+
+// send delivers data from src to receivers which match after latency
+func (l *VirtualLAN) send(src, srcPort string, match func(r *virtualReceiver) bool, data []byte) {
+	for i := l.copies(); i > 0; i-- {
+		data := append([]byte{}, data...)
+		time.AfterFunc(l.Latency, func() {
+			l.deliver(src, srcPort, match, data)
+		})
+	}
+}
+
+func (l *VirtualLAN) deliver(src, srcPort string, match func(r *virtualReceiver) bool, data []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for r := range l.receivers {
+		if !match(r) {
+			continue
+		}
+		result := ReceiveResult{Data: append([]byte{}, data...), Address: net.JoinHostPort(src, srcPort)}
+		if r.group != "" {
+			// UDPMulticastReceiver reports only IP of the sender
+			result.Address = src
+		}
+		select {
+		case r.results <- result:
+		default:
+			// receive buffer is full
+		}
+	}
+}
+
+func trimPort(port string) string {
+	return strings.TrimPrefix(port, ":")
+}
+
+// VirtualHost is a host on VirtualLAN, which makes receivers and senders of the host
+type VirtualHost struct {
+	lan *VirtualLAN
+	ip  string
+}
+
+// IP returns address of the host
+func (h *VirtualHost) IP() string {
+	return h.ip
+}
+
+// MulticastReceiver returns MulticastReceiver of the host
+func (h *VirtualHost) MulticastReceiver() MulticastReceiver {
+	return &virtualMulticastReceiver{host: h}
+}
+
+// UnicastReceiver returns UnicastReceiver of the host
+func (h *VirtualHost) UnicastReceiver() UnicastReceiver {
+	return &virtualUnicastReceiver{host: h}
+}
+
+// MulticastSender returns MulticastSender which sends to port of multicast group ip, e.g. ":3610"
+func (h *VirtualHost) MulticastSender(ip, port string) MulticastSender {
+	return &virtualMulticastSender{host: h, group: ip, port: trimPort(port), srcPort: h.lan.ephemeralPort()}
+}
+
+// UnicastSender returns UnicastSender which sends to port of each destination, e.g. ":3610"
+func (h *VirtualHost) UnicastSender(port string) UnicastSender {
+	return &virtualUnicastSender{host: h, port: trimPort(port), srcPort: h.lan.ephemeralPort()}
+}
+
+type virtualMulticastReceiver struct {
+	host *VirtualHost
+	wg   sync.WaitGroup
+}
+
+// Start starts to receive until ctx is done. The channel is closed then.
+func (r *virtualMulticastReceiver) Start(ctx context.Context, ip, port string) <-chan ReceiveResult {
+	return r.host.lan.listen(ctx, &r.wg, r.host.ip, ip, port)
+}
+
+// Wait waits for receiving to stop. It never fails.
+func (r *virtualMulticastReceiver) Wait() error {
+	r.wg.Wait()
+	return nil
+}
+
+type virtualUnicastReceiver struct {
+	host *VirtualHost
+	wg   sync.WaitGroup
+}
+
+// Start starts to receive until ctx is done. The channel is closed then.
+func (r *virtualUnicastReceiver) Start(ctx context.Context, port string) <-chan ReceiveResult {
+	return r.host.lan.listen(ctx, &r.wg, r.host.ip, "", port)
+}
+
+// Wait waits for receiving to stop. It never fails.
+func (r *virtualUnicastReceiver) Wait() error {
+	r.wg.Wait()
+	return nil
+}
+
+type virtualMulticastSender struct {
+	host    *VirtualHost
+	group   string
+	port    string
+	srcPort string
+	mu      sync.Mutex
+	closed  bool
+}
+
+// Send sends data to all hosts joined to the group, including the sender itself
+func (s *virtualMulticastSender) Send(data []byte) {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return
+	}
+	s.host.lan.send(s.host.ip, s.srcPort, func(r *virtualReceiver) bool {
+		return r.group == s.group && r.port == s.port
+	}, data)
+}
+
+// Close closes the sender
+func (s *virtualMulticastSender) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+}
+
+type virtualUnicastSender struct {
+	host    *VirtualHost
+	port    string
+	srcPort string
+	mu      sync.Mutex
+	closed  bool
+}
+
+// Send sends data to ip. It succeeds even if no host is at ip, as UDP does.
+func (s *virtualUnicastSender) Send(ip string, data []byte) error {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+	s.host.lan.send(s.host.ip, s.srcPort, func(r *virtualReceiver) bool {
+		return r.group == "" && r.host == ip && r.port == s.port
+	}, data)
+	return nil
+}
+
+// Close closes the sender
+func (s *virtualUnicastSender) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+}
@@ -5,8 +5,8 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
@@ -15,37 +15,86 @@ import (
 
 const (
 	commandTimeout = 60 * time.Second
+	// listenBufferSize is number of unsolicited frames kept until the listener receives them
+	listenBufferSize = 16
+	// eventBufferSize is number of events kept until the command receives them
+	eventBufferSize = 32
+	// readErrorInterval is interval to retry reading after failure while no command is in progress
+	readErrorInterval = 100 * time.Millisecond
 )
 
 // BP35C2Client is client for ROHM BP35C2
+// A reader goroutine parses lines from the serial port into events while a command or listener needs them.
+// Commands are serialized, and each of them waits for the events it expects.
+// After Connect succeeds, the PANA session is tracked and reconnected automatically when it is lost.
 type BP35C2Client struct {
-	sendSeq int
-	readSeq int
-	serial  transport.Serial
-	panDesc PanDesc
-	joined  bool
+	sendSeq    int
+	readSeq    int
+	serial     transport.Serial
+	panDesc    PanDesc // guarded by rmu
+	joined     bool    // guarded by rmu
+	stateFile  string  // file to store PanDesc, optional
+	dialect    Dialect // guarded by rmu
+	dataFormat DataFormat
+	reception  Reception     // guarded by rmu
+	link       LinkQuality   // guarded by rmu, as of the last UpdateLinkQuality
+	linkPolled bool          // guarded by rmu, true after UpdateLinkQuality
+	sample     linkSample    // guarded by rmu, since the last UpdateLinkQuality
+	noLQI      bool          // guarded by rmu, true if the module rejected SKLQI
+	timeout    time.Duration // timeout of a command, commandTimeout if zero
+
+	mu sync.Mutex // serializes commands
+
+	rmu     sync.Mutex  // guards state shared with the reader goroutine and reconnection
+	users   int         // number of commands and listeners which need the reader
+	reading bool        // reader goroutine is running
+	closed  bool        // serial port is closed
+	waiter  chan event  // events for the command in progress, nil without command
+	frames  chan []byte // unsolicited frames, nil without listener
+	parser  parser      // used only by the reader goroutine
+
+	session // guarded by rmu
 }
 
 // PanDesc is...
 type PanDesc struct {
-	Addr     string
-	IPV6Addr string
-	Channel  string
-	PanID    string
+	Addr     string `json:"addr"`
+	IPV6Addr string `json:"ipv6_addr"`
+	Channel  string `json:"channel"`
+	PanID    string `json:"pan_id"`
 }
 
 // NewBP35C2Client returns BP35C2Client instance
 func NewBP35C2Client(portaddr string) *BP35C2Client {
 	fmt.Println("NewBP35C2Client: ", portaddr)
 	s := transport.NewSerialImpl(portaddr)
-	return &BP35C2Client{serial: s}
+	return NewBP35C2ClientWithSerial(s)
+}
+
+// NewBP35C2ClientWithSerial returns BP35C2Client instance which communicates through s
+func NewBP35C2ClientWithSerial(s transport.Serial) *BP35C2Client {
+	return &BP35C2Client{serial: s, dialect: DialectBP35C2}
+}
+
+func (c *BP35C2Client) commandTimeout() time.Duration {
+	if c.timeout == 0 {
+		return commandTimeout
+	}
+	return c.timeout
 }
 
 // Close closees connection
 func (c *BP35C2Client) Close() {
-	if c.joined {
+	if c.isJoined() {
 		c.Term()
 	}
+	c.rmu.Lock()
+	c.closed = true
+	if c.done != nil {
+		close(c.done)
+		c.done = nil
+	}
+	c.rmu.Unlock()
 	c.serial.Close()
 }
 
@@ -79,348 +128,475 @@ func stringWithBinary(data []byte) string {
 	return b.String()
 }
 
-// Send sends serial command
-func (c *BP35C2Client) send(in []byte) error {
-	c.sendSeq++
-	log.Printf("Send[%d]:%s", c.sendSeq, stringWithBinary(in))
-	err := c.serial.Send(in)
-	if err != nil {
-		return err
-	}
-	// Echoback
-	_, err = c.recv()
-	if err != nil {
-		return err
+// acquire starts the reader goroutine unless it is running
+func (c *BP35C2Client) acquire() {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	c.users++
+	if !c.reading {
+		c.reading = true
+		go c.read()
 	}
-	return nil
 }
 
-// recv receives serial response by line
-func (c *BP35C2Client) recv() ([]byte, error) {
-	line, err := c.serial.Recv()
-	c.readSeq++
-	if err != nil {
-		return []byte{}, err
+// release lets the reader goroutine stop if nobody needs it
+func (c *BP35C2Client) release() {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	c.users--
+}
+
+// read reads lines and dispatches events parsed from them until nobody needs them
+func (c *BP35C2Client) read() {
+	for {
+		line, err := c.serial.Recv()
+		c.readSeq++
+		if err != nil {
+			if !isTimeout(err) && !c.dispatch(errorEvent{err: err}) {
+				time.Sleep(readErrorInterval)
+			}
+		} else {
+			log.Printf("Read[%d]:%s", c.readSeq, stringWithBinary(line))
+			c.parser.dialect = c.Dialect()
+			for _, ev := range c.parser.parse(line) {
+				c.trackSession(ev)
+				c.trackReception(ev)
+				c.dispatch(ev)
+			}
+		}
+
+		c.rmu.Lock()
+		if c.users == 0 || c.closed {
+			c.reading = false
+			c.rmu.Unlock()
+			return
+		}
+		c.rmu.Unlock()
 	}
+}
+
+// dispatch passes ev to the command in progress, and returns false if there is no command
+func (c *BP35C2Client) dispatch(ev event) bool {
+	c.rmu.Lock()
+	w := c.waiter
+	c.rmu.Unlock()
+	if w == nil {
+		c.handleUnsolicited(ev)
+		return false
+	}
+	select {
+	case w <- ev:
+	default:
+		log.Printf("event dropped: %#v", ev)
+	}
+	return true
+}
 
-	log.Printf("Read[%d]:%s", c.readSeq, stringWithBinary(line))
-	line = bytes.TrimSuffix(line, []byte{'\r', '\n'})
-	return line, err
+func (c *BP35C2Client) handleUnsolicited(ev event) {
+	switch e := ev.(type) {
+	case rxUDPEvent:
+		if e.lport == echonetLitePort {
+			c.deliver(e.data)
+		}
+	case notifyEvent:
+		log.Printf("EVENT %02X from %s", e.num, e.sender)
+	case errorEvent:
+		log.Println("[Error]", e.err)
+	}
 }
 
-func (c *BP35C2Client) recvOK() error {
-	r, err := c.recv()
+// command sends cmd and passes events to handle until it returns true or error.
+// FAIL and failure of reading are returned as error.
+func (c *BP35C2Client) command(ctx context.Context, cmd []byte, handle func(ev event) (bool, error)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := make(chan event, eventBufferSize)
+	c.rmu.Lock()
+	c.waiter = w
+	c.rmu.Unlock()
+	c.acquire()
+	defer func() {
+		c.rmu.Lock()
+		c.waiter = nil
+		c.rmu.Unlock()
+		c.release()
+	}()
+
+	c.sendSeq++
+	log.Printf("Send[%d]:%s", c.sendSeq, stringWithBinary(cmd))
+	err := c.serial.Send(cmd)
 	if err != nil {
 		return err
 	}
-	if !bytes.Equal(r, []byte("OK")) {
-		return fmt.Errorf("command failed [%s]", r)
+
+	for {
+		select {
+		case ev := <-w:
+			switch e := ev.(type) {
+			case errorEvent:
+				return e.err
+			case failEvent:
+				return fmt.Errorf("command failed [%s]", e.line)
+			case echoEvent:
+				continue
+			}
+			done, err := handle(ev)
+			if err != nil || done {
+				return err
+			}
+		case <-ctx.Done():
+			name := strings.SplitN(string(cmd), " ", 2)[0]
+			return fmt.Errorf("%s timeout: %w", strings.TrimSpace(name), ctx.Err())
+		}
 	}
-	return nil
+}
+
+// commandOK sends cmd and waits for OK
+func (c *BP35C2Client) commandOK(cmd string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.commandTimeout())
+	defer cancel()
+
+	return c.command(ctx, []byte(cmd), func(ev event) (bool, error) {
+		_, ok := ev.(okEvent)
+		return ok, nil
+	})
 }
 
 // Version is ..
-func (c BP35C2Client) Version() (string, error) {
-	err := c.send([]byte("SKVER\r\n"))
-	if err != nil {
-		return "", err
-	}
+func (c *BP35C2Client) Version() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.commandTimeout())
+	defer cancel()
 
 	//EVER X.Y.Z
-	r, err := c.recv()
-	if err != nil {
-		return "", err
-	}
-
-	if !bytes.HasPrefix(r, []byte("EVER")) {
-		return "", fmt.Errorf("unexpected response [%s]", r)
-	}
+	ver := ""
+	err := c.command(ctx, []byte("SKVER\r\n"), func(ev event) (bool, error) {
+		switch e := ev.(type) {
+		case verEvent:
+			if e.version == "" {
+				return false, fmt.Errorf("version string not found")
+			}
+			ver = e.version
+		case lineEvent:
+			return false, fmt.Errorf("unexpected response [%s]", e.line)
+		case okEvent:
+			return true, nil
+		}
+		return false, nil
+	})
+	return ver, err
+}
 
-	tokens := bytes.Split(r, []byte{' '})
-	if len(tokens) < 2 {
-		return "", fmt.Errorf("version string not found")
-	}
-	ver := string(tokens[1])
+// Info returns fields of EINFO
+func (c *BP35C2Client) Info() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.commandTimeout())
+	defer cancel()
 
-	err = c.recvOK()
-	if err != nil {
-		return ver, err
-	}
-	return ver, nil
+	var fields []string
+	err := c.command(ctx, []byte("SKINFO\r\n"), func(ev event) (bool, error) {
+		switch e := ev.(type) {
+		case infoEvent:
+			fields = e.fields
+		case okEvent:
+			if fields == nil {
+				return false, fmt.Errorf("EINFO not found")
+			}
+			return true, nil
+		}
+		return false, nil
+	})
+	return fields, err
 }
 
 // SetBRoutePassword is..
-func (c BP35C2Client) SetBRoutePassword(password string) error {
+func (c *BP35C2Client) SetBRoutePassword(password string) error {
 	if len(password) == 0 {
 		return fmt.Errorf("b-route password is empty")
 	}
-
-	err := c.send([]byte("SKSETPWD C " + password + "\r\n"))
-	if err != nil {
-		return err
-	}
-
-	return c.recvOK()
+	return c.commandOK("SKSETPWD C " + password + "\r\n")
 }
 
 // SetBRouteID  is ..
-func (c BP35C2Client) SetBRouteID(id string) error {
+func (c *BP35C2Client) SetBRouteID(id string) error {
 	if len(id) == 0 {
 		return fmt.Errorf("b-route ID is empty")
 	}
-
-	err := c.send([]byte("SKSETRBID " + id + "\r\n"))
-	if err != nil {
-		return err
-	}
-
-	return c.recvOK()
+	return c.commandOK("SKSETRBID " + id + "\r\n")
 }
 
-func (c BP35C2Client) scan(ctx context.Context, duration int) (bool, error) {
-
-	err := c.send([]byte(fmt.Sprintf("SKSCAN 2 FFFFFFFF %d 0 \r\n", duration)))
-	if err != nil {
-		return false, err
-	}
-
-	err = c.recvOK()
-	if err != nil {
-		return false, err
-	}
-
-	for {
-		ch := make(chan error)
-		var data []byte
-		go func(data *[]byte) {
-			res, err := c.recv()
-			if err != nil {
-				log.Println(err)
-				ch <- err
-			}
-
-			if bytes.HasPrefix(res, []byte("EVENT 22")) {
-				log.Println("found EVENT 22")
-				ch <- nil
-			}
-			if bytes.HasPrefix(res, []byte("EVENT 20")) {
+// scan scans PAN for duration and returns the first one found
+func (c *BP35C2Client) scan(ctx context.Context, duration int) (PanDesc, bool, error) {
+	cmd := fmt.Sprintf("SKSCAN 2 FFFFFFFF %d %s\r\n", duration, c.Dialect().side())
+
+	desc := PanDesc{}
+	found := false
+	err := c.command(ctx, []byte(cmd), func(ev event) (bool, error) {
+		switch e := ev.(type) {
+		case notifyEvent:
+			switch e.num {
+			case 0x20:
 				log.Println("found EVENT 20")
-				*data = res
-				ch <- nil
-			}
-		}(&data)
-
-		select {
-		case err := <-ch:
-			if err == nil {
-				return len(data) != 0, nil
+			case 0x22:
+				log.Println("found EVENT 22")
+				return true, nil
 			}
-		case <-ctx.Done():
-			return false, fmt.Errorf("scan timeout: %w", ctx.Err())
+		case panDescEvent:
+			desc = e.desc
+			found = true
+			c.setLQI(e)
+			return true, nil
 		}
+		return false, nil
+	})
+	if err != nil {
+		return PanDesc{}, false, err
 	}
-}
-
-func (c BP35C2Client) receivePanDesc() (PanDesc, error) {
-	ed := PanDesc{}
-	line, err := c.recv()
-	if err == nil && bytes.HasPrefix(line, []byte("EPANDESC")) {
-		line, err := c.recv() // Channel
-		if err != nil {
-			return PanDesc{}, fmt.Errorf("failed to get Channel [%s]", err)
-		}
-		tokens := bytes.Split(line, []byte("Channel:"))
-		ed.Channel = string(bytes.Trim(tokens[1], "\r\n"))
-		c.recv()             // Channel Page: XX
-		line, err = c.recv() // Pan ID: XXXX
-		if err != nil {
-			return PanDesc{}, fmt.Errorf("failed to get Pan ID [%s]", err)
-		}
-		tokens = bytes.Split(line, []byte("Pan ID:"))
-		ed.PanID = string(bytes.Trim(tokens[1], "\r\n"))
-		line, err = c.recv() // Addr:XXXXXXXXXXXXXXXX
-		if err != nil {
-			return PanDesc{}, fmt.Errorf("failed to get Addr [%s]", err)
-		}
-		tokens = bytes.Split(line, []byte("Addr:"))
-		ed.Addr = string(bytes.Trim(tokens[1], "\r\n"))
-		c.recv() // LQI:CA
-		c.recv() // Side:X
-		c.recv() // PairID:XXXXXXXX
-	}
-	return ed, err
+	return desc, found, nil
 }
 
 // Scan is ..
-func (c BP35C2Client) Scan(ctx context.Context) (PanDesc, error) {
-	duration := 4
-	for {
-		if duration > 8 {
-			log.Println("duration limit(8) exceeds")
-			break
-		}
-
-		found, err := c.scan(ctx, duration)
+func (c *BP35C2Client) Scan(ctx context.Context) (PanDesc, error) {
+	for duration := 4; duration <= 8; duration++ {
+		ed, found, err := c.scan(ctx, duration)
 		if err != nil {
 			return PanDesc{}, fmt.Errorf("scan failed: %w", err)
 		}
 		if found {
-			break
+			log.Printf("Received EPANDesc:%#v", ed)
+			return ed, nil
 		}
-		duration = duration + 1
 	}
-
-	ed, err := c.receivePanDesc()
-	log.Printf("Received EPANDesc:%#v", ed)
-	return ed, err
+	log.Println("duration limit(8) exceeds")
+	return PanDesc{}, fmt.Errorf("PAN not found")
 }
 
 // LL64 is .
-func (c BP35C2Client) LL64(addr string) (string, error) {
-	cmd := fmt.Sprintf("SKLL64 %s\r\n", addr)
-	c.send([]byte(cmd))
-	line, err := c.recv()
+func (c *BP35C2Client) LL64(addr string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.commandTimeout())
+	defer cancel()
+
+	ipV6Addr := ""
+	err := c.command(ctx, []byte(fmt.Sprintf("SKLL64 %s\r\n", addr)), func(ev event) (bool, error) {
+		if e, ok := ev.(lineEvent); ok {
+			ipV6Addr = strings.TrimSpace(e.line)
+			return true, nil
+		}
+		return false, nil
+	})
 	if err != nil {
 		return "", err
 	}
-	ipV6Addr := string(bytes.Trim(line, "\r\n"))
 	log.Printf("Translated address:%#v", ipV6Addr)
 	return ipV6Addr, nil
 }
 
 // SRegS2 is.
-func (c BP35C2Client) SRegS2(channel string) error {
-	cmd := fmt.Sprintf("SKSREG S2 %s\r\n", channel)
-	c.send([]byte(cmd))
-	c.recv()
-	return nil
+func (c *BP35C2Client) SRegS2(channel string) error {
+	return c.commandOK(fmt.Sprintf("SKSREG S2 %s\r\n", channel))
 }
 
 // SRegS3 is ..
-func (c BP35C2Client) SRegS3(panID string) error {
-	cmd := fmt.Sprintf("SKSREG S3 %s\r\n", panID)
-	c.send([]byte(cmd))
-	c.recv()
-	return nil
+func (c *BP35C2Client) SRegS3(panID string) error {
+	return c.commandOK(fmt.Sprintf("SKSREG S3 %s\r\n", panID))
 }
 
 // Join is ..
 func (c *BP35C2Client) Join(desc PanDesc) (bool, error) {
-	cmd := fmt.Sprintf("SKJOIN %s\r\n", desc.IPV6Addr)
-	c.send([]byte(cmd))
-	c.recv()
+	ctx, cancel := context.WithTimeout(context.Background(), c.commandTimeout())
+	defer cancel()
 
-	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	joined := false
+	err := c.command(ctx, []byte(fmt.Sprintf("SKJOIN %s\r\n", desc.IPV6Addr)), func(ev event) (bool, error) {
+		e, ok := ev.(notifyEvent)
+		if !ok {
+			return false, nil
+		}
+		switch e.num {
+		case 0x24:
+			log.Println("Join failed")
+			return true, nil
+		case 0x25:
+			log.Println("Join succeed")
+			joined = true
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		log.Println(err)
+		return false, fmt.Errorf("join failed: %w", err)
+	}
+	c.rmu.Lock()
+	c.joined = joined
+	c.rmu.Unlock()
+	c.setSessionUp(joined)
+	return joined, nil
+}
+
+// Send sends ECHONET Lite frame to the smart-meter and returns its response.
+// Frames of other transactions received while waiting are delivered to the channel returned by Listen.
+func (c *BP35C2Client) Send(data []byte) ([]byte, error) {
+	if !c.available() {
+		return nil, fmt.Errorf("session is down")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.commandTimeout())
 	defer cancel()
 
-	for {
-		select {
-		case <-ctx.Done():
-			err := fmt.Errorf("timeout:%w", ctx.Err())
-			log.Println(err)
-			return false, err
-		default:
-			res, err := c.recv()
-			if err != nil {
-				log.Println(err)
-				if err.Error() == "serial: timeout" {
-					continue
-				}
-				return false, fmt.Errorf("join failed: %w", err)
+	var res []byte
+	err := c.command(ctx, c.sendToCommand(data), func(ev event) (bool, error) {
+		switch e := ev.(type) {
+		case notifyEvent:
+			logSendEvent(e)
+		case rxUDPEvent:
+			if !c.receiveUDP(e) {
+				break
 			}
-
-			tokens := bytes.Split(res, []byte{' '})
-			eventType := string(tokens[0])
-
-			switch eventType {
-			case "EVENT":
-				if len(tokens) < 2 {
-					return false, fmt.Errorf("invalid format [%s]", res)
-				}
-				num, err := strconv.ParseInt(string(tokens[1]), 16, 8)
-				if err != nil {
-					return false, fmt.Errorf("invalid EVENT num [%s]", res)
-				}
-				switch num {
-				case 0x24:
-					log.Println("Join failed")
-					return false, nil
-				case 0x25:
-					log.Println("Join succeed")
-					c.joined = true
-					return true, nil
-				}
+			if sameTransaction(data, e.data) {
+				res = e.data
+				return true, nil
 			}
+			c.deliver(e.data)
 		}
+		return false, nil
+	})
+	if err != nil {
+		log.Println(err)
+		c.sendFailed()
+		return nil, err
 	}
+	c.sendSucceeded()
+	return res, nil
+}
+
+// SendOnly sends ECHONET Lite frame which has no response (e.g. INFC_Res) to the smart-meter
+func (c *BP35C2Client) SendOnly(data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.commandTimeout())
+	defer cancel()
 
+	return c.command(ctx, c.sendToCommand(data), func(ev event) (bool, error) {
+		switch e := ev.(type) {
+		case notifyEvent:
+			logSendEvent(e)
+		case rxUDPEvent:
+			if c.receiveUDP(e) {
+				c.deliver(e.data)
+			}
+		case okEvent:
+			return true, nil
+		}
+		return false, nil
+	})
 }
 
-// Send is...
-func (c *BP35C2Client) Send(data []byte) ([]byte, error) {
-	ipv6 := c.panDesc.IPV6Addr
-	cmd := []byte(fmt.Sprintf("SKSENDTO 1 %s 0E1A 1 0 %04X ", ipv6, len(data)))
+func (c *BP35C2Client) sendToCommand(data []byte) []byte {
+	ipv6 := c.getPanDesc().IPV6Addr
+	cmd := []byte(fmt.Sprintf("SKSENDTO 1 %s 0E1A 1 %s%04X ", ipv6, c.Dialect().side(), len(data)))
 	cmd = append(cmd, data...)
 	cmd = append(cmd, []byte("\r\n")...)
-	c.send(cmd)
+	return cmd
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
-	defer cancel()
+func logSendEvent(e notifyEvent) {
+	switch e.num {
+	case 0x21:
+		log.Println("UDP send succeed")
+	default:
+		log.Printf("unexpected EVENT %x\n", e.num)
+	}
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			log.Println(ctx.Err())
-			return nil, ctx.Err()
-		default:
-			res, err := c.recv()
-			if err != nil {
-				log.Println(err)
-				if err.Error() == "serial: timeout" {
-					continue
-				}
-				return nil, err
-			}
+// receiveUDP returns true for ECHONET Lite frame. Frames which are not response should be delivered.
+func (c *BP35C2Client) receiveUDP(e rxUDPEvent) bool {
+	switch e.lport {
+	case echonetLitePort:
+		return true
+	case panaPort:
+		log.Println("PANA data")
+	case mlePort:
+		log.Println("MLE data")
+	}
+	return false
+}
 
-			tokens := bytes.Split(res, []byte{' '})
-			eventType := string(tokens[0])
-
-			switch eventType {
-			case "EVENT":
-				if len(tokens) < 2 {
-					log.Printf("invalid format [%s]\n", res)
-				}
-				num, err := strconv.ParseInt(string(tokens[1]), 16, 8)
-				if err != nil {
-					log.Printf("invalid EVENT num [%s]\n", res)
-				}
-				switch num {
-				case 0x21:
-					log.Println("UDP send succeed")
-				default:
-					log.Printf("unexpected EVENT %x\n", num)
-				}
-			case "ERXUDP":
-				// ERXUDP <SENDER> <DEST> <RPORT> <LPORT> <SENDERLLA> (<RSSI>) <SECURED> <SIDE> <DATALEN> <DATA>
-				if len(tokens) >= 10 {
-					dstPort, err := strconv.ParseInt(string(tokens[4]), 16, 16)
-					if err != nil {
-						return nil, fmt.Errorf("invalid destination port [%s]", res)
-					}
-					switch dstPort {
-					case 3610: // ECHONET Lite
-						data := tokens[9]
-						return data, err
-					case 716: // PANA
-						log.Println("PANA data")
-					case 19788: // MLE
-						log.Println("MLE data")
-					}
-
-				}
-			}
-		}
+// Reception is the sender and the quality of a received UDP datagram
+type Reception struct {
+	Sender  string
+	RSSI    int  // dBm, valid if HasRSSI
+	HasRSSI bool // RSSI is reported by some firmware only
+	Secured bool
+	Time    time.Time
+}
+
+// LastReception returns Reception of the last UDP datagram received, including PANA
+func (c *BP35C2Client) LastReception() Reception {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	return c.reception
+}
+
+func (c *BP35C2Client) trackReception(ev event) {
+	e, ok := ev.(rxUDPEvent)
+	if !ok {
+		return
+	}
+	if e.lport == echonetLitePort && !e.secured {
+		log.Printf("unsecured ECHONET Lite frame from %s", e.sender)
+	}
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	c.reception = Reception{Sender: e.sender, RSSI: e.rssi, HasRSSI: e.hasRSSI, Secured: e.secured, Time: time.Now()}
+	c.setRSSI(e)
+}
+
+// sameTransaction returns true if ECHONET Lite frames have the same TID
+func sameTransaction(request, response []byte) bool {
+	return len(request) >= 4 && len(response) >= 4 && bytes.Equal(request[2:4], response[2:4])
+}
+
+// deliver passes a frame the smart-meter sent spontaneously to the listener
+func (c *BP35C2Client) deliver(data []byte) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	if c.frames == nil {
+		log.Printf("frame dropped without listener: %X", data)
+		return
 	}
+	select {
+	case c.frames <- data:
+	default:
+		log.Printf("frame dropped by full listener: %X", data)
+	}
+}
+
+// Listen starts to receive ECHONET Lite frames which the smart-meter sends spontaneously, such as INF of 0xEA.
+// Frames which arrive while a command is in progress are also delivered. The channel is closed when ctx is done.
+func (c *BP35C2Client) Listen(ctx context.Context) <-chan []byte {
+	frames := make(chan []byte, listenBufferSize)
+	c.rmu.Lock()
+	c.frames = frames
+	c.rmu.Unlock()
+	c.acquire()
+
+	go func() {
+		<-ctx.Done()
+		c.rmu.Lock()
+		c.frames = nil
+		close(frames)
+		c.rmu.Unlock()
+		c.release()
+	}()
+	return frames
+}
+
+func (c *BP35C2Client) isJoined() bool {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	return c.joined
+}
+
+func isTimeout(err error) bool {
+	return err.Error() == "serial: timeout"
 }
 
 // Connect connects to smart-meter
@@ -447,6 +623,30 @@ func (c *BP35C2Client) Connect(ctx context.Context, bRouteID, bRoutePW string) e
 		return err
 	}
 
+	err = c.setupDataFormat()
+	if err != nil {
+		return err
+	}
+
+	err = c.joinSaved()
+	if err != nil {
+		if c.stateFile != "" {
+			log.Printf("failed to join PAN in %s: %s", c.stateFile, err)
+		}
+		err = c.scanAndJoin(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.rmu.Lock()
+	c.keepSession = true
+	c.rmu.Unlock()
+	return nil
+}
+
+// scanAndJoin scans PAN and joins it
+func (c *BP35C2Client) scanAndJoin(ctx context.Context) error {
 	pd, err := c.Scan(ctx)
 	if err != nil {
 		err := fmt.Errorf("Scan failed: %w", err)
@@ -462,7 +662,17 @@ func (c *BP35C2Client) Connect(ctx context.Context, bRouteID, bRoutePW string) e
 	pd.IPV6Addr = ipv6Addr
 	log.Printf("Translated address:%#v", pd)
 
-	err = c.SRegS2(pd.Channel)
+	err = c.joinPAN(pd)
+	if err != nil {
+		return err
+	}
+	c.saveState(pd)
+	return nil
+}
+
+// joinPAN sets up PAN and starts PANA authentication
+func (c *BP35C2Client) joinPAN(pd PanDesc) error {
+	err := c.SRegS2(pd.Channel)
 	if err != nil {
 		err := fmt.Errorf("SRegS2 failed: %w", err)
 		return err
@@ -485,14 +695,24 @@ func (c *BP35C2Client) Connect(ctx context.Context, bRouteID, bRoutePW string) e
 		return fmt.Errorf("Join failed")
 	}
 
+	c.rmu.Lock()
 	c.panDesc = pd
-
-	// TODO: return error
+	c.rmu.Unlock()
 	return nil
 }
 
 // Term terminates PANA session
-func (c BP35C2Client) Term() {
-	c.send([]byte("SKTERM\r\n"))
-	c.recv()
+func (c *BP35C2Client) Term() {
+	c.rmu.Lock()
+	c.keepSession = false
+	c.rmu.Unlock()
+
+	err := c.commandOK("SKTERM\r\n")
+	if err != nil {
+		log.Println(err)
+	}
+	c.rmu.Lock()
+	c.joined = false
+	c.rmu.Unlock()
+	c.setSessionUp(false)
 }
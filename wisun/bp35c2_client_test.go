@@ -3,7 +3,9 @@ package wisun
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
@@ -18,16 +20,26 @@ type resp struct {
 func mock(t *testing.T, m *transport.MockSerial, input string, response []resp) {
 	t.Helper()
 
+	// Send and Recv are called from different goroutines
+	var mu sync.Mutex
 	lastCmd := ""
-	respCnt := -1
+	respCnt := len(response) // nothing to read until a command is sent
 
 	m.EXPECT().Send(gomock.Any()).DoAndReturn(func(cmd []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
 		lastCmd = string(cmd)
 		respCnt = -1
 		return nil
 	}).AnyTimes()
 
 	m.EXPECT().Recv().DoAndReturn(func() ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if respCnt >= len(response) {
+			time.Sleep(time.Millisecond)
+			return nil, fmt.Errorf("serial: timeout")
+		}
 		resp := ""
 		var err error
 		if respCnt == -1 {
@@ -269,6 +281,7 @@ func Test_scan(t *testing.T) {
 		duration int
 		input    string
 		response []resp
+		want     PanDesc
 		expect   bool
 		err      error
 	}{
@@ -289,7 +302,16 @@ func Test_scan(t *testing.T) {
 			response: []resp{
 				{"OK\r\n", nil},
 				{"EVENT 20 2001:0DB8:0000:0000:011A:1111:0000:0001 0\r\n", nil},
+				{"EPANDESC\r\n", nil},
+				{"  Channel:21\r\n", nil},
+				{"  Channel Page:01\r\n", nil},
+				{"  Pan ID:0002\r\n", nil},
+				{"  Addr:001A111100000002\r\n", nil},
+				{"  LQI:CA\r\n", nil},
+				{"  Side:0\r\n", nil},
+				{"  PairID:0112CE67\r\n", nil},
 			},
+			want:   PanDesc{Addr: "001A111100000002", Channel: "21", PanID: "0002"},
 			expect: true,
 		},
 		{
@@ -325,10 +347,13 @@ func Test_scan(t *testing.T) {
 			mock(t, m, tc.input, tc.response)
 
 			c := &BP35C2Client{serial: m}
-			got, err := c.scan(context.Background(), tc.duration)
+			desc, got, err := c.scan(context.Background(), tc.duration)
 			if tc.expect != got {
 				t.Errorf("Diffrent result: want:%v, got:%v", tc.expect, got)
 			}
+			if diff := cmp.Diff(tc.want, desc); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
 
 			if tc.err != nil && err != nil {
 				if tc.err.Error() != err.Error() {
@@ -342,46 +367,23 @@ func Test_scan(t *testing.T) {
 	}
 }
 
-func Test_receivePanDesc(t *testing.T) {
+func Test_scan_Timeout(t *testing.T) {
 	t.Parallel()
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	m := transport.NewMockSerial(ctrl)
+	mock(t, m, "SKSCAN 2 FFFFFFFF 4 0 \r\n", []resp{
+		{"OK\r\n", nil},
+	})
 
-	response := []string{
-		"EPANDESC\r\n",
-		"  Channel:21\r\n",
-		"  Channel Page:01\r\n",
-		"  Pan ID:0002\r\n",
-		"  Addr:001A111100000002\r\n",
-		"  LQI:CA\r\n",
-		"  Side:0\r\n",
-		"  PairID:0112CE67\r\n",
-	}
-
-	respCnt := 0
-	m.EXPECT().Recv().DoAndReturn(func() ([]byte, error) {
-		resp := response[respCnt]
-		respCnt++
-		return []byte(resp), nil
-	}).AnyTimes()
-
-	want := PanDesc{
-		Addr:     "001A111100000002",
-		IPV6Addr: "",
-		Channel:  "21",
-		PanID:    "0002",
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
 
 	c := &BP35C2Client{serial: m}
-	got, err := c.receivePanDesc()
-	if diff := cmp.Diff(want, got); diff != "" {
+	_, _, err := c.scan(ctx, 4)
+	if diff := cmp.Diff("SKSCAN timeout: context deadline exceeded", fmt.Sprint(err)); diff != "" {
 		t.Errorf("Diffrent result: -want, +got: \n%s", diff)
 	}
-
-	if err != nil {
-		t.Errorf("%s", err)
-	}
 }
 
 func Test_Scan(t *testing.T) {
@@ -550,25 +552,41 @@ func Test_Send(t *testing.T) {
 	t.Parallel()
 
 	testcases := []struct {
-		name     string
-		ipv6addr string
-		data     []byte
-		input    string
-		response []resp
-		want     []byte
-		err      error
+		name        string
+		ipv6addr    string
+		data        []byte
+		input       string
+		response    []resp
+		want        []byte
+		unsolicited [][]byte
+		err         error
 	}{
 		{
 			name:  "success",
-			data:  []byte{'X', 'X', 'X', 'X'},
+			data:  []byte{0x10, 0x81, 0x00, 0x01, 0x05, 0xff, 0x01, 0x02, 0x88, 0x01, 0x62, 0x01, 0xe7, 0x00},
 			input: "SKSENDTO 1 2001:0DB8:0000:0000:011A:1111:0000:0002 0E1A 1 0 000E \r\n",
 			response: []resp{
 				{"EVENT 21 2001:0DB8:0000:0000:011A:1111:0000:0002 0 00\r\n", nil},
 				{"OK\r\n", nil},
 				{"ERXUDP FE80:0000:0000:0000:021C:6400:030C:12A4 FE80:0000:0000:0000:021D:1291:0000:0574 0E1A 0E1A 001C6400030C12A4 1 0 0012 \x10\x81\x00\x01\x02\x88\x01\x05\xff\x01r\x01\xe7\x04\x00\x00\x01\xf8\r\n", nil},
 			},
-			want: []byte{0x10, 0x81, 0x00, 0x01, 0x02, 0x88, 0x01, 0x05, 0xff, 0x01, 'r', 0x01, 0xe7, 0x04, 0x00, 0x00, 0x01, 0xf8},
-			err:  nil,
+			want:        []byte{0x10, 0x81, 0x00, 0x01, 0x02, 0x88, 0x01, 0x05, 0xff, 0x01, 'r', 0x01, 0xe7, 0x04, 0x00, 0x00, 0x01, 0xf8},
+			unsolicited: [][]byte{},
+			err:         nil,
+		},
+		{
+			name:  "unsolicited frame before response",
+			data:  []byte{0x10, 0x81, 0x00, 0x01, 0x05, 0xff, 0x01, 0x02, 0x88, 0x01, 0x62, 0x01, 0xe7, 0x00},
+			input: "SKSENDTO 1 2001:0DB8:0000:0000:011A:1111:0000:0002 0E1A 1 0 000E \r\n",
+			response: []resp{
+				{"EVENT 21 2001:0DB8:0000:0000:011A:1111:0000:0002 0 00\r\n", nil},
+				{"OK\r\n", nil},
+				{"ERXUDP FE80:0000:0000:0000:021C:6400:030C:12A4 FF02:0000:0000:0000:0000:0000:0000:0001 0E1A 0E1A 001C6400030C12A4 1 0 000E \x10\x81\x00\x05\x02\x88\x01\x05\xff\x01\x73\x01\xea\x00\r\n", nil},
+				{"ERXUDP FE80:0000:0000:0000:021C:6400:030C:12A4 FE80:0000:0000:0000:021D:1291:0000:0574 0E1A 0E1A 001C6400030C12A4 1 0 0012 \x10\x81\x00\x01\x02\x88\x01\x05\xff\x01r\x01\xe7\x04\x00\x00\x01\xf8\r\n", nil},
+			},
+			want:        []byte{0x10, 0x81, 0x00, 0x01, 0x02, 0x88, 0x01, 0x05, 0xff, 0x01, 'r', 0x01, 0xe7, 0x04, 0x00, 0x00, 0x01, 0xf8},
+			unsolicited: [][]byte{{0x10, 0x81, 0x00, 0x05, 0x02, 0x88, 0x01, 0x05, 0xff, 0x01, 0x73, 0x01, 0xea, 0x00}},
+			err:         nil,
 		},
 	}
 
@@ -582,7 +600,8 @@ func Test_Send(t *testing.T) {
 			m := transport.NewMockSerial(ctrl)
 			mock(t, m, tc.input, tc.response)
 
-			c := &BP35C2Client{serial: m, panDesc: PanDesc{IPV6Addr: "2001:0DB8:0000:0000:011A:1111:0000:0002"}}
+			frames := make(chan []byte, listenBufferSize)
+			c := &BP35C2Client{serial: m, panDesc: PanDesc{IPV6Addr: "2001:0DB8:0000:0000:011A:1111:0000:0002"}, frames: frames}
 			got, err := c.Send(tc.data)
 			if diff := cmp.Diff(tc.want, got); diff != "" {
 				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
@@ -591,6 +610,75 @@ func Test_Send(t *testing.T) {
 			if tc.err != err {
 				t.Errorf("Diffrent error: want:%v, got:%v", tc.err, err)
 			}
+
+			close(frames)
+			unsolicited := [][]byte{}
+			for f := range frames {
+				unsolicited = append(unsolicited, f)
+			}
+			if diff := cmp.Diff(tc.unsolicited, unsolicited); diff != "" {
+				t.Errorf("Diffrent unsolicited frames: -want, +got: \n%s", diff)
+			}
 		})
 	}
 }
+
+func Test_SendOnly(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := transport.NewMockSerial(ctrl)
+	mock(t, m, "", []resp{
+		{"EVENT 21 2001:0DB8:0000:0000:011A:1111:0000:0002 0 00\r\n", nil},
+		{"OK\r\n", nil},
+	})
+
+	c := &BP35C2Client{serial: m, panDesc: PanDesc{IPV6Addr: "2001:0DB8:0000:0000:011A:1111:0000:0002"}}
+	err := c.SendOnly([]byte{0x10, 0x81, 0x00, 0x05, 0x05, 0xff, 0x01, 0x02, 0x88, 0x01, 0x7a, 0x01, 0xea, 0x00})
+	if err != nil {
+		t.Errorf("Diffrent error: want:%v, got:%v", nil, err)
+	}
+}
+
+func Test_Listen(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := transport.NewMockSerial(ctrl)
+
+	var mu sync.Mutex
+	response := []resp{
+		{"", fmt.Errorf("serial: timeout")},
+		{"ERXUDP FE80:0000:0000:0000:021C:6400:030C:12A4 FE80:0000:0000:0000:021D:1291:0000:0574 02CC 02CC 001C6400030C12A4 1 0 0002 \x00\x00\r\n", nil},
+		{"EVENT 29 FE80:0000:0000:0000:021C:6400:030C:12A4 0\r\n", nil},
+		{"ERXUDP FE80:0000:0000:0000:021C:6400:030C:12A4 FF02:0000:0000:0000:0000:0000:0000:0001 0E1A 0E1A 001C6400030C12A4 1 0 000E \x10\x81\x00\x05\x02\x88\x01\x05\xff\x01\x73\x01\xea\x00\r\n", nil},
+	}
+	m.EXPECT().Recv().DoAndReturn(func() ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(response) == 0 {
+			time.Sleep(time.Millisecond)
+			return nil, fmt.Errorf("serial: timeout")
+		}
+		r := response[0]
+		response = response[1:]
+		return []byte(r.d), r.e
+	}).AnyTimes()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := &BP35C2Client{serial: m}
+	frames := c.Listen(ctx)
+	got := <-frames
+	cancel()
+	for range frames {
+	}
+
+	want := []byte{0x10, 0x81, 0x00, 0x05, 0x02, 0x88, 0x01, 0x05, 0xff, 0x01, 0x73, 0x01, 0xea, 0x00}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+	}
+}
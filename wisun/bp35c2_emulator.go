@@ -4,20 +4,28 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"log"
-	"strings"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/goburrow/serial"
 )
 
-// BP35C2Emulator is ROHM BP35C2 emulator
+// BP35C2Emulator is ROHM BP35C2 emulator.
+// It responds to commands as described in a Scenario, and answers ECHONET Lite frames as a smart-meter.
 type BP35C2Emulator struct {
-	port     serial.Port
+	port     io.ReadWriteCloser
 	reader   *bufio.Reader
 	writer   *bufio.Writer
+	mu       sync.Mutex // guards writer, hexData, calls and reads
 	curLine  []byte
 	echoback bool
+	hexData  bool // ERXUDP data in ASCII hex, set with WOPT
+	scenario *Scenario
+	calls    map[int]int  // number of calls per rule
+	reads    map[byte]int // number of reads per EPC
 }
 
 // NewBP35C2Emulator returns BP35C2Emulator instance
@@ -35,22 +43,43 @@ func NewBP35C2Emulator(addr string) *BP35C2Emulator {
 	if err != nil {
 		log.Fatal("Faild to open serial:", err)
 	}
+	return NewBP35C2EmulatorWithPort(port)
+}
 
+// NewBP35C2EmulatorWithPort returns BP35C2Emulator instance which communicates through port, such as an end of net.Pipe.
+// It plays the default scenario until SetScenario is called.
+func NewBP35C2EmulatorWithPort(port io.ReadWriteCloser) *BP35C2Emulator {
+	s, err := BuiltinScenario("default")
+	if err != nil {
+		log.Fatal("Failed to load default scenario:", err)
+	}
 	r := bufio.NewReaderSize(port, 4096)
 	w := bufio.NewWriter(port)
-	return &BP35C2Emulator{port: port, reader: r, writer: w, echoback: true}
+	e := &BP35C2Emulator{port: port, reader: r, writer: w, echoback: true}
+	e.SetScenario(s)
+	return e
+}
+
+// SetScenario sets the scenario to play. Call it before Start.
+func (e *BP35C2Emulator) SetScenario(s *Scenario) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.scenario = s
+	e.echoback = !s.NoEcho
+	e.calls = map[int]int{}
+	e.reads = map[byte]int{}
 }
 
 // Close closees connection
-func (e BP35C2Emulator) Close() {
+func (e *BP35C2Emulator) Close() {
 	e.port.Close()
 }
 
-func (e BP35C2Emulator) flush() {
+func (e *BP35C2Emulator) flush() {
 	e.writer.Flush()
 }
 
-func (e BP35C2Emulator) echoBack() {
+func (e *BP35C2Emulator) echoBack() {
 	if e.echoback {
 		e.writer.Write(e.curLine)
 		e.writer.WriteString("\r\n")
@@ -59,171 +88,298 @@ func (e BP35C2Emulator) echoBack() {
 	}
 }
 
-func (e BP35C2Emulator) ok() {
+func (e *BP35C2Emulator) ok() {
 	e.writer.WriteString("OK\r\n")
 	e.flush()
 	fmt.Println("=>OK")
 }
 
-func (e BP35C2Emulator) er(num int) {
-	code := fmt.Sprintf("ER%02d", num)
-	e.writer.WriteString(code + "\r\n")
+func (e *BP35C2Emulator) fail(code string) {
+	e.writer.WriteString("FAIL " + code + "\r\n")
 	e.flush()
-	fmt.Println("=>", code)
+	fmt.Println("=> FAIL", code)
+}
+
+func (e *BP35C2Emulator) line(line string) {
+	e.writer.WriteString(line + "\r\n")
+	e.flush()
+	fmt.Println("=>", line)
+}
+
+// writeData writes data of ERXUDP in the format set with WOPT
+func (e *BP35C2Emulator) writeData(data []byte) {
+	if e.hexData {
+		e.writer.WriteString(fmt.Sprintf("%X", data))
+	} else {
+		e.writer.Write(data)
+	}
 }
 
-func (e BP35C2Emulator) rxUDP(data []byte) {
+func (e *BP35C2Emulator) rxUDP(data []byte) {
 	dlen := len(data)
 	cmd := fmt.Sprintf("ERXUDP FE80:0000:0000:0000:021C:6400:030C:12A4 FF02:0000:0000:0000:0000:0000:0000:0001 0E1A 0E1A 001C6400030C12A4 1 0 %04x ", dlen)
 	e.writer.WriteString(cmd)
-	e.writer.Write(data)
+	e.writeData(data)
 	e.writer.WriteString("\r\n")
 	e.flush()
 	fmt.Println(cmd)
 }
 
-// Start starts to emulate
+// pana writes PANA authentication exchange with the smart-meter, which succeeds
+func (e *BP35C2Emulator) pana() {
+	e.writer.WriteString("EVENT 22 FE80:0000:0000:0000:021D:1290:1234:5678 0\r\n")
+	e.flush()
+
+	e.writer.WriteString("EVENT 21 FE80:0000:0000:0000:021D:1290:1234:5678 0 00\r\n")
+	e.flush()
+	e.writer.WriteString("ERXUDP FE80:0000:0000:0000:021D:1290:1234:ABCD FE80:0000:0000:0000:021D:1290:1234:5678 02CC 02CC 12345678ABCDEF01 0 0 0028 ")
+	e.writeData([]byte{
+		0x00, 0x00, // Reserved (16bit)
+		0x00, 0x28, // Message Length (16bit)
+		0xc0, 0x00, // Flags (16bit) [RSCAPIrrrrrrrrrr] R:Request, S:Start, C:Complete, A:re-Authentication, P:Ping, I:IP Reconfiguration, r:reserved, 11000000 00000000 -> Request&Start
+		0x00, 0x02, // Message Type (16bit)
+		0x1c, 0x2f, 0xf4, 0xb9, // Session Identifier (32bit)
+		0x23, 0x84, 0x41, 0x58, // Sequence Number (32bit)
+		// AVPs
+		0x00, 0x06, // AVP Code (16bit)
+		0x00, 0x00, // AVP Flags (16bit) [Vrrrrrrrrrrrrrrr] V:Vendor, r:reserved
+		0x00, 0x04, // AVP Length (16bit), num of octets
+		0x00, 0x00, // Reserved (16bit)
+		// Vendor-ID (None. Present if V bit in AVP Flags is set)
+		0x00, 0x00, 0x00, 0x05, // Value
+		0x00, 0x03, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0c})
+
+	e.writer.WriteString("\r\n")
+	e.flush()
+
+	e.writer.WriteString("EVENT 21 FE80:0000:0000:0000:021D:1290:1234:5678 0 00\r\n")
+	e.flush()
+	e.writer.WriteString("ERXUDP FE80:0000:0000:0000:021D:1290:1234:ABCD FE80:0000:0000:0000:021D:1290:1234:5678 02CC 02CC 12345678ABCDEF01 0 0 0068 ")
+	e.writeData([]byte{
+		0x00, 0x00, 0x00, 0x68, 0x80, 0x00, 0x00, 0x02, 0x1c, 0x2f, 0xf4, 0xb9, 0x23, 0x84, 0x41, 0x59, // Flags: 10000000 00000000 -> Request
+		0x00, 0x05, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x4b, 0x5a, 0xd1, 0x90, 0x99, 0x8e, 0xe9, 0x2e,
+		0xfd, 0x8a, 0x95, 0xfc, 0x29, 0x38, 0x9f, 0xa2, 0x00, 0x02, 0x00, 0x00, 0x00, 0x38, 0x00, 0x00,
+		0x01, 0x48, 0x00, 0x38, 0x2f, 0x00, 0x98, 0x3b, 0x5c, 0x1b, 0x72, 0x33, 0x26, 0xe7, 0xbe, 0x2b,
+		0x4c, 0x07, 0xe8, 0x09, 0x0c, 0xf1, 0x53, 0x4d, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x39, 0x39,
+		0x30, 0x32, 0x31, 0x31, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30,
+		0x30, 0x31, 0x31, 0x32, 0x43, 0x45, 0x36, 0x37})
+	e.writer.WriteString("\r\n")
+	e.flush()
+
+	e.writer.WriteString("EVENT 21 FE80:0000:0000:0000:021D:1290:1234:5678 0 00\r\n")
+	e.flush()
+	e.writer.WriteString("ERXUDP FE80:0000:0000:0000:021D:1290:1234:ABCD FE80:0000:0000:0000:021D:1290:1234:5678 02CC 02CC 12345678ABCDEF01 0 0 0054 ")
+	e.writeData([]byte{
+		0x00, 0x00, 0x00, 0x54, 0x80, 0x00, 0x00, 0x02, 0x1c, 0x2f, 0xf4, 0xb9, 0x23, 0x84, 0x41, 0x5a, // Flags: 10000000 00000000 -> Request
+		0x00, 0x02, 0x00, 0x00, 0x00, 0x3b, 0x00, 0x00, 0x01, 0x49, 0x00, 0x3b, 0x2f, 0x80, 0x98, 0x3b,
+		0x5c, 0x1b, 0x72, 0x33, 0x26, 0xe7, 0xbe, 0x2b, 0x4c, 0x07, 0xe8, 0x09, 0x0c, 0xf1, 0x19, 0x88,
+		0x78, 0x8f, 0x48, 0x5e, 0x69, 0x94, 0xed, 0x46, 0xf0, 0xf5, 0x36, 0x1e, 0x9a, 0xb7, 0x00, 0x00,
+		0x00, 0x00, 0x73, 0x97, 0x16, 0xc5, 0x80, 0xad, 0x49, 0x62, 0x17, 0xb7, 0x68, 0x8a, 0xe4, 0x6e,
+		0xff, 0xaf, 0x7e, 0x00})
+	e.writer.WriteString("\r\n")
+	e.flush()
+
+	e.writer.WriteString("EVENT 21 FE80:0000:0000:0000:021D:1290:1234:5678 0 00\r\n")
+	e.flush()
+	e.writer.WriteString("ERXUDP FE80:0000:0000:0000:021D:1290:1234:ABCD FE80:0000:0000:0000:021D:1290:1234:5678 02CC 02CC 12345678ABCDEF01 0 0 0058 ")
+	e.writeData([]byte{
+		0x00, 0x00, 0x00, 0x58, 0xa0, 0x00, 0x00, 0x02, 0x1c, 0x2f, 0xf4, 0xb9, 0x23, 0x84, 0x41, 0x5b, // Flags: 10100000 00000000 -> Request&Complete
+		0x00, 0x07, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00,
+		0x00, 0x04, 0x00, 0x00, 0x03, 0x49, 0x00, 0x04, 0x00, 0x04, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00,
+		0x00, 0x00, 0x12, 0x10, 0x00, 0x08, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x51, 0x80,
+		0x00, 0x01, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x10, 0x8e, 0x79, 0x57, 0xb9, 0xb2, 0x6c, 0x04,
+		0x34, 0x5d, 0x70, 0x25, 0xc2, 0x4a, 0x24, 0x72})
+	e.writer.WriteString("\r\n")
+	e.flush()
+
+	e.writer.WriteString("EVENT 21 FE80:0000:0000:0000:021D:1290:1234:5678 0 00\r\n")
+	e.flush()
+	e.writer.WriteString("EVENT 25 FE80:0000:0000:0000:021D:1290:1234:5678 0\r\n")
+	e.flush()
+}
+
+// readLine reads a command terminated with CR, which is followed by LF except for ROPT and WOPT.
+// Binary data of SKSENDTO is read by its length since it may contain CR.
+func (e *BP35C2Emulator) readLine() ([]byte, error) {
+	line, err := e.reader.ReadBytes('\r')
+	// LF of the previous command
+	line = bytes.TrimPrefix(line, []byte{'\n'})
+	if err != nil {
+		return line, err
+	}
+
+	if bytes.HasPrefix(line, []byte("SKSENDTO")) {
+		// SKSENDTO <HANDLE> <IPADDR> <PORT> <SEC> <SIDE> <DATALEN> <DATA>
+		tokens := bytes.SplitN(line, []byte{' '}, 8)
+		if len(tokens) == 8 {
+			datalen, err := strconv.ParseInt(string(tokens[6]), 16, 32)
+			for err == nil && len(tokens[7]) <= int(datalen) {
+				var more []byte
+				more, err = e.reader.ReadBytes('\r')
+				line = append(line, more...)
+				tokens[7] = append(tokens[7], more...)
+			}
+		}
+	}
+	return bytes.TrimSuffix(line, []byte{'\r'}), nil
+}
+
+// Start starts to emulate until the port is closed
 func (e *BP35C2Emulator) Start() {
 	fmt.Println("Start")
 	for {
-		line, _, err := e.reader.ReadLine()
+		line, err := e.readLine()
 		if err != nil {
 			fmt.Println("[Error]", err)
+			if err != serial.ErrTimeout {
+				return
+			}
+			continue
 		}
-		e.curLine = line
 		fmt.Println("<=", string(line))
-		if bytes.HasPrefix(line, []byte("SKVER")) {
-			e.echoBack()
-			e.writer.WriteString("EVER 1.0.0\r\n")
-			e.flush()
-			e.ok()
-		} else if bytes.HasPrefix(line, []byte("SKSETPWD")) {
-			e.echoBack()
-			e.ok()
-		} else if bytes.HasPrefix(line, []byte("SKSETRBID")) {
-			e.echoBack()
-			e.ok()
-		} else if bytes.HasPrefix(line, []byte("SKSCAN")) {
-			e.echoBack()
-			e.ok()
 
-			time.Sleep(3 * time.Second)
-
-			cmd := string(line)
-			params := strings.Split(cmd, " ")
-			if params[3] == "4" {
-				e.writer.WriteString("EVENT 22 FE80:0000:0000:0000:021D:1290:1234:5678 0\r\n")
-				e.flush()
-			} else if params[3] == "5" {
-				e.writer.WriteString("EVENT 20 FE80:0000:0000:0000:021D:1290:1234:5678 0\r\n")
-				e.flush()
-
-				e.writer.WriteString("EPANDESC\r\n")
-				e.writer.WriteString(" Channel:21\r\n")
-				e.writer.WriteString(" Channel Page:09\r\n")
-				e.writer.WriteString(" Pan ID:8888\r\n")
-				e.writer.WriteString(" Addr:12345678ABCDEF01\r\n")
-				e.writer.WriteString(" LQI:E1\r\n")
-				e.writer.WriteString(" Side:0\r\n")
-				e.writer.WriteString(" PairID:AABBCCDD\r\n")
-				e.flush()
-			}
+		e.mu.Lock()
+		e.curLine = line
+		e.echoBack()
+		i, found := e.scenario.rule(string(line))
+		if !found {
+			e.builtin(line)
+			e.mu.Unlock()
+			continue
+		}
+		outputs := e.response(i)
+		e.mu.Unlock()
 
-		} else if bytes.HasPrefix(line, []byte("SKLL64")) {
-			e.echoBack()
+		// delays in the response don't block following commands, like notifications of the module
+		go e.play(outputs, line)
+	}
+}
 
-			e.writer.WriteString("FE80:0000:0000:0000:021D:1290:1234:ABCD\r\n")
-			e.flush()
+// builtin responds to commands not in the scenario
+func (e *BP35C2Emulator) builtin(line []byte) {
+	if bytes.HasPrefix(line, []byte("ROPT")) {
+		if e.hexData {
+			e.line("OK 01")
+		} else {
+			e.line("OK 00")
+		}
+	} else if bytes.HasPrefix(line, []byte("WOPT")) {
+		e.hexData = bytes.HasSuffix(line, []byte("01"))
+		e.ok()
+	} else {
+		fmt.Println("no rule for", string(line))
+	}
+}
 
-		} else if bytes.HasPrefix(line, []byte("SKSREG")) {
-			e.echoBack()
-			e.ok()
-		} else if bytes.HasPrefix(line, []byte("SKJOIN")) {
-			e.echoBack()
-			e.ok()
+// response returns outputs for the call of i-th rule
+func (e *BP35C2Emulator) response(i int) []Output {
+	responses := e.scenario.Rules[i].Responses
+	if len(responses) == 0 {
+		return nil
+	}
+	n := e.calls[i]
+	e.calls[i]++
+	if n >= len(responses) {
+		n = len(responses) - 1
+	}
+	return responses[n]
+}
 
-			e.writer.WriteString("EVENT 22 FE80:0000:0000:0000:021D:1290:1234:5678 0\r\n")
-			e.flush()
-
-			e.writer.WriteString("EVENT 21 FE80:0000:0000:0000:021D:1290:1234:5678 0 00\r\n")
-			e.flush()
-			e.writer.WriteString("ERXUDP FE80:0000:0000:0000:021D:1290:1234:ABCD FE80:0000:0000:0000:021D:1290:1234:5678 02CC 02CC 12345678ABCDEF01 0 0 0028 ")
-			e.writer.Write([]byte{
-				0x00, 0x00, // Reserved (16bit)
-				0x00, 0x28, // Message Length (16bit)
-				0xc0, 0x00, // Flags (16bit) [RSCAPIrrrrrrrrrr] R:Request, S:Start, C:Complete, A:re-Authentication, P:Ping, I:IP Reconfiguration, r:reserved, 11000000 00000000 -> Request&Start
-				0x00, 0x02, // Message Type (16bit)
-				0x1c, 0x2f, 0xf4, 0xb9, // Session Identifier (32bit)
-				0x23, 0x84, 0x41, 0x58, // Sequence Number (32bit)
-				// AVPs
-				0x00, 0x06, // AVP Code (16bit)
-				0x00, 0x00, // AVP Flags (16bit) [Vrrrrrrrrrrrrrrr] V:Vendor, r:reserved
-				0x00, 0x04, // AVP Length (16bit), num of octets
-				0x00, 0x00, // Reserved (16bit)
-				// Vendor-ID (None. Present if V bit in AVP Flags is set)
-				0x00, 0x00, 0x00, 0x05, // Value
-				0x00, 0x03, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0c})
-
-			e.writer.WriteString("\r\n")
-			e.flush()
-
-			e.writer.WriteString("EVENT 21 FE80:0000:0000:0000:021D:1290:1234:5678 0 00\r\n")
-			e.flush()
-			e.writer.WriteString("ERXUDP FE80:0000:0000:0000:021D:1290:1234:ABCD FE80:0000:0000:0000:021D:1290:1234:5678 02CC 02CC 12345678ABCDEF01 0 0 0068 ")
-			e.writer.Write([]byte{
-				0x00, 0x00, 0x00, 0x68, 0x80, 0x00, 0x00, 0x02, 0x1c, 0x2f, 0xf4, 0xb9, 0x23, 0x84, 0x41, 0x59, // Flags: 10000000 00000000 -> Request
-				0x00, 0x05, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x4b, 0x5a, 0xd1, 0x90, 0x99, 0x8e, 0xe9, 0x2e,
-				0xfd, 0x8a, 0x95, 0xfc, 0x29, 0x38, 0x9f, 0xa2, 0x00, 0x02, 0x00, 0x00, 0x00, 0x38, 0x00, 0x00,
-				0x01, 0x48, 0x00, 0x38, 0x2f, 0x00, 0x98, 0x3b, 0x5c, 0x1b, 0x72, 0x33, 0x26, 0xe7, 0xbe, 0x2b,
-				0x4c, 0x07, 0xe8, 0x09, 0x0c, 0xf1, 0x53, 0x4d, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x39, 0x39,
-				0x30, 0x32, 0x31, 0x31, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30, 0x30,
-				0x30, 0x31, 0x31, 0x32, 0x43, 0x45, 0x36, 0x37})
-			e.writer.WriteString("\r\n")
-			e.flush()
-
-			e.writer.WriteString("EVENT 21 FE80:0000:0000:0000:021D:1290:1234:5678 0 00\r\n")
-			e.flush()
-			e.writer.WriteString("ERXUDP FE80:0000:0000:0000:021D:1290:1234:ABCD FE80:0000:0000:0000:021D:1290:1234:5678 02CC 02CC 12345678ABCDEF01 0 0 0054 ")
-			e.writer.Write([]byte{
-				0x00, 0x00, 0x00, 0x54, 0x80, 0x00, 0x00, 0x02, 0x1c, 0x2f, 0xf4, 0xb9, 0x23, 0x84, 0x41, 0x5a, // Flags: 10000000 00000000 -> Request
-				0x00, 0x02, 0x00, 0x00, 0x00, 0x3b, 0x00, 0x00, 0x01, 0x49, 0x00, 0x3b, 0x2f, 0x80, 0x98, 0x3b,
-				0x5c, 0x1b, 0x72, 0x33, 0x26, 0xe7, 0xbe, 0x2b, 0x4c, 0x07, 0xe8, 0x09, 0x0c, 0xf1, 0x19, 0x88,
-				0x78, 0x8f, 0x48, 0x5e, 0x69, 0x94, 0xed, 0x46, 0xf0, 0xf5, 0x36, 0x1e, 0x9a, 0xb7, 0x00, 0x00,
-				0x00, 0x00, 0x73, 0x97, 0x16, 0xc5, 0x80, 0xad, 0x49, 0x62, 0x17, 0xb7, 0x68, 0x8a, 0xe4, 0x6e,
-				0xff, 0xaf, 0x7e, 0x00})
-			e.writer.WriteString("\r\n")
-			e.flush()
-
-			e.writer.WriteString("EVENT 21 FE80:0000:0000:0000:021D:1290:1234:5678 0 00\r\n")
-			e.flush()
-			e.writer.WriteString("ERXUDP FE80:0000:0000:0000:021D:1290:1234:ABCD FE80:0000:0000:0000:021D:1290:1234:5678 02CC 02CC 12345678ABCDEF01 0 0 0058 ")
-			e.writer.Write([]byte{
-				0x00, 0x00, 0x00, 0x58, 0xa0, 0x00, 0x00, 0x02, 0x1c, 0x2f, 0xf4, 0xb9, 0x23, 0x84, 0x41, 0x5b, // Flags: 10100000 00000000 -> Request&Complete
-				0x00, 0x07, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00,
-				0x00, 0x04, 0x00, 0x00, 0x03, 0x49, 0x00, 0x04, 0x00, 0x04, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00,
-				0x00, 0x00, 0x12, 0x10, 0x00, 0x08, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x51, 0x80,
-				0x00, 0x01, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x10, 0x8e, 0x79, 0x57, 0xb9, 0xb2, 0x6c, 0x04,
-				0x34, 0x5d, 0x70, 0x25, 0xc2, 0x4a, 0x24, 0x72})
-			e.writer.WriteString("\r\n")
-			e.flush()
-
-			e.writer.WriteString("EVENT 21 FE80:0000:0000:0000:021D:1290:1234:5678 0 00\r\n")
-			e.flush()
-			e.writer.WriteString("EVENT 25 FE80:0000:0000:0000:021D:1290:1234:5678 0\r\n")
-			e.flush()
-
-		} else if bytes.HasPrefix(line, []byte("SKSENDTO")) {
-			e.echoBack()
-
-			e.writer.WriteString("EVENT 21 FE80:0000:0000:0000:021D:1290:1234:5678 0 00\r\n")
-			e.flush()
+func (e *BP35C2Emulator) play(outputs []Output, line []byte) {
+	for _, o := range outputs {
+		time.Sleep(time.Duration(o.Delay))
 
+		e.mu.Lock()
+		switch {
+		case o.Line != "":
+			e.line(o.Line)
+		case o.OK:
 			e.ok()
-			e.rxUDP([]byte{0x10, 0x81, 0x00, 0x01, 0x02, 0x88, 0x01, 0x05, 0xff, 0x01, 'r', 0x01, 0xe7, 0x04, 0x00, 0x00, 0x01, 0xf8})
-		} else if bytes.HasPrefix(line, []byte("SKTERM")) {
-			e.echoBack()
-			e.ok()
+		case o.Fail != "":
+			e.fail(o.Fail)
+		case o.PANA:
+			e.pana()
+		case o.Reply:
+			if res := e.reply(line); res != nil {
+				e.rxUDP(res)
+			}
 		}
+		e.mu.Unlock()
+	}
+}
 
+// reply returns ECHONET Lite response to the frame sent by SKSENDTO, or nil if no response is necessary
+func (e *BP35C2Emulator) reply(line []byte) []byte {
+	tokens := bytes.SplitN(line, []byte{' '}, 8)
+	if len(tokens) < 8 {
+		return nil
+	}
+	datalen, err := strconv.ParseInt(string(tokens[6]), 16, 32)
+	if err != nil || len(tokens[7]) < int(datalen) {
+		return nil
+	}
+	req := tokens[7][:datalen]
+	// EHD(2) TID(2) SEOJ(3) DEOJ(3) ESV OPC
+	if len(req) < 12 {
+		return nil
+	}
+
+	var esv, snaESV byte
+	switch req[10] {
+	case 0x62: // Get
+		esv, snaESV = 0x72, 0x52
+	case 0x61: // SetC
+		esv, snaESV = 0x71, 0x51
+	case 0x60: // SetI
+		esv, snaESV = 0, 0x50
+	case 0x63: // INF_REQ
+		esv, snaESV = 0x73, 0x53
+	default:
+		return nil
+	}
+
+	props := []byte{}
+	sna := false
+	opc := int(req[11])
+	p := req[12:]
+	for i := 0; i < opc; i++ {
+		if len(p) < 2 || len(p) < 2+int(p[1]) {
+			return nil
+		}
+		epc, edt := p[0], p[2:2+int(p[1])]
+		p = p[2+int(p[1]):]
+
+		switch req[10] {
+		case 0x62, 0x63:
+			data, ok := e.scenario.property(epc, e.reads[epc])
+			if !ok {
+				sna = true
+				props = append(props, epc, 0)
+				continue
+			}
+			e.reads[epc]++
+			props = append(props, epc, byte(len(data)))
+			props = append(props, data...)
+		case 0x60, 0x61:
+			spec, ok := e.scenario.Properties[fmt.Sprintf("%02X", epc)]
+			if !ok {
+				sna = true
+				props = append(props, epc, byte(len(edt)))
+				props = append(props, edt...)
+				continue
+			}
+			spec.Data = fmt.Sprintf("%X", edt)
+			e.scenario.Properties[fmt.Sprintf("%02X", epc)] = spec
+			props = append(props, epc, 0)
+		}
+	}
+
+	if sna {
+		esv = snaESV
+	}
+	if esv == 0 {
+		return nil
 	}
+	res := []byte{req[0], req[1], req[2], req[3], req[7], req[8], req[9], req[4], req[5], req[6], esv, byte(opc)}
+	return append(res, props...)
 }
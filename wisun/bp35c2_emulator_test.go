@@ -0,0 +1,253 @@
+package wisun
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/u-one/go-el-controller/transport"
+)
+
+// startEmulator returns a client connected through net.Pipe to the emulator playing the builtin scenario
+func startEmulator(t *testing.T, name string) *BP35C2Client {
+	t.Helper()
+
+	s, err := BuiltinScenario(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := startEmulatorWith(t, s)
+	return c
+}
+
+// startEmulatorWith returns a client connected through net.Pipe to the emulator playing s
+func startEmulatorWith(t *testing.T, s *Scenario) (*BP35C2Client, *BP35C2Emulator) {
+	t.Helper()
+
+	clientPort, emulatorPort := net.Pipe()
+	e := NewBP35C2EmulatorWithPort(emulatorPort)
+	e.SetScenario(s)
+	go e.Start()
+
+	c := NewBP35C2ClientWithSerial(transport.NewSerialWithPort(clientPort))
+	c.timeout = time.Second
+	t.Cleanup(func() {
+		c.Close()
+		e.Close()
+	})
+	return c, e
+}
+
+// calls returns number of commands starting with prefix which the emulator has received
+func calls(e *BP35C2Emulator, prefix string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := 0
+	for i, r := range e.scenario.Rules {
+		if strings.HasPrefix(r.Command, prefix) {
+			n += e.calls[i]
+		}
+	}
+	return n
+}
+
+// getFrame returns ECHONET Lite Get of epc from the controller to the smart-meter
+func getFrame(tid uint16, epc byte) []byte {
+	return []byte{0x10, 0x81, byte(tid >> 8), byte(tid), 0x05, 0xff, 0x01, 0x02, 0x88, 0x01, 0x62, 0x01, epc, 0x00}
+}
+
+func Test_Emulator_Default(t *testing.T) {
+	t.Parallel()
+
+	c := startEmulator(t, "default")
+
+	testcases := []struct {
+		name string
+		req  []byte
+		want []byte
+	}{
+		{
+			name: "instant power",
+			req:  getFrame(1, 0xe7),
+			want: []byte{0x10, 0x81, 0x00, 0x01, 0x02, 0x88, 0x01, 0x05, 0xff, 0x01, 0x72, 0x01, 0xe7, 0x04, 0x00, 0x00, 0x01, 0xf8},
+		},
+		{
+			name: "CR in data",
+			req:  getFrame(0x0d0a, 0xe0),
+			want: []byte{0x10, 0x81, 0x0d, 0x0a, 0x02, 0x88, 0x01, 0x05, 0xff, 0x01, 0x72, 0x01, 0xe0, 0x04, 0x00, 0x00, 0x30, 0x39},
+		},
+		{
+			name: "not supported",
+			req:  getFrame(3, 0xe3),
+			want: []byte{0x10, 0x81, 0x00, 0x03, 0x02, 0x88, 0x01, 0x05, 0xff, 0x01, 0x52, 0x01, 0xe3, 0x00},
+		},
+	}
+
+	for _, tc := range testcases {
+		got, err := c.Send(tc.req)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("%s: Diffrent result: -want, +got: \n%s", tc.name, diff)
+		}
+	}
+}
+
+func Test_Emulator_JoinFailure(t *testing.T) {
+	t.Parallel()
+
+	c := startEmulator(t, "join_failure")
+
+	err := c.Connect(context.Background(), "00000000000000000000000000000000", "PASSWORD")
+	if diff := cmp.Diff("Join failed", fmt.Sprint(err)); diff != "" {
+		t.Errorf("Diffrent error: -want, +got: \n%s", diff)
+	}
+	if c.SessionUp() {
+		t.Errorf("session is up")
+	}
+}
+
+func Test_Emulator_SessionExpiry(t *testing.T) {
+	t.Parallel()
+
+	c := startEmulator(t, "session_expiry")
+	c.delay = 10 * time.Millisecond
+	reconnects := testutil.ToFloat64(reconnectsMetric)
+
+	// keep reading to receive EVENT 29
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Listen(ctx)
+
+	err := c.Connect(context.Background(), "00000000000000000000000000000000", "PASSWORD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return testutil.ToFloat64(reconnectsMetric)-reconnects >= 1 })
+	waitFor(t, c.SessionUp)
+
+	// instant power changes for each read
+	for i, power := range []byte{0x01, 0x04, 0x0b, 0x0b} {
+		got, err := c.Send(getFrame(uint16(i), 0xe7))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(power, got[len(got)-2]); diff != "" {
+			t.Errorf("Diffrent power: -want, +got: \n%s", diff)
+		}
+	}
+}
+
+func Test_Emulator_ErrorCodes(t *testing.T) {
+	t.Parallel()
+
+	c := startEmulator(t, "error_codes")
+	c.timeout = 200 * time.Millisecond
+
+	_, err := c.Version()
+	if diff := cmp.Diff("command failed [FAIL ER04]", fmt.Sprint(err)); diff != "" {
+		t.Errorf("Diffrent error: -want, +got: \n%s", diff)
+	}
+
+	err = c.SetBRoutePassword("PASSWORD")
+	if diff := cmp.Diff("command failed [FAIL ER04]", fmt.Sprint(err)); diff != "" {
+		t.Errorf("Diffrent error: -want, +got: \n%s", diff)
+	}
+	err = c.SetBRoutePassword("PASSWORD")
+	if err != nil {
+		t.Errorf("retry failed: %s", err)
+	}
+
+	testcases := []struct {
+		name string
+		err  string
+	}{
+		{name: "ER10", err: "command failed [FAIL ER10]"},
+		{name: "dropped", err: "SKSENDTO timeout: context deadline exceeded"},
+		{name: "delayed", err: "<nil>"},
+	}
+	for _, tc := range testcases {
+		_, err := c.Send(getFrame(1, 0xe7))
+		if diff := cmp.Diff(tc.err, fmt.Sprint(err)); diff != "" {
+			t.Errorf("%s: Diffrent error: -want, +got: \n%s", tc.name, diff)
+		}
+	}
+}
+
+func Test_Emulator_StateFile(t *testing.T) {
+	t.Parallel()
+
+	// PAN found by scan in the default scenario
+	scanned := PanDesc{Addr: "12345678ABCDEF01", IPV6Addr: "FE80:0000:0000:0000:021D:1290:1234:ABCD", Channel: "21", PanID: "8888"}
+	// PAN which refuses to join with EVENT 24 in the default scenario
+	stale := PanDesc{Addr: "12345678ABCDEF02", IPV6Addr: "FE80:0000:0000:0000:021D:1290:1234:EF02", Channel: "22", PanID: "8889"}
+
+	testcases := []struct {
+		name      string
+		saved     PanDesc
+		wantScans bool
+		wantJoins int
+	}{
+		{name: "hit", saved: scanned, wantScans: false, wantJoins: 1},
+		// join fails, then scans and joins the PAN found
+		{name: "miss", saved: stale, wantScans: true, wantJoins: 2},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := BuiltinScenario("default")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// scan without waiting
+			for _, r := range s.Rules {
+				for _, outputs := range r.Responses {
+					for i := range outputs {
+						outputs[i].Delay = 0
+					}
+				}
+			}
+			c, e := startEmulatorWith(t, s)
+
+			path := filepath.Join(t.TempDir(), "state.json")
+			err = savePanDesc(path, tc.saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.SetStateFile(path)
+
+			err = c.Connect(context.Background(), "00000000000000000000000000000000", "PASSWORD")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := calls(e, "SKSCAN") > 0; got != tc.wantScans {
+				t.Errorf("Diffrent result: want scans:%v, got:%v", tc.wantScans, got)
+			}
+			if got := calls(e, "SKJOIN"); got != tc.wantJoins {
+				t.Errorf("Diffrent result: want joins:%d, got:%d", tc.wantJoins, got)
+			}
+			if diff := cmp.Diff(scanned, c.getPanDesc()); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+			got, err := loadPanDesc(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(scanned, got); diff != "" {
+				t.Errorf("Diffrent state file: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package wisun
+
+import (
+	"bytes"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// event is a response or notification of BP35C2 parsed from lines
+type event interface{}
+
+// okEvent is OK of a command, with the value such as "OK 01" of ROPT
+type okEvent struct {
+	value string
+}
+
+// failEvent is failure of a command (FAIL ERxx)
+type failEvent struct {
+	line string
+}
+
+// echoEvent is echo back of a command
+type echoEvent struct {
+	line string
+}
+
+// notifyEvent is EVENT nn <SENDER> <SIDE> [<PARAM>]
+type notifyEvent struct {
+	num    int
+	sender string
+	params []string
+}
+
+// rxUDPEvent is ERXUDP <SENDER> <DEST> <RPORT> <LPORT> <SENDERLLA> [<RSSI>] <SECURED> [<SIDE>] <DATALEN> <DATA>.
+// RSSI is present on some firmware, and SIDE is absent on modules without Side.
+type rxUDPEvent struct {
+	sender    string
+	dest      string
+	rport     int
+	lport     int
+	senderLLA string
+	rssi      int // dBm, valid if hasRSSI
+	hasRSSI   bool
+	secured   bool
+	side      string
+	data      []byte
+}
+
+// panDescEvent is PAN found by active scan (EPANDESC and its fields)
+type panDescEvent struct {
+	desc   PanDesc
+	lqi    int // valid if hasLQI
+	hasLQI bool
+}
+
+// verEvent is EVER <VERSION>
+type verEvent struct {
+	version string
+}
+
+// lqiEvent is ELQI <LQI> of SKLQI, LQI of the link to the PAN joined
+type lqiEvent struct {
+	lqi int
+}
+
+// addrEvent is EADDR and addresses which follow it until OK
+type addrEvent struct {
+	addrs []string
+}
+
+// infoEvent is EINFO <IPADDR> <ADDR64> <CHANNEL> <PANID> <ADDR16> <SIDE>
+// SIDE is missing on modules without Side, e.g. BP35A1 and RL7023.
+type infoEvent struct {
+	fields []string
+}
+
+// lineEvent is a line which is not any of above, e.g. IPv6 address of SKLL64
+type lineEvent struct {
+	line string
+}
+
+// errorEvent is failure to read serial port
+type errorEvent struct {
+	err error
+}
+
+// ports of ERXUDP
+const (
+	echonetLitePort = 3610
+	panaPort        = 716
+	mlePort         = 19788
+)
+
+var failPattern = regexp.MustCompile(`^(FAIL|(FAIL )?ER[0-9A-F]{2})$`)
+
+// maxDataLen is the maximum DATALEN of ERXUDP
+const maxDataLen = 0x4D0
+
+// parser parses lines from BP35C2 into events.
+// Some responses, such as EPANDESC, consist of several lines, so it keeps state between lines.
+type parser struct {
+	dialect Dialect
+	panDesc *panDescEvent // EPANDESC in progress
+	addrs   []string      // EADDR in progress
+	inAddr  bool
+	rx      *rxUDPEvent // ERXUDP whose binary data continues to the next line
+	rxLen   int         // DATALEN of rx
+}
+
+// parse returns events completed by line.
+// line may have its line ending, which is necessary to restore binary data of ERXUDP split by CRLF or LF in it.
+func (p *parser) parse(line []byte) []event {
+	if p.rx != nil {
+		return p.continueRXUDP(line)
+	}
+	raw := line
+	line = trimEOL(line)
+
+	events := []event{}
+	if p.panDesc != nil {
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			if p.panDescField(string(bytes.TrimSpace(line))) {
+				events = append(events, *p.panDesc)
+				p.panDesc = nil
+			}
+			return events
+		}
+		// fields are incomplete, but EPANDESC finished
+		events = append(events, *p.panDesc)
+		p.panDesc = nil
+	}
+	if len(bytes.TrimSpace(line)) == 0 {
+		return events
+	}
+
+	tokens := strings.Fields(string(line))
+	switch {
+	case tokens[0] == "OK":
+		if p.inAddr {
+			events = append(events, addrEvent{addrs: p.addrs})
+			p.addrs, p.inAddr = nil, false
+		}
+		return append(events, okEvent{value: strings.Join(tokens[1:], " ")})
+	case failPattern.Match(line):
+		p.addrs, p.inAddr = nil, false
+		return append(events, failEvent{line: string(line)})
+	case tokens[0] == "ERXUDP":
+		if evs, ok := p.parseRXUDP(raw); ok {
+			return append(events, evs...)
+		}
+	case tokens[0] == "EVENT":
+		if len(tokens) >= 2 {
+			num, err := strconv.ParseInt(tokens[1], 16, 32)
+			if err == nil {
+				ev := notifyEvent{num: int(num)}
+				if len(tokens) >= 3 {
+					ev.sender = tokens[2]
+					ev.params = tokens[3:]
+				}
+				return append(events, ev)
+			}
+		}
+	case tokens[0] == "EPANDESC":
+		p.panDesc = &panDescEvent{}
+		return events
+	case tokens[0] == "EVER":
+		ev := verEvent{}
+		if len(tokens) >= 2 {
+			ev.version = tokens[1]
+		}
+		return append(events, ev)
+	case tokens[0] == "EADDR":
+		p.addrs, p.inAddr = []string{}, true
+		return events
+	case tokens[0] == "EINFO":
+		return append(events, infoEvent{fields: tokens[1:]})
+	case tokens[0] == "ELQI" && len(tokens) >= 2:
+		lqi, err := strconv.ParseUint(tokens[len(tokens)-1], 16, 8)
+		if err == nil {
+			return append(events, lqiEvent{lqi: int(lqi)})
+		}
+	case strings.HasPrefix(tokens[0], "SK"):
+		return append(events, echoEvent{line: string(line)})
+	case p.inAddr:
+		p.addrs = append(p.addrs, string(bytes.TrimSpace(line)))
+		return events
+	}
+	return append(events, lineEvent{line: string(line)})
+}
+
+// panDescField sets a field of EPANDESC and returns true at the last field
+func (p *parser) panDescField(field string) bool {
+	i := strings.Index(field, ":")
+	if i < 0 {
+		return false
+	}
+	name, value := field[:i], field[i+1:]
+	switch name {
+	case "Channel":
+		p.panDesc.desc.Channel = value
+	case "Pan ID":
+		p.panDesc.desc.PanID = value
+	case "Addr":
+		p.panDesc.desc.Addr = value
+	case "LQI":
+		lqi, err := strconv.ParseUint(value, 16, 8)
+		if err == nil {
+			p.panDesc.lqi, p.panDesc.hasLQI = int(lqi), true
+		}
+	case "PairID":
+		return true
+	}
+	return false
+}
+
+// parseRXUDP parses ERXUDP, and reads DATALEN bytes of its data.
+// Binary data is incomplete if it contains a line ending, and the rest is read from the following lines.
+func (p *parser) parseRXUDP(raw []byte) ([]event, bool) {
+	rest := raw
+	field := func() string {
+		i := bytes.IndexByte(rest, ' ')
+		if i < 0 {
+			f := string(trimEOL(rest))
+			rest = nil
+			return f
+		}
+		f := string(rest[:i])
+		rest = rest[i+1:]
+		return f
+	}
+
+	field() // ERXUDP
+	ev := rxUDPEvent{sender: field(), dest: field()}
+	rport, err := strconv.ParseInt(field(), 16, 32)
+	if err != nil {
+		return nil, false
+	}
+	lport, err := strconv.ParseInt(field(), 16, 32)
+	if err != nil {
+		return nil, false
+	}
+	ev.rport, ev.lport = int(rport), int(lport)
+	ev.senderLLA = field()
+	secured := field()
+	if len(secured) > 1 {
+		// SECURED is a digit, so this is RSSI
+		rssi, err := strconv.Atoi(secured)
+		if err != nil {
+			return nil, false
+		}
+		ev.rssi, ev.hasRSSI = rssi, true
+		secured = field()
+	}
+	ev.secured = secured == "1"
+	if !p.dialect.NoSide {
+		ev.side = field()
+	}
+	datalen, err := strconv.ParseInt(field(), 16, 32)
+	if err != nil || datalen > maxDataLen || rest == nil {
+		return nil, false
+	}
+
+	if p.dialect.HexData {
+		data, err := hex.DecodeString(string(trimEOL(rest)))
+		if err != nil || len(data) < int(datalen) {
+			return nil, false
+		}
+		ev.data = data[:datalen]
+		return []event{ev}, true
+	}
+
+	p.rx, p.rxLen = &ev, int(datalen)
+	return p.continueRXUDP(rest), true
+}
+
+// continueRXUDP appends binary data of ERXUDP in progress, and returns it if it is complete
+func (p *parser) continueRXUDP(data []byte) []event {
+	p.rx.data = append(p.rx.data, data...)
+	if !bytes.HasSuffix(data, []byte{'\n'}) {
+		// line ending was removed by the reader, which is usually CRLF
+		p.rx.data = append(p.rx.data, '\r', '\n')
+	}
+	if len(p.rx.data) < p.rxLen {
+		return []event{}
+	}
+	ev := *p.rx
+	ev.data = ev.data[:p.rxLen]
+	p.rx = nil
+	return []event{ev}
+}
+
+// trimEOL removes CRLF or LF at the end of line
+func trimEOL(line []byte) []byte {
+	line = bytes.TrimSuffix(line, []byte{'\n'})
+	return bytes.TrimSuffix(line, []byte{'\r'})
+}
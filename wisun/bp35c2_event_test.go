@@ -0,0 +1,197 @@
+package wisun
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_parser(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name    string
+		dialect Dialect
+		lines   []string
+		want    []event
+	}{
+		{
+			name:  "OK and FAIL",
+			lines: []string{"OK", "FAIL ER04", "ER10", "FAIL"},
+			want:  []event{okEvent{}, failEvent{line: "FAIL ER04"}, failEvent{line: "ER10"}, failEvent{line: "FAIL"}},
+		},
+		{
+			name:  "echo back and other line",
+			lines: []string{"SKLL64 001A111100000002", "FE80:0000:0000:0000:021A:1111:0000:0002", ""},
+			want: []event{
+				echoEvent{line: "SKLL64 001A111100000002"},
+				lineEvent{line: "FE80:0000:0000:0000:021A:1111:0000:0002"},
+			},
+		},
+		{
+			name:  "EVENT",
+			lines: []string{"EVENT 21 2001:0DB8:0000:0000:011A:1111:0000:0002 0 00", "EVENT 1F FE80:0000:0000:0000:021D:1291:0000:0574 0", "EVENT XX"},
+			want: []event{
+				notifyEvent{num: 0x21, sender: "2001:0DB8:0000:0000:011A:1111:0000:0002", params: []string{"0", "00"}},
+				notifyEvent{num: 0x1f, sender: "FE80:0000:0000:0000:021D:1291:0000:0574", params: []string{"0"}},
+				lineEvent{line: "EVENT XX"},
+			},
+		},
+		{
+			name:  "ERXUDP",
+			lines: []string{"ERXUDP FE80:0000:0000:0000:021C:6400:030C:12A4 FF02:0000:0000:0000:0000:0000:0000:0001 0E1A 0E1A 001C6400030C12A4 1 0 0004 \x10 \x81\x00"},
+			want: []event{
+				rxUDPEvent{
+					sender:    "FE80:0000:0000:0000:021C:6400:030C:12A4",
+					dest:      "FF02:0000:0000:0000:0000:0000:0000:0001",
+					rport:     3610,
+					lport:     3610,
+					senderLLA: "001C6400030C12A4",
+					secured:   true,
+					side:      "0",
+					data:      []byte{0x10, ' ', 0x81, 0x00},
+				},
+			},
+		},
+		{
+			name:    "ERXUDP without Side in ASCII hex",
+			dialect: DialectBP35A1,
+			lines:   []string{"ERXUDP FE80:0000:0000:0000:021C:6400:030C:12A4 FF02:0000:0000:0000:0000:0000:0000:0001 0E1A 0E1A 001C6400030C12A4 1 0004 10208100"},
+			want: []event{
+				rxUDPEvent{
+					sender:    "FE80:0000:0000:0000:021C:6400:030C:12A4",
+					dest:      "FF02:0000:0000:0000:0000:0000:0000:0001",
+					rport:     3610,
+					lport:     3610,
+					senderLLA: "001C6400030C12A4",
+					secured:   true,
+					data:      []byte{0x10, ' ', 0x81, 0x00},
+				},
+			},
+		},
+		{
+			name:  "ERXUDP with RSSI and data longer than DATALEN",
+			lines: []string{"ERXUDP FE80:0000:0000:0000:021C:6400:030C:12A4 FE80:0000:0000:0000:021D:1291:0000:0574 0E1A 0E1A 001C6400030C12A4 -52 1 0 0002 \x10\x81\x00\r\n"},
+			want: []event{
+				rxUDPEvent{
+					sender:    "FE80:0000:0000:0000:021C:6400:030C:12A4",
+					dest:      "FE80:0000:0000:0000:021D:1291:0000:0574",
+					rport:     3610,
+					lport:     3610,
+					senderLLA: "001C6400030C12A4",
+					rssi:      -52,
+					hasRSSI:   true,
+					secured:   true,
+					side:      "0",
+					data:      []byte{0x10, 0x81},
+				},
+			},
+		},
+		{
+			name: "ERXUDP with CRLF and LF in binary data",
+			lines: []string{
+				"ERXUDP FE80:0000:0000:0000:021C:6400:030C:12A4 FE80:0000:0000:0000:021D:1291:0000:0574 0E1A 0E1A 001C6400030C12A4 1 0 0006 \x10\r\n",
+				"\x81\n",
+				"\x00\r\n",
+				"OK\r\n",
+			},
+			want: []event{
+				rxUDPEvent{
+					sender:    "FE80:0000:0000:0000:021C:6400:030C:12A4",
+					dest:      "FE80:0000:0000:0000:021D:1291:0000:0574",
+					rport:     3610,
+					lport:     3610,
+					senderLLA: "001C6400030C12A4",
+					secured:   true,
+					side:      "0",
+					data:      []byte{0x10, '\r', '\n', 0x81, '\n', 0x00},
+				},
+				okEvent{},
+			},
+		},
+		{
+			name:    "ERXUDP in ASCII hex shorter than DATALEN",
+			dialect: DialectBP35A1,
+			lines:   []string{"ERXUDP FE80:0000:0000:0000:021C:6400:030C:12A4 FF02:0000:0000:0000:0000:0000:0000:0001 0E1A 0E1A 001C6400030C12A4 1 0004 102081"},
+			want:    []event{lineEvent{line: "ERXUDP FE80:0000:0000:0000:021C:6400:030C:12A4 FF02:0000:0000:0000:0000:0000:0000:0001 0E1A 0E1A 001C6400030C12A4 1 0004 102081"}},
+		},
+		{
+			name:  "OK with value",
+			lines: []string{"OK 01"},
+			want:  []event{okEvent{value: "01"}},
+		},
+		{
+			name:  "too short ERXUDP",
+			lines: []string{"ERXUDP FE80:0000:0000:0000:021C:6400:030C:12A4"},
+			want:  []event{lineEvent{line: "ERXUDP FE80:0000:0000:0000:021C:6400:030C:12A4"}},
+		},
+		{
+			name: "EPANDESC",
+			lines: []string{
+				"EVENT 20 FE80:0000:0000:0000:021D:1290:1234:5678 0",
+				"EPANDESC",
+				"  Channel:21",
+				"  Channel Page:09",
+				"  Pan ID:8888",
+				"  Addr:12345678ABCDEF01",
+				"  LQI:E1",
+				"  Side:0",
+				"  PairID:AABBCCDD",
+				"EVENT 22 FE80:0000:0000:0000:021D:1290:1234:5678 0",
+			},
+			want: []event{
+				notifyEvent{num: 0x20, sender: "FE80:0000:0000:0000:021D:1290:1234:5678", params: []string{"0"}},
+				panDescEvent{desc: PanDesc{Addr: "12345678ABCDEF01", Channel: "21", PanID: "8888"}, lqi: 0xe1, hasLQI: true},
+				notifyEvent{num: 0x22, sender: "FE80:0000:0000:0000:021D:1290:1234:5678", params: []string{"0"}},
+			},
+		},
+		{
+			name:  "incomplete EPANDESC",
+			lines: []string{"EPANDESC", " Channel:21", " Channel Page", "OK"},
+			want:  []event{panDescEvent{desc: PanDesc{Channel: "21"}}, okEvent{}},
+		},
+		{
+			name:  "EVER",
+			lines: []string{"EVER 1.5.2", "EVER"},
+			want:  []event{verEvent{version: "1.5.2"}, verEvent{}},
+		},
+		{
+			name:  "EADDR",
+			lines: []string{"EADDR", "FE80:0000:0000:0000:021D:1291:0000:0574", "FE80:0000:0000:0000:021C:6400:030C:12A4", "OK"},
+			want: []event{
+				addrEvent{addrs: []string{"FE80:0000:0000:0000:021D:1291:0000:0574", "FE80:0000:0000:0000:021C:6400:030C:12A4"}},
+				okEvent{},
+			},
+		},
+		{
+			name:  "EINFO",
+			lines: []string{"EINFO FE80:0000:0000:0000:021D:1291:0000:0574 001D129100000574 21 8888 FFFE"},
+			want: []event{
+				infoEvent{fields: []string{"FE80:0000:0000:0000:021D:1291:0000:0574", "001D129100000574", "21", "8888", "FFFE"}},
+			},
+		},
+		{
+			name:  "ELQI",
+			lines: []string{"ELQI A0", "ELQI XY"},
+			want:  []event{lqiEvent{lqi: 0xa0}, lineEvent{line: "ELQI XY"}},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := parser{dialect: tc.dialect}
+			got := []event{}
+			for _, line := range tc.lines {
+				got = append(got, p.parse([]byte(line))...)
+			}
+			opt := cmp.AllowUnexported(okEvent{}, failEvent{}, echoEvent{}, notifyEvent{}, rxUDPEvent{}, panDescEvent{}, verEvent{}, addrEvent{}, infoEvent{}, lqiEvent{}, lineEvent{})
+			if diff := cmp.Diff(tc.want, got, opt); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
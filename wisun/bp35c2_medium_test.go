@@ -4,6 +4,7 @@ package wisun
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -64,7 +65,7 @@ func Test_Medium_scan(t *testing.T) {
 			c := NewBP35C2Client(testPort)
 			defer c.Close()
 
-			got, err := c.scan(context.Background(), tc.duration)
+			_, got, err := c.scan(context.Background(), tc.duration)
 			if tc.want != got {
 				t.Errorf("Diffrent result: want:%v, got:%v", tc.want, got)
 			}
@@ -227,7 +228,7 @@ func Test_Medium_Send(t *testing.T) {
 	}{
 		{
 			name: "success",
-			data: []byte{'X', 'X', 'X', 'X'},
+			data: []byte{0x10, 0x81, 0x00, 0x01, 0x05, 0xff, 0x01, 0x02, 0x88, 0x01, 0x62, 0x01, 0xe7, 0x00},
 			want: []byte{0x10, 0x81, 0x00, 0x01, 0x02, 0x88, 0x01, 0x05, 0xff, 0x01, 'r', 0x01, 0xe7, 0x04, 0x00, 0x00, 0x01, 0xf8},
 			err:  nil,
 		},
@@ -259,3 +260,75 @@ func Test_Medium_Term(t *testing.T) {
 
 	wisunClient.Term()
 }
+
+func Test_Medium_Connect_StateFile(t *testing.T) {
+	scanned := PanDesc{
+		Addr:     "12345678ABCDEF01",
+		IPV6Addr: "FE80:0000:0000:0000:021D:1290:1234:ABCD",
+		Channel:  "21",
+		PanID:    "8888",
+	}
+
+	testcases := []struct {
+		name  string
+		saved PanDesc
+		want  PanDesc
+	}{
+		{
+			// PanID differs from the scanned one, so it is joined without scanning
+			name:  "hit",
+			saved: PanDesc{Addr: "12345678ABCDEF01", IPV6Addr: "FE80:0000:0000:0000:021D:1290:1234:ABCD", Channel: "21", PanID: "0002"},
+			want:  PanDesc{Addr: "12345678ABCDEF01", IPV6Addr: "FE80:0000:0000:0000:021D:1290:1234:ABCD", Channel: "21", PanID: "0002"},
+		},
+		{
+			name:  "miss",
+			saved: PanDesc{Addr: "12345678ABCDEF02", IPV6Addr: "FE80:0000:0000:0000:021D:1290:1234:ABCE", Channel: "21", PanID: "8888"},
+			want:  scanned,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json")
+			err := savePanDesc(path, tc.saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := NewBP35C2Client(testPort)
+			defer c.Close()
+			c.SetStateFile(path)
+
+			err = c.Connect(context.Background(), "000000TESTID00000000000000000000", "TESTPWDYYYYY")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, c.getPanDesc()); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+
+			got, err := loadPanDesc(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Diffrent state file: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Medium_DetectDialect(t *testing.T) {
+	c := NewBP35C2Client(testPort)
+	defer c.Close()
+
+	got, err := c.DetectDialect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(DialectBP35C2, got); diff != "" {
+		t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+	}
+}
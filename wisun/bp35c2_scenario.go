@@ -0,0 +1,153 @@
+package wisun
+
+import (
+	"embed"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scenarioData is scenarios of the emulator. default.json is the happy path used unless another is loaded.
+//
+//go:embed scenarios
+var scenarioData embed.FS
+
+// Scenario describes responses of BP35C2Emulator to commands, and properties of the emulated smart-meter
+type Scenario struct {
+	Name string `json:"name"`
+	// NoEcho disables echo back of commands
+	NoEcho bool `json:"no_echo"`
+	// Rules are searched in order for the first one matching a command
+	Rules []Rule `json:"rules"`
+	// Properties are properties of the smart-meter by EPC in hex, e.g. "E7"
+	Properties map[string]PropertySpec `json:"properties"`
+}
+
+// Rule is responses to commands which start with Command.
+// Responses are used in order of calls, and the last one is repeated.
+// An empty response drops the command.
+type Rule struct {
+	Command   string     `json:"command"`
+	Responses [][]Output `json:"responses"`
+}
+
+// Output is an output of a response, written after Delay.
+// One of Line, OK, Fail, PANA or Reply is set.
+type Output struct {
+	Delay Duration `json:"delay"`
+	Line  string   `json:"line"`
+	OK    bool     `json:"ok"`
+	Fail  string   `json:"fail"`  // error code, e.g. "ER04"
+	PANA  bool     `json:"pana"`  // PANA authentication exchange followed by EVENT 25
+	Reply bool     `json:"reply"` // ECHONET Lite response to the frame sent by SKSENDTO
+}
+
+// PropertySpec is a property of the emulated smart-meter.
+// Data is returned as is if it is set. Otherwise Values are returned in order of reads,
+// and the last one is repeated, or Start + Step * reads is returned.
+type PropertySpec struct {
+	Data   string  `json:"data"` // hex
+	Values []int64 `json:"values"`
+	Start  int64   `json:"start"`
+	Step   int64   `json:"step"`
+	Size   int     `json:"size"` // bytes of a value, 4 if zero
+}
+
+// Duration is time.Duration in JSON string such as "3s"
+type Duration time.Duration
+
+// UnmarshalJSON parses a string of time.ParseDuration
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// LoadScenario reads a scenario from a JSON file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScenario(data)
+}
+
+// BuiltinScenario returns a scenario bundled with the emulator, e.g. "default"
+func BuiltinScenario(name string) (*Scenario, error) {
+	data, err := scenarioData.ReadFile("scenarios/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown scenario: %s", name)
+	}
+	return ParseScenario(data)
+}
+
+// ParseScenario parses a scenario in JSON
+func ParseScenario(data []byte) (*Scenario, error) {
+	s := &Scenario{}
+	err := json.Unmarshal(data, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scenario: %w", err)
+	}
+	for epc, p := range s.Properties {
+		if _, err := strconv.ParseUint(epc, 16, 8); err != nil {
+			return nil, fmt.Errorf("invalid EPC in scenario: %s", epc)
+		}
+		if _, err := hex.DecodeString(p.Data); err != nil {
+			return nil, fmt.Errorf("invalid data of %s in scenario: %w", epc, err)
+		}
+		if p.Size < 0 || p.Size > 8 {
+			return nil, fmt.Errorf("invalid size of %s in scenario: %d", epc, p.Size)
+		}
+	}
+	return s, nil
+}
+
+// rule returns the rule for cmd
+func (s *Scenario) rule(cmd string) (int, bool) {
+	for i, r := range s.Rules {
+		if strings.HasPrefix(cmd, r.Command) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// property returns EDT of epc at the n-th read
+func (s *Scenario) property(epc byte, n int) ([]byte, bool) {
+	p, ok := s.Properties[fmt.Sprintf("%02X", epc)]
+	if !ok {
+		return nil, false
+	}
+	if p.Data != "" {
+		data, _ := hex.DecodeString(p.Data)
+		return data, true
+	}
+
+	v := p.Start + p.Step*int64(n)
+	if len(p.Values) > 0 {
+		if n >= len(p.Values) {
+			n = len(p.Values) - 1
+		}
+		v = p.Values[n]
+	}
+	size := p.Size
+	if size == 0 {
+		size = 4
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(v))
+	return buf[8-size:], true
+}
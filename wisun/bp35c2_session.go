@@ -0,0 +1,195 @@
+package wisun
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	sessionUpMetric = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "wisun",
+			Name:      "session_up",
+			Help:      "1 if PANA session with the smart-meter is established",
+		},
+	)
+	reconnectsMetric = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "wisun",
+			Name:      "reconnects_total",
+			Help:      "Number of reconnections to the smart-meter after the session was lost",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(sessionUpMetric)
+	prometheus.MustRegister(reconnectsMetric)
+}
+
+const (
+	// maxSendFailures is number of consecutive failures of Send regarded as loss of the session
+	maxSendFailures = 3
+	// reconnectDelay is delay before the first reconnection. It doubles on each failure.
+	reconnectDelay    = 5 * time.Second
+	maxReconnectDelay = 5 * time.Minute
+	reconnectTimeout  = 5 * time.Minute
+)
+
+// session is state of PANA session with the smart-meter
+type session struct {
+	up           bool
+	keepSession  bool // reconnect when the session is lost, set when Connect succeeds
+	reconnecting bool
+	failures     int           // consecutive failures of Send
+	done         chan struct{} // closed by Close to stop reconnection
+	delay        time.Duration // delay before the first reconnection, reconnectDelay if zero
+	maxDelay     time.Duration // maxReconnectDelay if zero
+}
+
+// SessionUp returns true if PANA session is established
+func (c *BP35C2Client) SessionUp() bool {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	return c.up
+}
+
+func (c *BP35C2Client) setSessionUp(up bool) {
+	c.rmu.Lock()
+	c.up = up
+	if up {
+		c.failures = 0
+	}
+	c.rmu.Unlock()
+
+	if up {
+		sessionUpMetric.Set(1)
+	} else {
+		sessionUpMetric.Set(0)
+	}
+}
+
+// available returns false while the session is lost and being reconnected
+func (c *BP35C2Client) available() bool {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	return !c.keepSession || c.up
+}
+
+// trackSession updates state of the session with EVENT
+func (c *BP35C2Client) trackSession(ev event) {
+	e, ok := ev.(notifyEvent)
+	if !ok {
+		return
+	}
+	switch e.num {
+	case 0x25: // PANA authentication succeeded, also after automatic re-authentication
+		c.setSessionUp(true)
+	case 0x26, 0x27, 0x28, 0x29: // terminated by the peer or us, termination timed out, lifetime expired
+		log.Printf("PANA session lost: EVENT %02X", e.num)
+		c.setSessionUp(false)
+		c.startReconnect()
+	}
+}
+
+func (c *BP35C2Client) sendSucceeded() {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	c.failures = 0
+}
+
+func (c *BP35C2Client) sendFailed() {
+	c.rmu.Lock()
+	c.failures++
+	lost := c.keepSession && c.up && c.failures >= maxSendFailures
+	c.rmu.Unlock()
+
+	if lost {
+		log.Printf("PANA session regarded as lost after %d failures", maxSendFailures)
+		c.setSessionUp(false)
+		c.startReconnect()
+	}
+}
+
+// startReconnect starts reconnection unless it is in progress
+func (c *BP35C2Client) startReconnect() {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	if !c.keepSession || c.closed || c.reconnecting {
+		return
+	}
+	if c.done == nil {
+		c.done = make(chan struct{})
+	}
+	c.reconnecting = true
+	go c.reconnect(c.done)
+}
+
+// reconnect rejoins PAN with exponential backoff until the session is up again
+func (c *BP35C2Client) reconnect(done <-chan struct{}) {
+	defer func() {
+		c.rmu.Lock()
+		c.reconnecting = false
+		c.rmu.Unlock()
+	}()
+
+	c.rmu.Lock()
+	delay, maxDelay := c.delay, c.maxDelay
+	c.rmu.Unlock()
+	if delay == 0 {
+		delay = reconnectDelay
+	}
+	if maxDelay == 0 {
+		maxDelay = maxReconnectDelay
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(delay):
+		}
+
+		c.rmu.Lock()
+		up, keep := c.up, c.keepSession
+		c.rmu.Unlock()
+		if up || !keep {
+			// re-authenticated automatically, or terminated
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), reconnectTimeout)
+		err := c.rejoin(ctx)
+		cancel()
+		if err == nil {
+			reconnectsMetric.Inc()
+			log.Println("reconnected")
+			return
+		}
+
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+		log.Printf("[Error] reconnect failed: %s, retry in %s", err, delay)
+	}
+}
+
+// rejoin joins PAN in cached PanDesc, and scans PAN again if it fails
+func (c *BP35C2Client) rejoin(ctx context.Context) error {
+	joined, err := c.Join(c.getPanDesc())
+	if err == nil && joined {
+		return nil
+	}
+	log.Println("rejoin to cached PAN failed, scan again")
+	return c.scanAndJoin(ctx)
+}
+
+func (c *BP35C2Client) getPanDesc() PanDesc {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	return c.panDesc
+}
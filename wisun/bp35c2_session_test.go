@@ -0,0 +1,139 @@
+package wisun
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/u-one/go-el-controller/transport"
+)
+
+// mockCommands responds to commands in order of their prefix.
+// Lines in unsolicited are read before any command.
+func mockCommands(t *testing.T, m *transport.MockSerial, unsolicited []string, commands map[string][][]string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	queue := unsolicited
+
+	m.EXPECT().Send(gomock.Any()).DoAndReturn(func(cmd []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		queue = append(queue, string(cmd))
+		for prefix, responses := range commands {
+			if strings.HasPrefix(string(cmd), prefix) && len(responses) > 0 {
+				queue = append(queue, responses[0]...)
+				commands[prefix] = responses[1:]
+				return nil
+			}
+		}
+		t.Errorf("unexpected command: %s", cmd)
+		return nil
+	}).AnyTimes()
+
+	m.EXPECT().Recv().DoAndReturn(func() ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(queue) == 0 {
+			time.Sleep(time.Millisecond)
+			return nil, fmt.Errorf("serial: timeout")
+		}
+		line := queue[0]
+		queue = queue[1:]
+		return []byte(line), nil
+	}).AnyTimes()
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("condition not satisfied")
+}
+
+func Test_reconnect(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := transport.NewMockSerial(ctrl)
+	mockCommands(t, m, []string{"EVENT 29 FE80:0000:0000:0000:021D:1291:0000:0574 0"}, map[string][][]string{
+		"SKJOIN FE80:0000:0000:0000:021D:1291:0000:0574": {
+			{"OK", "EVENT 24 FE80:0000:0000:0000:021D:1291:0000:0574 0"},
+		},
+		"SKSCAN 2 FFFFFFFF 4 0": {
+			{"OK", "EVENT 20 FE80:0000:0000:0000:021D:1291:0000:0575 0", "EPANDESC", "  Channel:3B", "  Channel Page:09", "  Pan ID:8889", "  Addr:001D129100000575", "  LQI:CA", "  Side:0", "  PairID:00000000"},
+		},
+		"SKLL64 001D129100000575": {
+			{"FE80:0000:0000:0000:021D:1291:0000:0575"},
+		},
+		"SKSREG S2 3B":   {{"OK"}},
+		"SKSREG S3 8889": {{"OK"}},
+		"SKJOIN FE80:0000:0000:0000:021D:1291:0000:0575": {
+			{"OK", "EVENT 25 FE80:0000:0000:0000:021D:1291:0000:0575 0"},
+		},
+	})
+
+	c := &BP35C2Client{serial: m, panDesc: PanDesc{Addr: "001D129100000574", IPV6Addr: "FE80:0000:0000:0000:021D:1291:0000:0574", Channel: "21", PanID: "8888"}}
+	c.up, c.keepSession, c.delay = true, true, time.Millisecond
+	reconnects := testutil.ToFloat64(reconnectsMetric)
+
+	// reader is running only while a command or listener needs it
+	c.acquire()
+	defer c.release()
+
+	want := PanDesc{Addr: "001D129100000575", IPV6Addr: "FE80:0000:0000:0000:021D:1291:0000:0575", Channel: "3B", PanID: "8889"}
+	waitFor(t, func() bool { return c.getPanDesc() == want })
+	waitFor(t, func() bool {
+		c.rmu.Lock()
+		defer c.rmu.Unlock()
+		return !c.reconnecting
+	})
+
+	if !c.SessionUp() {
+		t.Errorf("session is not up")
+	}
+	if got := testutil.ToFloat64(reconnectsMetric) - reconnects; got < 1 {
+		t.Errorf("reconnects not counted: %v", got)
+	}
+}
+
+func Test_sendFailed(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := transport.NewMockSerial(ctrl)
+	mock(t, m, "", []resp{
+		{"FAIL ER10\r\n", nil},
+	})
+	m.EXPECT().Close()
+
+	c := &BP35C2Client{serial: m, panDesc: PanDesc{IPV6Addr: "FE80:0000:0000:0000:021D:1291:0000:0574"}}
+	// reconnection doesn't start during the test
+	c.up, c.keepSession, c.delay = true, true, time.Hour
+	defer c.Close()
+
+	data := []byte{0x10, 0x81, 0x00, 0x01, 0x05, 0xff, 0x01, 0x02, 0x88, 0x01, 0x62, 0x01, 0xe7, 0x00}
+	for i := 0; i < maxSendFailures; i++ {
+		_, err := c.Send(data)
+		if diff := cmp.Diff("command failed [FAIL ER10]", fmt.Sprint(err)); diff != "" {
+			t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+		}
+	}
+
+	_, err := c.Send(data)
+	if diff := cmp.Diff("session is down", fmt.Sprint(err)); diff != "" {
+		t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+	}
+}
@@ -0,0 +1,77 @@
+package wisun
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// SetStateFile sets path of the file to store PanDesc of the PAN joined last.
+// Connect joins the PAN in the file without scanning, and scans only if the join fails.
+func (c *BP35C2Client) SetStateFile(path string) {
+	c.stateFile = path
+}
+
+// joinSaved joins the PAN in the state file
+func (c *BP35C2Client) joinSaved() error {
+	if c.stateFile == "" {
+		return fmt.Errorf("state file not set")
+	}
+	pd, err := loadPanDesc(c.stateFile)
+	if err != nil {
+		return err
+	}
+	log.Printf("Join PAN in %s: %#v", c.stateFile, pd)
+	return c.joinPAN(pd)
+}
+
+// saveState stores pd into the state file if it is set
+func (c *BP35C2Client) saveState(pd PanDesc) {
+	if c.stateFile == "" {
+		return
+	}
+	err := savePanDesc(c.stateFile, pd)
+	if err != nil {
+		log.Println("[Error] failed to save state:", err)
+	}
+}
+
+func loadPanDesc(path string) (PanDesc, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return PanDesc{}, err
+	}
+	pd := PanDesc{}
+	err = json.Unmarshal(data, &pd)
+	if err != nil {
+		return PanDesc{}, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if pd.Addr == "" || pd.IPV6Addr == "" || pd.Channel == "" || pd.PanID == "" {
+		return PanDesc{}, fmt.Errorf("incomplete PanDesc in %s: %#v", path, pd)
+	}
+	return pd, nil
+}
+
+// savePanDesc writes pd into a temporary file and renames it not to leave broken file
+func savePanDesc(path string, pd PanDesc) error {
+	data, err := json.MarshalIndent(pd, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
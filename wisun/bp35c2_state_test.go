@@ -0,0 +1,157 @@
+package wisun
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/u-one/go-el-controller/transport"
+)
+
+func Test_loadPanDesc(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	testcases := []struct {
+		name    string
+		content string
+		want    PanDesc
+		err     string
+	}{
+		{
+			name:    "valid",
+			content: `{"addr":"001D129100000574","ipv6_addr":"FE80:0000:0000:0000:021D:1291:0000:0574","channel":"21","pan_id":"8888"}`,
+			want:    PanDesc{Addr: "001D129100000574", IPV6Addr: "FE80:0000:0000:0000:021D:1291:0000:0574", Channel: "21", PanID: "8888"},
+		},
+		{
+			name:    "incomplete",
+			content: `{"addr":"001D129100000574","channel":"21","pan_id":"8888"}`,
+			err:     "incomplete PanDesc in %s",
+		},
+		{
+			name:    "broken",
+			content: `{"addr":`,
+			err:     "invalid state file %s",
+		},
+	}
+
+	for i, tc := range testcases {
+		tc := tc
+		path := filepath.Join(dir, fmt.Sprintf("state%d.json", i))
+		err := ioutil.WriteFile(path, []byte(tc.content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := loadPanDesc(path)
+			if tc.err != "" {
+				want := fmt.Sprintf(tc.err, path)
+				if !strings.HasPrefix(fmt.Sprint(err), want) {
+					t.Errorf("Diffrent error: want:%s, got:%v", want, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Connect_StateFile(t *testing.T) {
+	t.Parallel()
+
+	saved := PanDesc{Addr: "001D129100000574", IPV6Addr: "FE80:0000:0000:0000:021D:1291:0000:0574", Channel: "21", PanID: "8888"}
+	scanned := PanDesc{Addr: "001D129100000575", IPV6Addr: "FE80:0000:0000:0000:021D:1291:0000:0575", Channel: "3B", PanID: "8889"}
+
+	testcases := []struct {
+		name     string
+		commands map[string][][]string
+		want     PanDesc
+	}{
+		{
+			name: "hit",
+			commands: map[string][][]string{
+				"SKSREG S2 21":   {{"OK"}},
+				"SKSREG S3 8888": {{"OK"}},
+				"SKJOIN FE80:0000:0000:0000:021D:1291:0000:0574": {
+					{"OK", "EVENT 25 FE80:0000:0000:0000:021D:1291:0000:0574 0"},
+				},
+			},
+			want: saved,
+		},
+		{
+			name: "miss",
+			commands: map[string][][]string{
+				"SKSREG S2 21":   {{"OK"}},
+				"SKSREG S3 8888": {{"OK"}},
+				"SKJOIN FE80:0000:0000:0000:021D:1291:0000:0574": {
+					{"OK", "EVENT 24 FE80:0000:0000:0000:021D:1291:0000:0574 0"},
+				},
+				"SKSCAN 2 FFFFFFFF 4 0": {
+					{"OK", "EVENT 20 FE80:0000:0000:0000:021D:1291:0000:0575 0", "EPANDESC", "  Channel:3B", "  Channel Page:09", "  Pan ID:8889", "  Addr:001D129100000575", "  LQI:CA", "  Side:0", "  PairID:00000000"},
+				},
+				"SKLL64 001D129100000575": {
+					{"FE80:0000:0000:0000:021D:1291:0000:0575"},
+				},
+				"SKSREG S2 3B":   {{"OK"}},
+				"SKSREG S3 8889": {{"OK"}},
+				"SKJOIN FE80:0000:0000:0000:021D:1291:0000:0575": {
+					{"OK", "EVENT 25 FE80:0000:0000:0000:021D:1291:0000:0575 0"},
+				},
+			},
+			want: scanned,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := transport.NewMockSerial(ctrl)
+			tc.commands["SKSETPWD"] = [][]string{{"OK"}}
+			tc.commands["SKSETRBID"] = [][]string{{"OK"}}
+			tc.commands["ROPT"] = [][]string{{"OK 00"}}
+			mockCommands(t, m, nil, tc.commands)
+
+			path := filepath.Join(t.TempDir(), "state.json")
+			err := savePanDesc(path, saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := &BP35C2Client{serial: m}
+			c.SetStateFile(path)
+			err = c.Connect(context.Background(), "00000000000000000000000000000000", "PASSWORD")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tc.want, c.getPanDesc()); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+			got, err := loadPanDesc(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Diffrent state file: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
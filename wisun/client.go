@@ -9,4 +9,6 @@ type Client interface {
 	Connect(ctx context.Context, bRouteID, bRoutePW string) error
 	Close()
 	Send(data []byte) ([]byte, error)
+	SendOnly(data []byte) error
+	Listen(ctx context.Context) <-chan []byte
 }
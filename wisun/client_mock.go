@@ -73,3 +73,31 @@ func (mr *MockClientMockRecorder) Send(data interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockClient)(nil).Send), data)
 }
+
+// SendOnly mocks base method
+func (m *MockClient) SendOnly(data []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendOnly", data)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendOnly indicates an expected call of SendOnly
+func (mr *MockClientMockRecorder) SendOnly(data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendOnly", reflect.TypeOf((*MockClient)(nil).SendOnly), data)
+}
+
+// Listen mocks base method
+func (m *MockClient) Listen(ctx context.Context) <-chan []byte {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Listen", ctx)
+	ret0, _ := ret[0].(<-chan []byte)
+	return ret0
+}
+
+// Listen indicates an expected call of Listen
+func (mr *MockClientMockRecorder) Listen(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Listen", reflect.TypeOf((*MockClient)(nil).Listen), ctx)
+}
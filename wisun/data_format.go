@@ -0,0 +1,104 @@
+package wisun
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// DataFormat is the format of ERXUDP data, which is switched with WOPT
+type DataFormat int
+
+const (
+	// DataFormatKeep keeps the format set in the module
+	DataFormatKeep DataFormat = iota
+	// DataFormatBinary is raw binary
+	DataFormatBinary
+	// DataFormatHex is ASCII hex
+	DataFormatHex
+)
+
+// ParseDataFormat returns DataFormat of name: keep, binary or hex
+func ParseDataFormat(name string) (DataFormat, error) {
+	switch name {
+	case "keep":
+		return DataFormatKeep, nil
+	case "binary":
+		return DataFormatBinary, nil
+	case "hex":
+		return DataFormatHex, nil
+	}
+	return DataFormatKeep, fmt.Errorf("unknown data format: %s", name)
+}
+
+// SetDataFormat sets the format of ERXUDP data which Connect sets to the module
+func (c *BP35C2Client) SetDataFormat(f DataFormat) {
+	c.dataFormat = f
+}
+
+// ReadOption returns true if ERXUDP data is in ASCII hex (ROPT)
+func (c *BP35C2Client) ReadOption() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.commandTimeout())
+	defer cancel()
+
+	// OK XX
+	hexData := false
+	err := c.command(ctx, []byte("ROPT\r"), func(ev event) (bool, error) {
+		e, ok := ev.(okEvent)
+		if !ok {
+			return false, nil
+		}
+		opt, err := strconv.ParseUint(e.value, 16, 8)
+		if err != nil {
+			return false, fmt.Errorf("invalid option [%s]", e.value)
+		}
+		hexData = opt&0x01 != 0
+		return true, nil
+	})
+	return hexData, err
+}
+
+// WriteOption sets the format of ERXUDP data (WOPT).
+// The option is saved in flash memory of the module, whose write cycles are limited.
+func (c *BP35C2Client) WriteOption(hexData bool) error {
+	if hexData {
+		return c.commandOK("WOPT 01\r")
+	}
+	return c.commandOK("WOPT 00\r")
+}
+
+// setupDataFormat queries the format of ERXUDP data, and changes it only if it differs from the one set
+func (c *BP35C2Client) setupDataFormat() error {
+	d := c.Dialect()
+	if d.NoOption {
+		if c.dataFormat != DataFormatKeep && (c.dataFormat == DataFormatHex) != d.HexData {
+			log.Printf("%s doesn't support WOPT, ERXUDP data format is not changed", d.Name)
+		}
+		return nil
+	}
+
+	hexData, err := c.ReadOption()
+	if err != nil {
+		log.Printf("ROPT failed, ERXUDP data is assumed to be in the default format of %s: %s", d.Name, err)
+		return nil
+	}
+
+	want := hexData
+	switch c.dataFormat {
+	case DataFormatBinary:
+		want = false
+	case DataFormatHex:
+		want = true
+	}
+	if want != hexData {
+		err = c.WriteOption(want)
+		if err != nil {
+			return fmt.Errorf("WOPT failed: %w", err)
+		}
+	}
+
+	d.HexData = want
+	c.SetDialect(d)
+	return nil
+}
@@ -0,0 +1,127 @@
+package wisun
+
+import (
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/u-one/go-el-controller/transport"
+)
+
+func Test_setupDataFormat(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		dialect  Dialect
+		format   DataFormat
+		commands map[string][][]string
+		want     bool
+	}{
+		{
+			name:     "keep binary",
+			dialect:  DialectBP35C2,
+			format:   DataFormatKeep,
+			commands: map[string][][]string{"ROPT": {{"OK 00"}}},
+			want:     false,
+		},
+		{
+			name:     "keep hex",
+			dialect:  DialectBP35C2,
+			format:   DataFormatKeep,
+			commands: map[string][][]string{"ROPT": {{"OK 01"}}},
+			want:     true,
+		},
+		{
+			name:     "already hex",
+			dialect:  DialectBP35A1,
+			format:   DataFormatHex,
+			commands: map[string][][]string{"ROPT": {{"OK 01"}}},
+			want:     true,
+		},
+		{
+			name:    "binary to hex",
+			dialect: DialectBP35C2,
+			format:  DataFormatHex,
+			commands: map[string][][]string{
+				"ROPT":    {{"OK 00"}},
+				"WOPT 01": {{"OK"}},
+			},
+			want: true,
+		},
+		{
+			name:     "ROPT not supported",
+			dialect:  DialectBP35A1,
+			format:   DataFormatBinary,
+			commands: map[string][][]string{"ROPT": {{"FAIL ER04"}}},
+			want:     true,
+		},
+		{
+			name:     "no option",
+			dialect:  DialectRL7023,
+			format:   DataFormatBinary,
+			commands: map[string][][]string{},
+			want:     true,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := transport.NewMockSerial(ctrl)
+			mockCommands(t, m, nil, tc.commands)
+
+			c := &BP35C2Client{serial: m, dialect: tc.dialect}
+			c.SetDataFormat(tc.format)
+			err := c.setupDataFormat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, c.Dialect().HexData); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+			for cmd, responses := range tc.commands {
+				if len(responses) > 0 {
+					t.Errorf("command not sent: %s", cmd)
+				}
+			}
+		})
+	}
+}
+
+func Test_LastReception(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := transport.NewMockSerial(ctrl)
+	mockCommands(t, m, nil, map[string][][]string{
+		"SKSENDTO": {{
+			"EVENT 21 FE80:0000:0000:0000:021D:1291:0000:0574 0 00",
+			"OK",
+			"ERXUDP FE80:0000:0000:0000:021D:1291:0000:0574 FE80:0000:0000:0000:021D:1290:1234:5678 0E1A 0E1A 001D129100000574 -61 1 0 0004 \x10\x81\x00\x01",
+		}},
+	})
+
+	c := &BP35C2Client{serial: m, panDesc: PanDesc{IPV6Addr: "FE80:0000:0000:0000:021D:1291:0000:0574"}}
+	_, err := c.Send([]byte{0x10, 0x81, 0x00, 0x01, 0x05, 0xff, 0x01, 0x02, 0x88, 0x01, 0x62, 0x01, 0xe7, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := c.LastReception()
+	if got.Time.IsZero() {
+		t.Errorf("time not recorded")
+	}
+	got.Time = time.Time{}
+	want := Reception{Sender: "FE80:0000:0000:0000:021D:1291:0000:0574", RSSI: -61, HasRSSI: true, Secured: true}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+	}
+}
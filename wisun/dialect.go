@@ -0,0 +1,122 @@
+package wisun
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Dialect is a variant of SKSTACK commands and responses, which differs among Wi-SUN modules.
+// The zero value is the dialect of BP35C2.
+type Dialect struct {
+	Name string
+	// NoSide is true if commands and responses have no Side parameter,
+	// which selects B-route or HAN on dual stack modules like BP35C2
+	NoSide bool
+	// HexData is true if data of ERXUDP is in ASCII hex instead of binary
+	HexData bool
+	// NoOption is true if the module doesn't support ROPT/WOPT to switch the format of ERXUDP data
+	NoOption bool
+	// NoLQI is true if the module doesn't support SKLQI to query LQI of the link to the PAN joined
+	NoLQI bool
+}
+
+var (
+	// DialectBP35C2 is ROHM BP35C2, dual stack of B-route and HAN
+	DialectBP35C2 = Dialect{Name: "BP35C2"}
+	// DialectBP35A1 is ROHM BP35A1
+	DialectBP35A1 = Dialect{Name: "BP35A1", NoSide: true, HexData: true, NoLQI: true}
+	// DialectRL7023 is Tessera RL7023 Stick-D/IPS
+	DialectRL7023 = Dialect{Name: "RL7023", NoSide: true, HexData: true, NoOption: true, NoLQI: true}
+)
+
+// Dialects are dialects which can be selected by name
+var Dialects = []Dialect{DialectBP35C2, DialectBP35A1, DialectRL7023}
+
+// LookupDialect returns the dialect of name, case insensitive
+func LookupDialect(name string) (Dialect, error) {
+	for _, d := range Dialects {
+		if strings.EqualFold(d.Name, name) {
+			return d, nil
+		}
+	}
+	return Dialect{}, fmt.Errorf("unknown module: %s", name)
+}
+
+// einfoFieldsWithSide is the number of fields of EINFO on modules with Side,
+// <IPADDR> <ADDR64> <CHANNEL> <PANID> <ADDR16> <SIDE>
+const einfoFieldsWithSide = 6
+
+// detectDialect guesses the dialect from fields of SKINFO, and whether the module supports ROPT.
+// SKVER of BP35A1 and RL7023 reports versions of SKSTACK IP alike, and EINFO of both has no Side,
+// so they are told apart by ROPT, which RL7023 doesn't support. BP35A1 of firmware without ROPT
+// is regarded as RL7023, which has the same ERXUDP data format and no option to switch it.
+func detectDialect(info []string, hasOption bool) Dialect {
+	if len(info) >= einfoFieldsWithSide {
+		return DialectBP35C2
+	}
+	if hasOption {
+		return DialectBP35A1
+	}
+	return DialectRL7023
+}
+
+// Dialect returns the dialect of the module
+func (c *BP35C2Client) Dialect() Dialect {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	return c.dialect
+}
+
+// SetDialect sets the dialect of the module. Call it before Connect.
+func (c *BP35C2Client) SetDialect(d Dialect) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	c.dialect = d
+}
+
+// DetectDialect detects the dialect of the module with SKVER, SKINFO and ROPT, and sets it
+func (c *BP35C2Client) DetectDialect() (Dialect, error) {
+	version, err := c.Version()
+	if err != nil {
+		return Dialect{}, fmt.Errorf("Version failed: %w", err)
+	}
+	info, err := c.Info()
+	if err != nil {
+		return Dialect{}, fmt.Errorf("Info failed: %w", err)
+	}
+	hasOption := false
+	if len(info) < einfoFieldsWithSide {
+		_, err := c.ReadOption()
+		if err != nil {
+			log.Printf("ROPT failed: %s", err)
+		}
+		hasOption = err == nil
+	}
+	d := detectDialect(info, hasOption)
+	log.Printf("detected module: %s (version %s)", d.Name, version)
+	c.SetDialect(d)
+	return d, nil
+}
+
+// side returns Side parameter followed by a space if the dialect has it
+func (d Dialect) side() string {
+	if d.NoSide {
+		return ""
+	}
+	return "0 "
+}
+
+// UseDialect sets the dialect of name, or detects it if name is "auto"
+func (c *BP35C2Client) UseDialect(name string) error {
+	if name == "auto" {
+		_, err := c.DetectDialect()
+		return err
+	}
+	d, err := LookupDialect(name)
+	if err != nil {
+		return err
+	}
+	c.SetDialect(d)
+	return nil
+}
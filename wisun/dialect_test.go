@@ -0,0 +1,165 @@
+package wisun
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/u-one/go-el-controller/transport"
+)
+
+func Test_detectDialect(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name      string
+		info      []string
+		hasOption bool
+		want      Dialect
+	}{
+		{
+			name:      "BP35C2",
+			info:      []string{"FE80:0000:0000:0000:021D:1291:0000:0574", "001D129100000574", "21", "8888", "FFFE", "0"},
+			hasOption: true,
+			want:      DialectBP35C2,
+		},
+		{
+			name:      "BP35A1",
+			info:      []string{"FE80:0000:0000:0000:021D:1291:0000:0574", "001D129100000574", "21", "8888", "FFFE"},
+			hasOption: true,
+			want:      DialectBP35A1,
+		},
+		{
+			name:      "RL7023",
+			info:      []string{"FE80:0000:0000:0000:021D:1291:0000:0574", "001D129100000574", "21", "8888", "FFFE"},
+			hasOption: false,
+			want:      DialectRL7023,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := detectDialect(tc.info, tc.hasOption)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_LookupDialect(t *testing.T) {
+	t.Parallel()
+
+	got, err := LookupDialect("rl7023")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(DialectRL7023, got); diff != "" {
+		t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+	}
+
+	_, err = LookupDialect("BP35X9")
+	if diff := cmp.Diff("unknown module: BP35X9", fmt.Sprint(err)); diff != "" {
+		t.Errorf("Diffrent error: -want, +got: \n%s", diff)
+	}
+}
+
+func Test_DetectDialect(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		commands map[string][][]string
+		want     Dialect
+	}{
+		{
+			name: "BP35C2",
+			commands: map[string][][]string{
+				"SKVER":  {{"EVER 1.5.2", "OK"}},
+				"SKINFO": {{"EINFO FE80:0000:0000:0000:021D:1291:0000:0574 001D129100000574 21 8888 FFFE 0", "OK"}},
+			},
+			want: DialectBP35C2,
+		},
+		{
+			name: "BP35A1",
+			commands: map[string][][]string{
+				"SKVER":  {{"EVER 1.2.10", "OK"}},
+				"SKINFO": {{"EINFO FE80:0000:0000:0000:021D:1291:0000:0574 001D129100000574 21 8888 FFFE", "OK"}},
+				"ROPT":   {{"OK 01"}},
+			},
+			want: DialectBP35A1,
+		},
+		{
+			name: "RL7023",
+			commands: map[string][][]string{
+				"SKVER":  {{"EVER 1.2.8", "OK"}},
+				"SKINFO": {{"EINFO FE80:0000:0000:0000:021D:1291:0000:0574 001D129100000574 21 8888 FFFE", "OK"}},
+				"ROPT":   {{"FAIL ER04"}},
+			},
+			want: DialectRL7023,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			m := transport.NewMockSerial(ctrl)
+			mockCommands(t, m, nil, tc.commands)
+
+			c := &BP35C2Client{serial: m, dialect: DialectBP35C2}
+			got, err := c.DetectDialect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want, c.Dialect()); diff != "" {
+				t.Errorf("Diffrent dialect: -want, +got: \n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_Send_BP35A1(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := transport.NewMockSerial(ctrl)
+	// no Side in SKSENDTO, EVENT and ERXUDP, and ERXUDP data is in ASCII hex
+	mockCommands(t, m, nil, map[string][][]string{
+		"SKSENDTO 1 FE80:0000:0000:0000:021D:1291:0000:0574 0E1A 1 000E ": {{
+			"EVENT 21 FE80:0000:0000:0000:021D:1291:0000:0574 00",
+			"OK",
+			"ERXUDP FE80:0000:0000:0000:021D:1291:0000:0574 FE80:0000:0000:0000:021D:1290:1234:5678 0E1A 0E1A 001D129100000574 1 0012 1081000102880105FF017201E704000001F8",
+		}},
+		"SKSCAN 2 FFFFFFFF 4 \r\n": {{"OK", "EVENT 22 FE80:0000:0000:0000:021D:1290:1234:5678"}},
+	})
+
+	c := &BP35C2Client{serial: m, dialect: DialectBP35A1, panDesc: PanDesc{IPV6Addr: "FE80:0000:0000:0000:021D:1291:0000:0574"}}
+	got, err := c.Send([]byte{0x10, 0x81, 0x00, 0x01, 0x05, 0xff, 0x01, 0x02, 0x88, 0x01, 0x62, 0x01, 0xe7, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x10, 0x81, 0x00, 0x01, 0x02, 0x88, 0x01, 0x05, 0xff, 0x01, 0x72, 0x01, 0xe7, 0x04, 0x00, 0x00, 0x01, 0xf8}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+	}
+
+	_, found, err := c.scan(context.Background(), 4)
+	if err != nil || found {
+		t.Errorf("Diffrent result: found:%v, err:%v", found, err)
+	}
+}
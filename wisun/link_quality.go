@@ -0,0 +1,146 @@
+package wisun
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// LinkQuality is quality of the radio link to the smart-meter
+// Only values measured by the module are set. LQI is not converted from RSSI nor vice versa.
+type LinkQuality struct {
+	LQI     int     // LQI of SKLQI, or of EPANDESC of the scan until the first UpdateLinkQuality. Valid if HasLQI
+	HasLQI  bool    //
+	RSSI    float64 // dBm of ERXUDP received since the previous UpdateLinkQuality. Valid if HasRSSI
+	HasRSSI bool    //
+	Channel string  // hex, e.g. "21"
+	PanID   string  // hex, e.g. "8888"
+}
+
+// linkSample is LQI of the scan and RSSI received since the previous UpdateLinkQuality
+type linkSample struct {
+	lqi     int
+	hasLQI  bool
+	rssi    int // dBm
+	hasRSSI bool
+}
+
+// LinkQuality returns quality of the link to the smart-meter as of the last UpdateLinkQuality,
+// or of the scan and frames received until the first UpdateLinkQuality
+func (c *BP35C2Client) LinkQuality() LinkQuality {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	lq := c.link
+	if !c.linkPolled {
+		lq = c.sample.apply(lq)
+	}
+	if lq.Channel == "" {
+		lq.Channel, lq.PanID = c.panDesc.Channel, c.panDesc.PanID
+	}
+	return lq
+}
+
+// LQI queries LQI of the link to the PAN joined with SKLQI
+func (c *BP35C2Client) LQI() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.commandTimeout())
+	defer cancel()
+
+	lqi, found := 0, false
+	err := c.command(ctx, []byte("SKLQI\r\n"), func(ev event) (bool, error) {
+		switch e := ev.(type) {
+		case lqiEvent:
+			lqi, found = e.lqi, true
+		case okEvent:
+			if !found {
+				return false, fmt.Errorf("ELQI not found")
+			}
+			return true, nil
+		}
+		return false, nil
+	})
+	return lqi, err
+}
+
+// UpdateLinkQuality queries the channel and PAN ID in use with SKINFO and LQI with SKLQI,
+// and takes RSSI of frames received since the previous call.
+// LQI is invalid on modules without SKLQI, and RSSI is invalid if no frame with RSSI has been received,
+// rather than converted from each other or kept stale.
+func (c *BP35C2Client) UpdateLinkQuality() error {
+	if !c.available() {
+		return fmt.Errorf("session is down")
+	}
+	info, err := c.Info()
+	if err != nil {
+		return fmt.Errorf("Info failed: %w", err)
+	}
+	// <IPADDR> <ADDR64> <CHANNEL> <PANID> <ADDR16>
+	if len(info) < 4 {
+		return fmt.Errorf("invalid EINFO: %v", info)
+	}
+	lq := LinkQuality{Channel: info[2], PanID: info[3]}
+
+	if c.hasLQI() {
+		lqi, err := c.LQI()
+		switch {
+		case err == nil:
+			lq.LQI, lq.HasLQI = lqi, true
+		case isCommandFailure(err):
+			log.Printf("SKLQI is not supported (%s), LQI is not available", err)
+			c.rmu.Lock()
+			c.noLQI = true
+			c.rmu.Unlock()
+		default:
+			return fmt.Errorf("LQI failed: %w", err)
+		}
+	}
+
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	lq.RSSI, lq.HasRSSI = float64(c.sample.rssi), c.sample.hasRSSI
+	c.link = lq
+	c.sample = linkSample{}
+	c.linkPolled = true
+	if !c.link.HasRSSI {
+		log.Println("no frame with RSSI since the last update")
+	}
+	return nil
+}
+
+// hasLQI returns true if LQI can be queried with SKLQI
+func (c *BP35C2Client) hasLQI() bool {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	return !c.dialect.NoLQI && !c.noLQI
+}
+
+// isCommandFailure returns true if err is FAIL of the module, e.g. FAIL ER04 for unsupported command
+func isCommandFailure(err error) bool {
+	return strings.HasPrefix(err.Error(), "command failed")
+}
+
+// apply sets LQI and RSSI of the sample to lq
+func (s linkSample) apply(lq LinkQuality) LinkQuality {
+	lq.LQI, lq.HasLQI, lq.RSSI, lq.HasRSSI = s.lqi, s.hasLQI, float64(s.rssi), s.hasRSSI
+	return lq
+}
+
+// setLQI records LQI of PAN found by scan
+func (c *BP35C2Client) setLQI(e panDescEvent) {
+	if !e.hasLQI {
+		return
+	}
+	log.Printf("LQI: %d", e.lqi)
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	c.sample = linkSample{lqi: e.lqi, hasLQI: true}
+}
+
+// setRSSI records RSSI of ERXUDP. rmu must be held.
+func (c *BP35C2Client) setRSSI(e rxUDPEvent) {
+	if !e.hasRSSI {
+		return
+	}
+	c.sample.rssi, c.sample.hasRSSI = e.rssi, true
+}
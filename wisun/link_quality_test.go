@@ -0,0 +1,119 @@
+package wisun
+
+import (
+	"context"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/u-one/go-el-controller/transport"
+)
+
+func Test_LinkQuality(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := transport.NewMockSerial(ctrl)
+	einfo := []string{"EINFO FE80:0000:0000:0000:021D:1290:1234:5678 001D129012345678 3B 8889 FFFE 0", "OK"}
+	mockCommands(t, m, nil, map[string][][]string{
+		"SKSCAN": {
+			{"OK", "EVENT 20 FE80:0000:0000:0000:021D:1291:0000:0574 0", "EPANDESC", "  Channel:21", "  Channel Page:09", "  Pan ID:8888", "  Addr:001D129100000574", "  LQI:A0", "  Side:0", "  PairID:00000000"},
+		},
+		"SKINFO": {einfo, einfo, einfo, einfo},
+		// FAIL ER04 on firmware without SKLQI, after which it is not sent
+		"SKLQI": {{"ELQI 9C", "OK"}, {"ELQI 7A", "OK"}, {"FAIL ER04"}},
+		"SKSENDTO": {
+			{
+				"EVENT 21 FE80:0000:0000:0000:021D:1291:0000:0574 0 00",
+				"OK",
+				"ERXUDP FE80:0000:0000:0000:021D:1291:0000:0574 FE80:0000:0000:0000:021D:1290:1234:5678 0E1A 0E1A 001D129100000574 -61 1 0 0004 \x10\x81\x00\x01",
+			},
+			{
+				"EVENT 21 FE80:0000:0000:0000:021D:1291:0000:0574 0 00",
+				"OK",
+				"ERXUDP FE80:0000:0000:0000:021D:1291:0000:0574 FE80:0000:0000:0000:021D:1290:1234:5678 0E1A 0E1A 001D129100000574 -70 1 0 0004 \x10\x81\x00\x02",
+			},
+			{
+				"EVENT 21 FE80:0000:0000:0000:021D:1291:0000:0574 0 00",
+				"OK",
+				"ERXUDP FE80:0000:0000:0000:021D:1291:0000:0574 FE80:0000:0000:0000:021D:1290:1234:5678 0E1A 0E1A 001D129100000574 -65 1 0 0004 \x10\x81\x00\x03",
+			},
+		},
+	})
+
+	c := &BP35C2Client{serial: m}
+	pd, _, err := c.scan(context.Background(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.panDesc = PanDesc{Addr: pd.Addr, IPV6Addr: "FE80:0000:0000:0000:021D:1291:0000:0574", Channel: pd.Channel, PanID: pd.PanID}
+
+	opt := cmpopts.EquateApprox(0, 1e-9)
+	// LQI of the scan until the first update, and no RSSI until ERXUDP with RSSI is received
+	want := LinkQuality{LQI: 0xa0, HasLQI: true, Channel: "21", PanID: "8888"}
+	if diff := cmp.Diff(want, c.LinkQuality(), opt); diff != "" {
+		t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+	}
+
+	polls := []struct {
+		send []byte
+		want LinkQuality
+	}{
+		{
+			send: []byte{0x10, 0x81, 0x00, 0x01, 0x05, 0xff, 0x01, 0x02, 0x88, 0x01, 0x62, 0x01, 0xe7, 0x00},
+			want: LinkQuality{LQI: 0x9c, HasLQI: true, RSSI: -61, HasRSSI: true, Channel: "3B", PanID: "8889"},
+		},
+		{
+			send: []byte{0x10, 0x81, 0x00, 0x02, 0x05, 0xff, 0x01, 0x02, 0x88, 0x01, 0x62, 0x01, 0xe7, 0x00},
+			want: LinkQuality{LQI: 0x7a, HasLQI: true, RSSI: -70, HasRSSI: true, Channel: "3B", PanID: "8889"},
+		},
+		{
+			// SKLQI failed, and no frame received since the last update
+			want: LinkQuality{Channel: "3B", PanID: "8889"},
+		},
+		{
+			// RSSI is not converted into LQI
+			send: []byte{0x10, 0x81, 0x00, 0x03, 0x05, 0xff, 0x01, 0x02, 0x88, 0x01, 0x62, 0x01, 0xe7, 0x00},
+			want: LinkQuality{RSSI: -65, HasRSSI: true, Channel: "3B", PanID: "8889"},
+		},
+	}
+	for _, poll := range polls {
+		if poll.send != nil {
+			if _, err := c.Send(poll.send); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := c.UpdateLinkQuality(); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(poll.want, c.LinkQuality(), opt); diff != "" {
+			t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+		}
+	}
+}
+
+func Test_LinkQuality_NoLQI(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := transport.NewMockSerial(ctrl)
+	// SKLQI is not sent to modules without it
+	mockCommands(t, m, nil, map[string][][]string{
+		"SKINFO": {{"EINFO FE80:0000:0000:0000:021D:1290:1234:5678 001D129012345678 21 8888 FFFE", "OK"}},
+	})
+
+	c := &BP35C2Client{serial: m, dialect: DialectBP35A1}
+	c.panDesc = PanDesc{Addr: "001D129100000574", IPV6Addr: "FE80:0000:0000:0000:021D:1291:0000:0574", Channel: "21", PanID: "8888"}
+	c.setLQI(panDescEvent{lqi: 0xa0, hasLQI: true})
+
+	if err := c.UpdateLinkQuality(); err != nil {
+		t.Fatal(err)
+	}
+	want := LinkQuality{Channel: "21", PanID: "8888"}
+	if diff := cmp.Diff(want, c.LinkQuality()); diff != "" {
+		t.Errorf("Diffrent result: -want, +got: \n%s", diff)
+	}
+}